@@ -1,5 +1,14 @@
 package tengo
 
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/d5/tengo/v2/token"
+)
+
 var builtinFuncs = []*BuiltinFunction{
 	{
 		Name:  "len",
@@ -113,13 +122,111 @@ var builtinFuncs = []*BuiltinFunction{
 		Name:  "format",
 		Value: builtinFormat,
 	},
+	{
+		Name:  "is_record",
+		Value: builtinIsRecord,
+	},
+	{
+		Name:  "bigint",
+		Value: builtinBigInt,
+	},
+	{
+		Name:  "decimal",
+		Value: builtinDecimal,
+	},
+	{
+		Name:  "set",
+		Value: builtinSet,
+	},
+	{
+		Name:  "has",
+		Value: builtinHas,
+	},
+	{
+		Name:  "is_set",
+		Value: builtinIsSet,
+	},
+	{
+		Name:  "is_immutable_set",
+		Value: builtinIsImmutableSet,
+	},
+	{
+		Name:  "delete",
+		Value: builtinDelete,
+	},
+	{
+		Name:  "splice",
+		Value: builtinSplice,
+	},
+	{
+		Name:  "keys",
+		Value: builtinKeys,
+	},
+	{
+		Name:  "values",
+		Value: builtinValues,
+	},
+	{
+		Name:  "contains",
+		Value: builtinContains,
+	},
+	{
+		Name:  "range",
+		Value: builtinRange,
+	},
+	{
+		Name:  "sort",
+		Value: builtinSort,
+	},
+	{
+		Name:  "sort_by",
+		Value: builtinSortBy,
+	},
+	{
+		Name:  "reverse",
+		Value: builtinReverse,
+	},
+	{
+		Name:  "min",
+		Value: builtinMin,
+	},
+	{
+		Name:  "max",
+		Value: builtinMax,
+	},
 }
 
+// builtin functions whose calls are handled by the VM
+var (
+	stringFunc = builtinFuncs[builtinFuncIndex("string")]
+	sortByFunc = builtinFuncs[builtinFuncIndex("sort_by")]
+)
+
 // GetAllBuiltinFunctions returns all builtin function objects.
 func GetAllBuiltinFunctions() []*BuiltinFunction {
 	return append([]*BuiltinFunction{}, builtinFuncs...)
 }
 
+// builtinFuncIndex returns the index of the builtin function name.
+func builtinFuncIndex(name string) int {
+	for idx, fn := range builtinFuncs {
+		if fn.Name == name {
+			return idx
+		}
+	}
+	panic("unknown builtin function: " + name)
+}
+
+// isDefaultBuiltin returns true if name is a default builtin function name.
+func isDefaultBuiltin(name string) bool {
+	for _, fn := range builtinFuncs {
+		if fn.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
 func builtinTypeName(args ...Object) (Object, error) {
 	if len(args) != 1 {
 		return nil, ErrWrongNumArguments
@@ -262,7 +369,30 @@ func builtinIsFunction(args ...Object) (Object, error) {
 		return nil, ErrWrongNumArguments
 	}
 	switch args[0].(type) {
-	case *CompiledFunction:
+	case *CompiledFunction, *BoundMethod:
+		return TrueValue, nil
+	}
+	return FalseValue, nil
+}
+
+func builtinIsRecord(args ...Object) (Object, error) {
+	argsLen := len(args)
+	if !(argsLen == 1 || argsLen == 2) {
+		return nil, ErrWrongNumArguments
+	}
+	var rt *RecordType
+	if argsLen == 2 {
+		var ok bool
+		rt, ok = args[1].(*RecordType)
+		if !ok {
+			return nil, ErrInvalidArgumentType{
+				Name:     "second",
+				Expected: "record-type",
+				Found:    args[1].TypeName(),
+			}
+		}
+	}
+	if r, ok := args[0].(*Record); ok && (rt == nil || r.Type == rt) {
 		return TrueValue, nil
 	}
 	return FalseValue, nil
@@ -278,6 +408,26 @@ func builtinIsCallable(args ...Object) (Object, error) {
 	return FalseValue, nil
 }
 
+func builtinIsSet(args ...Object) (Object, error) {
+	if len(args) != 1 {
+		return nil, ErrWrongNumArguments
+	}
+	if _, ok := args[0].(*Set); ok {
+		return TrueValue, nil
+	}
+	return FalseValue, nil
+}
+
+func builtinIsImmutableSet(args ...Object) (Object, error) {
+	if len(args) != 1 {
+		return nil, ErrWrongNumArguments
+	}
+	if _, ok := args[0].(*ImmutableSet); ok {
+		return TrueValue, nil
+	}
+	return FalseValue, nil
+}
+
 func builtinIsIterable(args ...Object) (Object, error) {
 	if len(args) != 1 {
 		return nil, ErrWrongNumArguments
@@ -303,16 +453,25 @@ func builtinLen(args ...Object) (Object, error) {
 	case *Bytes:
 		return &Int{Value: int64(len(arg.Value))}, nil
 	case *Map:
-		return &Int{Value: int64(len(arg.Value))}, nil
+		return &Int{Value: int64(len(arg.Value) + len(arg.Hashed))}, nil
+	case *Set:
+		return &Int{Value: int64(arg.Len())}, nil
+	case *ImmutableSet:
+		return &Int{Value: int64(arg.Len())}, nil
 	case *ImmutableMap:
-		return &Int{Value: int64(len(arg.Value))}, nil
-	default:
-		return nil, ErrInvalidArgumentType{
-			Name:     "first",
-			Expected: "array/string/bytes/map",
-			Found:    arg.TypeName(),
+		return &Int{Value: int64(len(arg.Value) + len(arg.Hashed))}, nil
+	case *Range:
+		return &Int{Value: arg.Len()}, nil
+	case *Proxy:
+		if n, ok := arg.length(); ok {
+			return &Int{Value: int64(n)}, nil
 		}
 	}
+	return nil, ErrInvalidArgumentType{
+		Name:     "first",
+		Expected: "array/string/bytes/map",
+		Found:    args[0].TypeName(),
+	}
 }
 
 func builtinFormat(args ...Object) (Object, error) {
@@ -339,6 +498,43 @@ func builtinFormat(args ...Object) (Object, error) {
 	return &String{Value: s}, nil
 }
 
+// set(elems ...object) => set
+func builtinSet(args ...Object) (Object, error) {
+	s, err := NewSet(args...)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// has(x set/map, elem object) => bool
+func builtinHas(args ...Object) (Object, error) {
+	if len(args) != 2 {
+		return nil, ErrWrongNumArguments
+	}
+	var ok bool
+	switch x := args[0].(type) {
+	case *Set:
+		ok = x.Has(args[1])
+	case *ImmutableSet:
+		ok = x.Has(args[1])
+	case *Map:
+		ok = mapHas(x.Value, x.Hashed, args[1])
+	case *ImmutableMap:
+		ok = mapHas(x.Value, x.Hashed, args[1])
+	default:
+		return nil, ErrInvalidArgumentType{
+			Name:     "first",
+			Expected: "set/map",
+			Found:    args[0].TypeName(),
+		}
+	}
+	if ok {
+		return TrueValue, nil
+	}
+	return FalseValue, nil
+}
+
 func builtinCopy(args ...Object) (Object, error) {
 	if len(args) != 1 {
 		return nil, ErrWrongNumArguments
@@ -403,6 +599,42 @@ func builtinFloat(args ...Object) (Object, error) {
 	return UndefinedValue, nil
 }
 
+func builtinBigInt(args ...Object) (Object, error) {
+	argsLen := len(args)
+	if !(argsLen == 1 || argsLen == 2) {
+		return nil, ErrWrongNumArguments
+	}
+	if _, ok := args[0].(*BigInt); ok {
+		return args[0], nil
+	}
+	v, ok := ToBigInt(args[0])
+	if ok {
+		return &BigInt{Value: v}, nil
+	}
+	if argsLen == 2 {
+		return args[1], nil
+	}
+	return UndefinedValue, nil
+}
+
+func builtinDecimal(args ...Object) (Object, error) {
+	argsLen := len(args)
+	if !(argsLen == 1 || argsLen == 2) {
+		return nil, ErrWrongNumArguments
+	}
+	if _, ok := args[0].(*Decimal); ok {
+		return args[0], nil
+	}
+	v, ok := ToDecimal(args[0])
+	if ok {
+		return v, nil
+	}
+	if argsLen == 2 {
+		return args[1], nil
+	}
+	return UndefinedValue, nil
+}
+
 func builtinBool(args ...Object) (Object, error) {
 	if len(args) != 1 {
 		return nil, ErrWrongNumArguments
@@ -500,3 +732,418 @@ func builtinAppend(args ...Object) (Object, error) {
 		}
 	}
 }
+
+// delete(m map, key object) / delete(s set, elem object) => undefined
+func builtinDelete(args ...Object) (Object, error) {
+	if len(args) != 2 {
+		return nil, ErrWrongNumArguments
+	}
+	if _, ok := hashKey(args[1]); !ok {
+		return nil, ErrInvalidArgumentType{
+			Name:     "second",
+			Expected: "hashable",
+			Found:    args[1].TypeName(),
+		}
+	}
+	switch arg := args[0].(type) {
+	case *Map:
+		arg.Delete(args[1])
+	case *Set:
+		arg.Remove(args[1])
+	default:
+		return nil, ErrInvalidArgumentType{
+			Name:     "first",
+			Expected: "map/set",
+			Found:    arg.TypeName(),
+		}
+	}
+	return UndefinedValue, nil
+}
+
+// splice(arr array, start int, count int, items...) => array
+func builtinSplice(args ...Object) (Object, error) {
+	argsLen := len(args)
+	if argsLen == 0 {
+		return nil, ErrWrongNumArguments
+	}
+	array, ok := args[0].(*Array)
+	if !ok {
+		return nil, ErrInvalidArgumentType{
+			Name:     "first",
+			Expected: "array",
+			Found:    args[0].TypeName(),
+		}
+	}
+	arrayLen := len(array.Value)
+
+	var start int
+	if argsLen > 1 {
+		arg1, ok := args[1].(*Int)
+		if !ok {
+			return nil, ErrInvalidArgumentType{
+				Name:     "second",
+				Expected: "int",
+				Found:    args[1].TypeName(),
+			}
+		}
+		if arg1.Value < 0 || arg1.Value > int64(arrayLen) {
+			return nil, ErrIndexOutOfBounds
+		}
+		start = int(arg1.Value)
+	}
+	count := arrayLen - start
+	if argsLen > 2 {
+		arg2, ok := args[2].(*Int)
+		if !ok {
+			return nil, ErrInvalidArgumentType{
+				Name:     "third",
+				Expected: "int",
+				Found:    args[2].TypeName(),
+			}
+		}
+		if arg2.Value < 0 {
+			return nil, ErrIndexOutOfBounds
+		}
+		if arg2.Value < int64(count) {
+			count = int(arg2.Value)
+		}
+	}
+
+	end := start + count
+	deleted := append([]Object{}, array.Value[start:end]...)
+	var items []Object
+	if argsLen > 3 {
+		items = args[3:]
+	}
+	res := make([]Object, 0, arrayLen-count+len(items))
+	res = append(res, array.Value[:start]...)
+	res = append(res, items...)
+	array.Value = append(res, array.Value[end:]...)
+	return &Array{Value: deleted}, nil
+}
+
+// keys(m map) => array
+func builtinKeys(args ...Object) (Object, error) {
+	return mapElements(args, true)
+}
+
+// values(m map) => array
+func builtinValues(args ...Object) (Object, error) {
+	return mapElements(args, false)
+}
+
+func mapElements(args []Object, keys bool) (Object, error) {
+	if len(args) != 1 {
+		return nil, ErrWrongNumArguments
+	}
+	switch arg := args[0].(type) {
+	case *Map, *ImmutableMap:
+		var elems []Object
+		for it := arg.Iterate(); it.Next(); {
+			if keys {
+				elems = append(elems, it.Key())
+			} else {
+				elems = append(elems, it.Value())
+			}
+		}
+		return &Array{Value: elems}, nil
+	default:
+		return nil, ErrInvalidArgumentType{
+			Name:     "first",
+			Expected: "map",
+			Found:    arg.TypeName(),
+		}
+	}
+}
+
+// contains(x array/string/map/set/range, elem object) => bool
+func builtinContains(args ...Object) (Object, error) {
+	if len(args) != 2 {
+		return nil, ErrWrongNumArguments
+	}
+	var ok bool
+	switch x := args[0].(type) {
+	case *Array:
+		ok = containsElem(x.Value, args[1])
+	case *ImmutableArray:
+		ok = containsElem(x.Value, args[1])
+	case *String:
+		switch elem := args[1].(type) {
+		case *String:
+			ok = strings.Contains(x.Value, elem.Value)
+		case *Char:
+			ok = strings.ContainsRune(x.Value, elem.Value)
+		default:
+			return nil, ErrInvalidArgumentType{
+				Name:     "second",
+				Expected: "string/char",
+				Found:    elem.TypeName(),
+			}
+		}
+	case *Map:
+		ok = mapHas(x.Value, x.Hashed, args[1])
+	case *ImmutableMap:
+		ok = mapHas(x.Value, x.Hashed, args[1])
+	case *Set:
+		ok = x.Has(args[1])
+	case *ImmutableSet:
+		ok = x.Has(args[1])
+	case *Range:
+		if elem, isInt := args[1].(*Int); isInt {
+			ok = x.contains(elem.Value)
+		}
+	default:
+		return nil, ErrInvalidArgumentType{
+			Name:     "first",
+			Expected: "array/string/map/set/range",
+			Found:    args[0].TypeName(),
+		}
+	}
+	if ok {
+		return TrueValue, nil
+	}
+	return FalseValue, nil
+}
+
+func containsElem(elems []Object, elem Object) bool {
+	for _, e := range elems {
+		if e.Equals(elem) {
+			return true
+		}
+	}
+	return false
+}
+
+// range(stop int) / range(start int, stop int[, step int]) => range
+func builtinRange(args ...Object) (Object, error) {
+	argsLen := len(args)
+	if argsLen < 1 || argsLen > 3 {
+		return nil, ErrWrongNumArguments
+	}
+	var v [3]int64
+	for i, arg := range args {
+		intArg, ok := arg.(*Int)
+		if !ok {
+			return nil, ErrInvalidArgumentType{
+				Name:     []string{"first", "second", "third"}[i],
+				Expected: "int",
+				Found:    arg.TypeName(),
+			}
+		}
+		v[i] = intArg.Value
+	}
+	switch argsLen {
+	case 1:
+		return &Range{Stop: v[0], Step: 1}, nil
+	case 2:
+		return &Range{Start: v[0], Stop: v[1], Step: 1}, nil
+	}
+	if v[2] == 0 {
+		return nil, errors.New("range step cannot be zero")
+	}
+	return &Range{Start: v[0], Stop: v[1], Step: v[2]}, nil
+}
+
+// sort(arr array) => array
+func builtinSort(args ...Object) (Object, error) {
+	if len(args) != 1 {
+		return nil, ErrWrongNumArguments
+	}
+	return sortElements(args[0], lessThan)
+}
+
+// sort_by(arr array, less func(a, b) => bool) => array
+func builtinSortBy(args ...Object) (Object, error) {
+	return sortBy(args, func(fn Object, args ...Object) (Object, error) {
+		// compiled functions can only be called by the VM, see
+		// VM.callSortBy
+		if _, ok := fn.(*CompiledFunction); ok {
+			return nil, ErrCompiledFunctionCall
+		}
+		return fn.Call(args...)
+	})
+}
+
+// sortBy sorts the array with a comparison function that is called with
+// call. The VM uses it to call compiled functions.
+func sortBy(
+	args []Object,
+	call func(fn Object, args ...Object) (Object, error),
+) (Object, error) {
+	if len(args) != 2 {
+		return nil, ErrWrongNumArguments
+	}
+	fn := args[1]
+	if !fn.CanCall() {
+		return nil, ErrInvalidArgumentType{
+			Name:     "second",
+			Expected: "callable",
+			Found:    fn.TypeName(),
+		}
+	}
+	return sortElements(args[0], func(a, b Object) (bool, error) {
+		res, err := call(fn, a, b)
+		if err != nil {
+			return false, err
+		}
+		return res != nil && !res.IsFalsy(), nil
+	})
+}
+
+// sortElements returns a sorted copy of the elements of an array. The sort
+// is stable, and, it stops at the first error less returns.
+func sortElements(
+	arg Object,
+	less func(a, b Object) (bool, error),
+) (Object, error) {
+	var elems []Object
+	switch arg := arg.(type) {
+	case *Array:
+		elems = arg.Value
+	case *ImmutableArray:
+		elems = arg.Value
+	default:
+		return nil, ErrInvalidArgumentType{
+			Name:     "first",
+			Expected: "array",
+			Found:    arg.TypeName(),
+		}
+	}
+	sorted := append([]Object{}, elems...)
+	var err error
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if err != nil {
+			return false
+		}
+		var res bool
+		res, err = less(sorted[i], sorted[j])
+		return res
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Array{Value: sorted}, nil
+}
+
+// lessThan returns true if a < b. Unlike the < operator, it compares strings
+// too.
+func lessThan(a, b Object) (bool, error) {
+	if x, ok := a.(*String); ok {
+		if y, ok := b.(*String); ok {
+			return x.Value < y.Value, nil
+		}
+	}
+	res, err := a.BinaryOp(token.Less, b)
+	if err == ErrInvalidOperator {
+		return false, fmt.Errorf("invalid operation: %s < %s",
+			a.TypeName(), b.TypeName())
+	}
+	if err != nil {
+		return false, err
+	}
+	return !res.IsFalsy(), nil
+}
+
+// reverse(x array/string/bytes) => array/string/bytes
+func builtinReverse(args ...Object) (Object, error) {
+	if len(args) != 1 {
+		return nil, ErrWrongNumArguments
+	}
+	switch arg := args[0].(type) {
+	case *Array:
+		return &Array{Value: reverseElements(arg.Value)}, nil
+	case *ImmutableArray:
+		return &Array{Value: reverseElements(arg.Value)}, nil
+	case *String:
+		runes := []rune(arg.Value)
+		for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+			runes[i], runes[j] = runes[j], runes[i]
+		}
+		return &String{Value: string(runes)}, nil
+	case *Bytes:
+		b := make([]byte, len(arg.Value))
+		for i, c := range arg.Value {
+			b[len(b)-1-i] = c
+		}
+		return &Bytes{Value: b}, nil
+	default:
+		return nil, ErrInvalidArgumentType{
+			Name:     "first",
+			Expected: "array/string/bytes",
+			Found:    arg.TypeName(),
+		}
+	}
+}
+
+func reverseElements(elems []Object) []Object {
+	res := make([]Object, len(elems))
+	for i, e := range elems {
+		res[len(res)-1-i] = e
+	}
+	return res
+}
+
+// min(x array/set/range) / min(values...) => object
+func builtinMin(args ...Object) (Object, error) {
+	return minMax(args, false)
+}
+
+// max(x array/set/range) / max(values...) => object
+func builtinMax(args ...Object) (Object, error) {
+	return minMax(args, true)
+}
+
+func minMax(args []Object, max bool) (Object, error) {
+	if len(args) == 0 {
+		return nil, ErrWrongNumArguments
+	}
+	elems := args
+	if len(args) == 1 {
+		switch arg := args[0].(type) {
+		case *Array:
+			elems = arg.Value
+		case *ImmutableArray:
+			elems = arg.Value
+		case *Set:
+			elems = arg.elems
+		case *ImmutableSet:
+			elems = arg.elems
+		case *Range:
+			n := arg.Len()
+			if n == 0 {
+				return UndefinedValue, nil
+			}
+			if (arg.Step > 0) == max {
+				return &Int{Value: arg.at(n - 1)}, nil
+			}
+			return &Int{Value: arg.Start}, nil
+		default:
+			return nil, ErrInvalidArgumentType{
+				Name:     "first",
+				Expected: "array/set/range",
+				Found:    arg.TypeName(),
+			}
+		}
+		if len(elems) == 0 {
+			return UndefinedValue, nil
+		}
+	}
+
+	res := elems[0]
+	for _, e := range elems[1:] {
+		var less bool
+		var err error
+		if max {
+			less, err = lessThan(res, e)
+		} else {
+			less, err = lessThan(e, res)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if less {
+			res = e
+		}
+	}
+	return res, nil
+}
@@ -14,6 +14,17 @@ type Bytecode struct {
 	FileSet      *parser.SourceFileSet
 	MainFunction *CompiledFunction
 	Constants    []Object
+
+	// Builtins is the builtin functions referenced by their indexes in the
+	// instructions. If nil, the default builtin functions are used.
+	Builtins []*BuiltinFunction
+}
+
+// builtinRef is the encoded form of a builtin function in Bytecode. Only the
+// name of the builtin function is encoded.
+type builtinRef struct {
+	Name   string
+	Custom bool
 }
 
 // Encode writes Bytecode data to the writer.
@@ -25,7 +36,18 @@ func (b *Bytecode) Encode(w io.Writer) error {
 	if err := enc.Encode(b.MainFunction); err != nil {
 		return err
 	}
-	return enc.Encode(b.Constants)
+	if err := enc.Encode(b.Constants); err != nil {
+		return err
+	}
+
+	refs := make([]builtinRef, len(b.Builtins))
+	for i, fn := range b.Builtins {
+		refs[i] = builtinRef{
+			Name:   fn.Name,
+			Custom: i >= len(builtinFuncs) || fn != builtinFuncs[i],
+		}
+	}
+	return enc.Encode(refs)
 }
 
 // CountObjects returns the number of objects found in Constants.
@@ -64,6 +86,17 @@ func (b *Bytecode) FormatConstants() (output []string) {
 
 // Decode reads Bytecode data from the reader.
 func (b *Bytecode) Decode(r io.Reader, modules *ModuleMap) error {
+	return b.DecodeWithBuiltins(r, modules, nil)
+}
+
+// DecodeWithBuiltins is like Decode but resolves the custom builtin functions
+// of the bytecode by their names from builtins. It returns an error if a
+// custom builtin function is not found in builtins.
+func (b *Bytecode) DecodeWithBuiltins(
+	r io.Reader,
+	modules *ModuleMap,
+	builtins []*BuiltinFunction,
+) error {
 	if modules == nil {
 		modules = NewModuleMap()
 	}
@@ -88,9 +121,49 @@ func (b *Bytecode) Decode(r io.Reader, modules *ModuleMap) error {
 		}
 		b.Constants[i] = fv
 	}
+
+	var refs []builtinRef
+	if err := dec.Decode(&refs); err != nil {
+		if err == io.EOF {
+			// encoded before the builtin functions were stored
+			b.Builtins = nil
+			return nil
+		}
+		return err
+	}
+	b.Builtins = nil
+	if len(refs) > 0 {
+		b.Builtins = make([]*BuiltinFunction, len(refs))
+	}
+	for i, ref := range refs {
+		fn, err := resolveBuiltin(ref, i, builtins)
+		if err != nil {
+			return err
+		}
+		b.Builtins[i] = fn
+	}
 	return nil
 }
 
+func resolveBuiltin(
+	ref builtinRef,
+	index int,
+	builtins []*BuiltinFunction,
+) (*BuiltinFunction, error) {
+	if !ref.Custom {
+		if index < len(builtinFuncs) && builtinFuncs[index].Name == ref.Name {
+			return builtinFuncs[index], nil
+		}
+		return nil, fmt.Errorf("builtin function not found: %s", ref.Name)
+	}
+	for _, fn := range builtins {
+		if fn.Name == ref.Name {
+			return fn, nil
+		}
+	}
+	return nil, fmt.Errorf("custom builtin function not found: %s", ref.Name)
+}
+
 // RemoveDuplicates finds and remove the duplicate values in Constants.
 // Note this function mutates Bytecode.
 func (b *Bytecode) RemoveDuplicates() {
@@ -221,7 +294,8 @@ func fixDecodedObject(
 
 		for k, v := range o.Value {
 			// encoding of user function not supported
-			if _, isUserFunction := v.(*UserFunction); isUserFunction {
+			switch v.(type) {
+			case *UserFunction, *UserContextFunction:
 				return nil, fmt.Errorf("user function not decodable")
 			}
 
@@ -275,10 +349,12 @@ func init() {
 	gob.Register(&parser.SourceFileSet{})
 	gob.Register(&parser.SourceFile{})
 	gob.Register(&Array{})
+	gob.Register(&BigInt{})
 	gob.Register(&Bool{})
 	gob.Register(&Bytes{})
 	gob.Register(&Char{})
 	gob.Register(&CompiledFunction{})
+	gob.Register(&Decimal{})
 	gob.Register(&Error{})
 	gob.Register(&Float{})
 	gob.Register(&ImmutableArray{})
@@ -288,5 +364,6 @@ func init() {
 	gob.Register(&String{})
 	gob.Register(&Time{})
 	gob.Register(&Undefined{})
+	gob.Register(&UserContextFunction{})
 	gob.Register(&UserFunction{})
 }
@@ -252,6 +252,48 @@ func TestBytecode_CountObjects(t *testing.T) {
 	require.Equal(t, 7, b.CountObjects())
 }
 
+func TestBytecode_Builtins(t *testing.T) {
+	answer := &tengo.BuiltinFunction{
+		Name: "answer",
+		Value: func(args ...tengo.Object) (tengo.Object, error) {
+			return &tengo.Int{Value: 42}, nil
+		},
+	}
+
+	src := []byte(`out := answer() + len([1, 2])`)
+	fileSet := parser.NewFileSet()
+	file := fileSet.AddFile("test", -1, len(src))
+	p := parser.NewParser(file, src, nil)
+	f, err := p.ParseFile()
+	require.NoError(t, err)
+	symTable := tengo.NewSymbolTable()
+	c := tengo.NewCompiler(file, symTable, nil, nil, nil)
+	c.SetBuiltin(answer)
+	require.NoError(t, c.Compile(f))
+	b := c.Bytecode()
+
+	var buf bytes.Buffer
+	require.NoError(t, b.Encode(&buf))
+
+	// custom builtin functions are not encoded
+	r := &tengo.Bytecode{}
+	err = r.Decode(bytes.NewReader(buf.Bytes()), nil)
+	require.Error(t, err)
+	require.Equal(t, "custom builtin function not found: answer", err.Error())
+
+	r = &tengo.Bytecode{}
+	err = r.DecodeWithBuiltins(bytes.NewReader(buf.Bytes()), nil,
+		[]*tengo.BuiltinFunction{answer})
+	require.NoError(t, err)
+	require.Equal(t, len(b.Builtins), len(r.Builtins))
+
+	globals := make([]tengo.Object, tengo.GlobalsSize)
+	v := tengo.NewVM(r, globals, -1)
+	require.NoError(t, v.Run())
+	symbol, _, _ := symTable.Resolve("out")
+	require.Equal(t, int64(44), globals[symbol.Index].(*tengo.Int).Value)
+}
+
 func fileSet(files ...srcfile) *parser.SourceFileSet {
 	fileSet := parser.NewFileSet()
 	for _, f := range files {
@@ -0,0 +1,296 @@
+package tengo
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"sort"
+	"sync"
+)
+
+// CacheStore is a persistent storage of the compiled scripts used by
+// CompileCache. The data is the encoded bytecode of the compiled script.
+type CacheStore interface {
+	// Load returns the data stored with the key, or, nil if the key is not
+	// found.
+	Load(key string) ([]byte, error)
+
+	// Store stores the data with the key.
+	Store(key string, data []byte) error
+}
+
+// CompileCache caches the compiled scripts so that the same scripts are not
+// parsed and compiled repeatedly. The scripts are identified by their source,
+// the names of their variables, their module map and their options. It keeps
+// the most recently used compiled scripts in memory, and, optionally stores
+// them in a CacheStore. CompileCache is safe for concurrent use.
+type CompileCache struct {
+	size    int
+	store   CacheStore
+	entries map[cacheKey]*list.Element
+	lru     *list.List // most recently used first
+	lock    sync.Mutex
+}
+
+type cacheKey struct {
+	hash    string
+	modules *ModuleMap
+}
+
+type cacheEntry struct {
+	key      cacheKey
+	compiled *Compiled
+}
+
+// encodedCompiled is the encoded form of a compiled script in CacheStore.
+type encodedCompiled struct {
+	GlobalIndexes map[string]int
+	NumGlobals    int
+	Bytecode      []byte
+}
+
+// NewCompileCache creates a CompileCache that keeps up to size compiled
+// scripts in memory. The size is unlimited if size <= 0. store can be nil.
+func NewCompileCache(size int, store CacheStore) *CompileCache {
+	return &CompileCache{
+		size:    size,
+		store:   store,
+		entries: make(map[cacheKey]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// Compile is like Script.Compile but returns a copy of the cached compiled
+// script if the same script was compiled before. The variables of the
+// returned Compiled have the current values of the script variables.
+func (cc *CompileCache) Compile(s *Script) (*Compiled, error) {
+	key := cacheKey{hash: s.cacheHash(), modules: s.modules}
+	if c := cc.get(key); c != nil {
+		return s.fromCache(c), nil
+	}
+
+	c, err := cc.load(s, key.hash)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		if c, err = s.Compile(); err != nil {
+			return nil, err
+		}
+		// the cached script does not keep the variable values alive
+		for i := range c.globals {
+			c.globals[i] = nil
+		}
+		if err := cc.save(key.hash, c); err != nil {
+			return nil, err
+		}
+	}
+	cc.add(key, c)
+	return s.fromCache(c), nil
+}
+
+// Len returns the number of the compiled scripts in memory.
+func (cc *CompileCache) Len() int {
+	cc.lock.Lock()
+	defer cc.lock.Unlock()
+
+	return cc.lru.Len()
+}
+
+// Purge removes all the compiled scripts from memory. It does not affect
+// the CacheStore.
+func (cc *CompileCache) Purge() {
+	cc.lock.Lock()
+	defer cc.lock.Unlock()
+
+	cc.entries = make(map[cacheKey]*list.Element)
+	cc.lru.Init()
+}
+
+func (cc *CompileCache) get(key cacheKey) *Compiled {
+	cc.lock.Lock()
+	defer cc.lock.Unlock()
+
+	e, ok := cc.entries[key]
+	if !ok {
+		return nil
+	}
+	cc.lru.MoveToFront(e)
+	return e.Value.(*cacheEntry).compiled
+}
+
+func (cc *CompileCache) add(key cacheKey, c *Compiled) {
+	cc.lock.Lock()
+	defer cc.lock.Unlock()
+
+	if e, ok := cc.entries[key]; ok {
+		// compiled concurrently
+		e.Value.(*cacheEntry).compiled = c
+		cc.lru.MoveToFront(e)
+		return
+	}
+	cc.entries[key] = cc.lru.PushFront(&cacheEntry{key: key, compiled: c})
+	for cc.size > 0 && cc.lru.Len() > cc.size {
+		e := cc.lru.Back()
+		cc.lru.Remove(e)
+		delete(cc.entries, e.Value.(*cacheEntry).key)
+	}
+}
+
+// load decodes the compiled script from the store. It returns nil if the
+// script is not found or cannot be decoded, e.g. if it was encoded by an
+// incompatible version.
+func (cc *CompileCache) load(s *Script, key string) (*Compiled, error) {
+	if cc.store == nil {
+		return nil, nil
+	}
+	data, err := cc.store.Load(key)
+	if err != nil || data == nil {
+		return nil, err
+	}
+
+	var enc encodedCompiled
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&enc); err != nil {
+		return nil, nil
+	}
+	var builtins []*BuiltinFunction
+	for _, fn := range s.builtins {
+		if fn != nil {
+			builtins = append(builtins, fn)
+		}
+	}
+	bytecode := &Bytecode{}
+	err = bytecode.DecodeWithBuiltins(bytes.NewReader(enc.Bytecode),
+		s.modules, builtins)
+	if err != nil {
+		return nil, nil
+	}
+	return &Compiled{
+		globalIndexes: enc.GlobalIndexes,
+		bytecode:      bytecode,
+		globals:       make([]Object, enc.NumGlobals),
+		maxAllocs:     s.maxAllocs,
+		overflowCheck: s.overflowCheck,
+		pool:          newVMPool(bytecode, s.maxAllocs, s.overflowCheck),
+	}, nil
+}
+
+func (cc *CompileCache) save(key string, c *Compiled) error {
+	if cc.store == nil {
+		return nil
+	}
+	var bc bytes.Buffer
+	if err := c.bytecode.Encode(&bc); err != nil {
+		return err
+	}
+	var data bytes.Buffer
+	err := gob.NewEncoder(&data).Encode(&encodedCompiled{
+		GlobalIndexes: c.globalIndexes,
+		NumGlobals:    len(c.globals),
+		Bytecode:      bc.Bytes(),
+	})
+	if err != nil {
+		return err
+	}
+	return cc.store.Store(key, data.Bytes())
+}
+
+// cacheHash returns the hash of the source, the variable names, the modules
+// and the options of the script.
+func (s *Script) cacheHash() string {
+	h := sha256.New()
+	writeHash(h, s.input)
+
+	var names []string
+	for name := range s.variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	writeHash(h, names)
+
+	names = names[:0]
+	for name := range s.builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		writeHash(h, name, s.builtins[name] != nil)
+	}
+
+	if s.modules != nil {
+		names = names[:0]
+		for name := range s.modules.m {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			switch mod := s.modules.m[name].(type) {
+			case *SourceModule:
+				writeHash(h, name, mod.Src)
+			case *BuiltinModule:
+				var attrs []string
+				for attr := range mod.Attrs {
+					attrs = append(attrs, attr)
+				}
+				sort.Strings(attrs)
+				writeHash(h, name, attrs)
+			default:
+				writeHash(h, name, fmt.Sprintf("%T", mod))
+			}
+		}
+	}
+
+	writeHash(h, s.maxAllocs, s.maxConstObjects, s.enableFileImport,
+		s.overflowCheck)
+	if s.policy != nil {
+		writeHash(h, *s.policy)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func writeHash(h hash.Hash, values ...interface{}) {
+	for _, v := range values {
+		if b, ok := v.([]byte); ok {
+			_, _ = fmt.Fprintf(h, "%d:", len(b))
+			_, _ = h.Write(b)
+			continue
+		}
+		_, _ = fmt.Fprintf(h, "%#v\n", v)
+	}
+}
+
+// fromCache returns a copy of the cached compiled script c with the current
+// values of the script variables and the current builtin functions of the
+// script.
+func (s *Script) fromCache(c *Compiled) *Compiled {
+	clone := c.Clone()
+	for name, v := range s.variables {
+		clone.globals[clone.globalIndexes[name]] = v.value
+	}
+
+	var builtins []*BuiltinFunction
+	for i, fn := range c.bytecode.Builtins {
+		if i < len(builtinFuncs) && fn == builtinFuncs[i] {
+			continue
+		}
+		if cur := s.builtins[fn.Name]; cur != nil && cur != fn {
+			if builtins == nil {
+				builtins = make([]*BuiltinFunction, len(c.bytecode.Builtins))
+				copy(builtins, c.bytecode.Builtins)
+			}
+			builtins[i] = cur
+		}
+	}
+	if builtins != nil {
+		bytecode := *c.bytecode
+		bytecode.Builtins = builtins
+		clone.bytecode = &bytecode
+		clone.pool = newVMPool(&bytecode, clone.maxAllocs,
+			clone.overflowCheck)
+	}
+	return clone
+}
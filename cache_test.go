@@ -0,0 +1,185 @@
+package tengo_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/d5/tengo/v2"
+	"github.com/d5/tengo/v2/require"
+	"github.com/d5/tengo/v2/stdlib"
+)
+
+type mapCacheStore struct {
+	data   map[string][]byte
+	loads  int
+	stores int
+	lock   sync.Mutex
+}
+
+func (s *mapCacheStore) Load(key string) ([]byte, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.loads++
+	return s.data[key], nil
+}
+
+func (s *mapCacheStore) Store(key string, data []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.stores++
+	s.data[key] = data
+	return nil
+}
+
+func TestCompileCache(t *testing.T) {
+	cache := tengo.NewCompileCache(2, nil)
+	compile := func(src string, vars M) *tengo.Compiled {
+		s := tengo.NewScript([]byte(src))
+		for name, value := range vars {
+			require.NoError(t, s.Add(name, value))
+		}
+		c, err := cache.Compile(s)
+		require.NoError(t, err)
+		return c
+	}
+
+	c1 := compile(`out := a * 2`, M{"a": 1})
+	c2 := compile(`out := a * 2`, M{"a": 2})
+	require.Equal(t, 1, cache.Len())
+	compiledRun(t, c2)
+	compiledGet(t, c2, "out", int64(4))
+	compiledRun(t, c1)
+	compiledGet(t, c1, "out", int64(2))
+	compiledGet(t, c2, "out", int64(4))
+
+	// different variable names
+	c := compile(`out := a * 2`, M{"a": 3, "b": 4})
+	require.Equal(t, 2, cache.Len())
+	compiledRun(t, c)
+	compiledGet(t, c, "out", int64(6))
+
+	// least recently used is removed
+	compile(`out := a * 2`, M{"a": 1})
+	compile(`out := a * 3`, M{"a": 1})
+	require.Equal(t, 2, cache.Len())
+	compile(`out := a * 2`, M{"a": 1})
+	require.Equal(t, 2, cache.Len())
+	cache.Purge()
+	require.Equal(t, 0, cache.Len())
+
+	// compile error is not cached
+	s := tengo.NewScript([]byte(`out := a * 2`))
+	_, err := cache.Compile(s)
+	require.Error(t, err)
+	require.Equal(t, 0, cache.Len())
+
+	// module maps and options
+	s = tengo.NewScript([]byte(`out := import("mod").a`))
+	mods := tengo.NewModuleMap()
+	mods.AddSourceModule("mod", []byte(`export {a: 1}`))
+	s.SetImports(mods)
+	c, err = cache.Compile(s)
+	require.NoError(t, err)
+	compiledRun(t, c)
+	compiledGet(t, c, "out", int64(1))
+	mods = tengo.NewModuleMap()
+	mods.AddSourceModule("mod", []byte(`export {a: 2}`))
+	s.SetImports(mods)
+	c, err = cache.Compile(s)
+	require.NoError(t, err)
+	compiledRun(t, c)
+	compiledGet(t, c, "out", int64(2))
+	s.SetMaxAllocs(1)
+	c, err = cache.Compile(s)
+	require.NoError(t, err)
+	require.Error(t, c.Run())
+	require.Equal(t, 2, cache.Len())
+
+	// custom builtin functions
+	cache.Purge()
+	for i := int64(1); i <= 3; i++ {
+		n := i
+		s := tengo.NewScript([]byte(`out := f()`))
+		s.SetBuiltin("f", func(args ...tengo.Object) (tengo.Object, error) {
+			return &tengo.Int{Value: n}, nil
+		})
+		c, err := cache.Compile(s)
+		require.NoError(t, err)
+		compiledRun(t, c)
+		compiledGet(t, c, "out", n)
+	}
+	require.Equal(t, 1, cache.Len())
+}
+
+func TestCompileCache_Store(t *testing.T) {
+	store := &mapCacheStore{data: make(map[string][]byte)}
+	mods := stdlib.GetModuleMap("text")
+	newScript := func(a int) *tengo.Script {
+		s := tengo.NewScript([]byte(`
+text := import("text")
+out := text.repeat("x", a) + f()`))
+		s.SetImports(mods)
+		s.SetBuiltin("f", func(args ...tengo.Object) (tengo.Object, error) {
+			return &tengo.String{Value: "!"}, nil
+		})
+		require.NoError(t, s.Add("a", a))
+		return s
+	}
+
+	cache := tengo.NewCompileCache(10, store)
+	c, err := cache.Compile(newScript(2))
+	require.NoError(t, err)
+	compiledRun(t, c)
+	compiledGet(t, c, "out", "xx!")
+	require.Equal(t, 1, store.loads)
+	require.Equal(t, 1, store.stores)
+
+	// loaded from the store
+	cache = tengo.NewCompileCache(10, store)
+	c, err = cache.Compile(newScript(3))
+	require.NoError(t, err)
+	compiledRun(t, c)
+	compiledGet(t, c, "out", "xxx!")
+	require.Equal(t, 2, store.loads)
+	require.Equal(t, 1, store.stores)
+
+	// in memory
+	c, err = cache.Compile(newScript(1))
+	require.NoError(t, err)
+	compiledRun(t, c)
+	compiledGet(t, c, "out", "x!")
+	require.Equal(t, 2, store.loads)
+
+	// invalid data is compiled again
+	for key := range store.data {
+		store.data[key] = []byte("foo")
+	}
+	cache.Purge()
+	c, err = cache.Compile(newScript(1))
+	require.NoError(t, err)
+	compiledRun(t, c)
+	compiledGet(t, c, "out", "x!")
+	require.Equal(t, 2, store.stores)
+}
+
+func TestCompileCache_Concurrency(t *testing.T) {
+	cache := tengo.NewCompileCache(2, nil)
+	srcs := []string{`out := a * 2`, `out := a * 3`, `out := a * 4`}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			n := i%len(srcs) + 2
+			s := tengo.NewScript([]byte(srcs[i%len(srcs)]))
+			require.NoError(t, s.Add("a", i))
+			c, err := cache.Compile(s)
+			require.NoError(t, err)
+			require.NoError(t, c.Run())
+			require.Equal(t, int64(i*n), c.Get("out").Int64())
+		}(i)
+	}
+	wg.Wait()
+	require.Equal(t, 2, cache.Len())
+}
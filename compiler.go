@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
 	"path/filepath"
 	"reflect"
 	"strings"
@@ -23,6 +24,7 @@ type compilationScope struct {
 // loop represents a loop construct that the compiler uses to track the current
 // loop.
 type loop struct {
+	Label     string
 	Continues []int
 	Breaks    []int
 }
@@ -49,6 +51,8 @@ type Compiler struct {
 	scopes          []compilationScope
 	scopeIndex      int
 	modules         *ModuleMap
+	builtins        []*BuiltinFunction
+	policy          *Policy
 	compiledModules map[string]*CompiledFunction
 	allowFileImport bool
 	loops           []*loop
@@ -65,11 +69,6 @@ func NewCompiler(
 	modules *ModuleMap,
 	trace io.Writer,
 ) *Compiler {
-	mainScope := compilationScope{
-		SymbolInit: make(map[string]bool),
-		SourceMap:  make(map[int]parser.Pos),
-	}
-
 	// symbol table
 	if symbolTable == nil {
 		symbolTable = NewSymbolTable()
@@ -79,6 +78,20 @@ func NewCompiler(
 	for idx, fn := range builtinFuncs {
 		symbolTable.DefineBuiltin(idx, fn.Name)
 	}
+	return newCompiler(file, symbolTable, constants, modules, trace)
+}
+
+func newCompiler(
+	file *parser.SourceFile,
+	symbolTable *SymbolTable,
+	constants []Object,
+	modules *ModuleMap,
+	trace io.Writer,
+) *Compiler {
+	mainScope := compilationScope{
+		SymbolInit: make(map[string]bool),
+		SourceMap:  make(map[int]parser.Pos),
+	}
 
 	// builtin modules
 	if modules == nil {
@@ -111,6 +124,10 @@ func (c *Compiler) Compile(node parser.Node) error {
 
 	switch node := node.(type) {
 	case *parser.File:
+		if len(c.builtins) > MaxBuiltins {
+			return c.errorf(node, "too many builtin functions: %d > %d",
+				len(c.builtins), MaxBuiltins)
+		}
 		for _, stmt := range node.Stmts {
 			if err := c.Compile(stmt); err != nil {
 				return err
@@ -133,7 +150,8 @@ func (c *Compiler) Compile(node parser.Node) error {
 			return err
 		}
 	case *parser.BinaryExpr:
-		if node.Token == token.LAnd || node.Token == token.LOr {
+		if node.Token == token.LAnd || node.Token == token.LOr ||
+			node.Token == token.Coalesce {
 			return c.compileLogical(node)
 		}
 		if node.Token == token.Less {
@@ -193,6 +211,8 @@ func (c *Compiler) Compile(node parser.Node) error {
 			c.emit(node, parser.OpBinaryOp, int(token.Shl))
 		case token.Shr:
 			c.emit(node, parser.OpBinaryOp, int(token.Shr))
+		case token.Pow:
+			c.emit(node, parser.OpBinaryOp, int(token.Pow))
 		default:
 			return c.errorf(node, "invalid binary operator: %s",
 				node.Token.String())
@@ -215,6 +235,23 @@ func (c *Compiler) Compile(node parser.Node) error {
 		}
 		c.emit(node, parser.OpConstant,
 			c.addConstant(&String{Value: node.Value}))
+	case *parser.InterpStringLit:
+		// f"a${b}c" is compiled as: "a" + b + "c"
+		parts := node.Parts
+		if len(parts) == 0 {
+			parts = []parser.Expr{&parser.StringLit{}}
+		} else if _, ok := parts[0].(*parser.StringLit); !ok {
+			// string concatenation needs a string on the left side
+			parts = append([]parser.Expr{&parser.StringLit{}}, parts...)
+		}
+		for i, part := range parts {
+			if err := c.Compile(part); err != nil {
+				return err
+			}
+			if i > 0 {
+				c.emit(node, parser.OpBinaryOp, int(token.Add))
+			}
+		}
 	case *parser.CharLit:
 		c.emit(node, parser.OpConstant,
 			c.addConstant(&Char{Value: node.Value}))
@@ -279,15 +316,24 @@ func (c *Compiler) Compile(node parser.Node) error {
 			c.changeOperand(jumpPos1, curPos)
 		}
 	case *parser.ForStmt:
-		return c.compileForStmt(node)
+		return c.compileForStmt(node, "")
 	case *parser.ForInStmt:
-		return c.compileForInStmt(node)
+		return c.compileForInStmt(node, "")
+	case *parser.LabeledStmt:
+		return c.compileLabeledStmt(node)
 	case *parser.BranchStmt:
 		if node.Token == token.Break {
 			curLoop := c.currentLoop()
 			if curLoop == nil {
 				return c.errorf(node, "break not allowed outside loop")
 			}
+			if node.Label != nil {
+				curLoop = c.labeledLoop(node.Label.Name)
+				if curLoop == nil {
+					return c.errorf(node, "break label '%s' not defined",
+						node.Label.Name)
+				}
+			}
 			pos := c.emit(node, parser.OpJump, 0)
 			curLoop.Breaks = append(curLoop.Breaks, pos)
 		} else if node.Token == token.Continue {
@@ -295,6 +341,13 @@ func (c *Compiler) Compile(node parser.Node) error {
 			if curLoop == nil {
 				return c.errorf(node, "continue not allowed outside loop")
 			}
+			if node.Label != nil {
+				curLoop = c.labeledLoop(node.Label.Name)
+				if curLoop == nil {
+					return c.errorf(node, "continue label '%s' not defined",
+						node.Label.Name)
+				}
+			}
 			pos := c.emit(node, parser.OpJump, 0)
 			curLoop.Continues = append(curLoop.Continues, pos)
 		} else {
@@ -333,17 +386,30 @@ func (c *Compiler) Compile(node parser.Node) error {
 		case ScopeLocal:
 			c.emit(node, parser.OpGetLocal, symbol.Index)
 		case ScopeBuiltin:
+			if !c.policy.allowsBuiltin(node.Name) {
+				return c.errorf(node, "builtin function '%s' not allowed",
+					node.Name)
+			}
 			c.emit(node, parser.OpGetBuiltin, symbol.Index)
 		case ScopeFree:
 			c.emit(node, parser.OpGetFree, symbol.Index)
 		}
 	case *parser.ArrayLit:
 		for _, elem := range node.Elements {
+			if _, ok := elem.(*parser.SpreadExpr); ok {
+				return c.errorf(elem,
+					"'...' not allowed outside destructuring assignment")
+			}
 			if err := c.Compile(elem); err != nil {
 				return err
 			}
 		}
 		c.emit(node, parser.OpArray, len(node.Elements))
+	case *parser.ArrayComprehension:
+		return c.compileComprehension(node, nil, node.Value, node.Clauses)
+	case *parser.MapComprehension:
+		return c.compileComprehension(node, node.Key, node.Value,
+			node.Clauses)
 	case *parser.MapLit:
 		for _, elt := range node.Elements {
 			// key
@@ -360,41 +426,14 @@ func (c *Compiler) Compile(node parser.Node) error {
 		}
 		c.emit(node, parser.OpMap, len(node.Elements)*2)
 
+	case *parser.RecordLit:
+		return c.compileRecordLit(node, "record")
 	case *parser.SelectorExpr: // selector on RHS side
-		if err := c.Compile(node.Expr); err != nil {
-			return err
-		}
-		if err := c.Compile(node.Sel); err != nil {
-			return err
-		}
-		c.emit(node, parser.OpIndex)
+		return c.compileChain(node)
 	case *parser.IndexExpr:
-		if err := c.Compile(node.Expr); err != nil {
-			return err
-		}
-		if err := c.Compile(node.Index); err != nil {
-			return err
-		}
-		c.emit(node, parser.OpIndex)
+		return c.compileChain(node)
 	case *parser.SliceExpr:
-		if err := c.Compile(node.Expr); err != nil {
-			return err
-		}
-		if node.Low != nil {
-			if err := c.Compile(node.Low); err != nil {
-				return err
-			}
-		} else {
-			c.emit(node, parser.OpNull)
-		}
-		if node.High != nil {
-			if err := c.Compile(node.High); err != nil {
-				return err
-			}
-		} else {
-			c.emit(node, parser.OpNull)
-		}
-		c.emit(node, parser.OpSliceIndex)
+		return c.compileChain(node)
 	case *parser.FuncLit:
 		c.enterScope()
 
@@ -405,7 +444,12 @@ func (c *Compiler) Compile(node parser.Node) error {
 			s.LocalAssigned = true
 		}
 
-		if err := c.Compile(node.Body); err != nil {
+		// loops of the enclosing function cannot be broken from the body
+		loops, loopIndex := c.loops, c.loopIndex
+		c.loops, c.loopIndex = nil, -1
+		err := c.Compile(node.Body)
+		c.loops, c.loopIndex = loops, loopIndex
+		if err != nil {
 			return err
 		}
 
@@ -469,6 +513,11 @@ func (c *Compiler) Compile(node parser.Node) error {
 			}
 		}
 
+		if len(freeSymbols) > 0 && c.policy != nil &&
+			c.policy.DisallowClosures {
+			return c.errorf(node, "closure not allowed")
+		}
+
 		compiledFunction := &CompiledFunction{
 			Instructions:  instructions,
 			NumLocals:     numLocals,
@@ -497,19 +546,14 @@ func (c *Compiler) Compile(node parser.Node) error {
 			c.emit(node, parser.OpReturn, 1)
 		}
 	case *parser.CallExpr:
-		if err := c.Compile(node.Func); err != nil {
-			return err
-		}
-		for _, arg := range node.Args {
-			if err := c.Compile(arg); err != nil {
-				return err
-			}
-		}
-		c.emit(node, parser.OpCall, len(node.Args))
+		return c.compileChain(node)
 	case *parser.ImportExpr:
 		if node.ModuleName == "" {
 			return c.errorf(node, "empty module name")
 		}
+		if !c.policy.allowsModule(node.ModuleName) {
+			return c.errorf(node, "module '%s' not allowed", node.ModuleName)
+		}
 
 		if mod := c.modules.Get(node.ModuleName); mod != nil {
 			v, err := mod.Import(node.ModuleName)
@@ -532,6 +576,10 @@ func (c *Compiler) Compile(node parser.Node) error {
 				panic(fmt.Errorf("invalid import value type: %T", v))
 			}
 		} else if c.allowFileImport {
+			if c.policy != nil && c.policy.DisallowFileImport {
+				return c.errorf(node,
+					"file import is not allowed by the policy")
+			}
 			moduleName := node.ModuleName
 			if !strings.HasSuffix(moduleName, ".tengo") {
 				moduleName += ".tengo"
@@ -625,7 +673,47 @@ func (c *Compiler) Bytecode() *Bytecode {
 			SourceMap:    c.currentSourceMap(),
 		},
 		Constants: c.constants,
+		Builtins:  c.builtins,
+	}
+}
+
+// SetBuiltin adds a builtin function to the compiler, or, replaces the
+// builtin function of the same name. The builtin functions must be set before
+// the compilation.
+func (c *Compiler) SetBuiltin(fn *BuiltinFunction) {
+	if c.builtins == nil {
+		c.builtins = builtinFuncs
+	}
+	// the builtin functions are copied as they can be shared by the other
+	// compilers, and, a replaced builtin function keeps its slot
+	builtins := make([]*BuiltinFunction, len(c.builtins), len(c.builtins)+1)
+	copy(builtins, c.builtins)
+	idx := len(builtins)
+	for i, b := range builtins {
+		if b.Name == fn.Name {
+			idx = i
+			break
+		}
+	}
+	if idx == len(builtins) {
+		builtins = append(builtins, fn)
+	} else {
+		builtins[idx] = fn
 	}
+	c.builtins = builtins
+	c.symbolTable.DefineBuiltin(idx, fn.Name)
+}
+
+// RemoveBuiltin removes the builtin function from the compiler. It returns
+// false if the builtin function name is not defined.
+func (c *Compiler) RemoveBuiltin(name string) bool {
+	return c.symbolTable.RemoveBuiltin(name)
+}
+
+// SetPolicy sets the policy that restricts the capabilities of the compiled
+// code. It must be set before the compilation.
+func (c *Compiler) SetPolicy(policy *Policy) {
+	c.policy = policy
 }
 
 // EnableFileImport enables or disables module loading from local files.
@@ -644,6 +732,16 @@ func (c *Compiler) compileAssign(
 		return c.errorf(node, "tuple assignment not allowed")
 	}
 
+	// destructuring assignment
+	switch lhs[0].(type) {
+	case *parser.ArrayLit, *parser.MapLit:
+		return c.compileDestructuring(node, lhs[0], rhs[0], op)
+	}
+
+	if isOptionalChain(lhs[0]) {
+		return c.errorf(node, "optional chaining not allowed in assignment")
+	}
+
 	// resolve and compile left-hand side
 	ident, selectors := resolveAssignLHS(lhs[0])
 	numSel := len(selectors)
@@ -674,6 +772,13 @@ func (c *Compiler) compileAssign(
 
 	// compile RHSs
 	for _, expr := range rhs {
+		if rec, ok := expr.(*parser.RecordLit); ok && numSel == 0 {
+			// record type is named after the variable
+			if err := c.compileRecordLit(rec, ident); err != nil {
+				return err
+			}
+			continue
+		}
 		if err := c.Compile(expr); err != nil {
 			return err
 		}
@@ -702,6 +807,8 @@ func (c *Compiler) compileAssign(
 		c.emit(node, parser.OpBinaryOp, int(token.Shl))
 	case token.ShrAssign:
 		c.emit(node, parser.OpBinaryOp, int(token.Shr))
+	case token.PowAssign:
+		c.emit(node, parser.OpBinaryOp, int(token.Pow))
 	}
 
 	// compile selector expressions (right to left)
@@ -744,6 +851,301 @@ func (c *Compiler) compileAssign(
 	return nil
 }
 
+func (c *Compiler) compileDestructuring(
+	node parser.Node,
+	pattern, rhs parser.Expr,
+	op token.Token,
+) error {
+	if op != token.Assign && op != token.Define {
+		return c.errorf(node, "operator '%s' not allowed with destructuring",
+			op.String())
+	}
+
+	// new variables are defined up front so that the hidden temporary
+	// variables of (nested) patterns do not share their indexes.
+	if op == token.Define {
+		defined := make(map[string]bool)
+		if err := c.definePatternSymbols(node, pattern, defined); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Compile(rhs); err != nil {
+		return err
+	}
+	return c.destructure(node, pattern, op)
+}
+
+func (c *Compiler) definePatternSymbols(
+	node parser.Node,
+	pattern parser.Expr,
+	defined map[string]bool,
+) error {
+	var targets []parser.Expr
+	switch pattern := pattern.(type) {
+	case *parser.ArrayLit:
+		targets = pattern.Elements
+	case *parser.MapLit:
+		for _, elt := range pattern.Elements {
+			targets = append(targets, elt.Value)
+		}
+	}
+
+	for _, target := range targets {
+		if spread, ok := target.(*parser.SpreadExpr); ok {
+			target = spread.Expr
+		}
+		switch target := target.(type) {
+		case *parser.ArrayLit, *parser.MapLit:
+			if err := c.definePatternSymbols(node, target, defined); err != nil {
+				return err
+			}
+		case *parser.Ident:
+			if target.Name == "_" {
+				continue
+			}
+			_, depth, exists := c.symbolTable.Resolve(target.Name)
+			if defined[target.Name] || (depth == 0 && exists) {
+				return c.errorf(node, "'%s' redeclared in this block",
+					target.Name)
+			}
+			c.symbolTable.Define(target.Name)
+			defined[target.Name] = true
+		case *parser.SelectorExpr, *parser.IndexExpr:
+			return c.errorf(node, "operator ':=' not allowed with selector")
+		default:
+			return c.errorf(target, "invalid destructuring target: %s",
+				target.String())
+		}
+	}
+	return nil
+}
+
+// destructure assigns the elements of the value on top of the stack to the
+// targets of the pattern. It's compiled like following:
+//
+//	:d := value
+//	a := :d[0]
+//	b := :d[1]
+//	rest := :d[2:]
+//
+// ":d" is a local variable of a block scope, so nested patterns can safely
+// shadow it.
+func (c *Compiler) destructure(
+	node parser.Node,
+	pattern parser.Expr,
+	op token.Token,
+) error {
+	c.symbolTable = c.symbolTable.Fork(true)
+	defer func() {
+		c.symbolTable = c.symbolTable.Parent(false)
+	}()
+
+	tmpSymbol := c.symbolTable.Define(":d")
+	if tmpSymbol.Scope == ScopeGlobal {
+		c.emit(node, parser.OpSetGlobal, tmpSymbol.Index)
+	} else {
+		c.emit(node, parser.OpDefineLocal, tmpSymbol.Index)
+		tmpSymbol.LocalAssigned = true
+	}
+	tmp := &parser.Ident{Name: ":d", NamePos: pattern.Pos()}
+
+	switch pattern := pattern.(type) {
+	case *parser.ArrayLit:
+		for idx, elem := range pattern.Elements {
+			var value parser.Expr = &parser.IndexExpr{
+				Expr:  tmp,
+				Index: &parser.IntLit{Value: int64(idx)},
+			}
+			if spread, ok := elem.(*parser.SpreadExpr); ok {
+				if idx != len(pattern.Elements)-1 {
+					return c.errorf(elem,
+						"rest element must be last in destructuring")
+				}
+				elem = spread.Expr
+				// explicit high index so that a rest element past the
+				// end of the value results in an empty array.
+				value = &parser.SliceExpr{
+					Expr: tmp,
+					Low:  &parser.IntLit{Value: int64(idx)},
+					High: &parser.IntLit{Value: math.MaxInt64},
+				}
+			}
+			if err := c.destructureElement(node, elem, value, op); err != nil {
+				return err
+			}
+		}
+	case *parser.MapLit:
+		for _, elt := range pattern.Elements {
+			value := &parser.IndexExpr{
+				Expr:  tmp,
+				Index: &parser.StringLit{Value: elt.Key},
+			}
+			err := c.destructureElement(node, elt.Value, value, op)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Compiler) destructureElement(
+	node parser.Node,
+	target, value parser.Expr,
+	op token.Token,
+) error {
+	switch target := target.(type) {
+	case *parser.ArrayLit, *parser.MapLit:
+		if err := c.Compile(value); err != nil {
+			return err
+		}
+		return c.destructure(node, target, op)
+	case *parser.Ident:
+		if target.Name == "_" {
+			return nil
+		}
+		if op == token.Assign {
+			return c.compileAssign(node, []parser.Expr{target},
+				[]parser.Expr{value}, op)
+		}
+
+		// the symbol was already defined by definePatternSymbols
+		symbol, _, _ := c.symbolTable.Resolve(target.Name)
+		if err := c.Compile(value); err != nil {
+			return err
+		}
+		if symbol.Scope == ScopeGlobal {
+			c.emit(node, parser.OpSetGlobal, symbol.Index)
+		} else {
+			c.emit(node, parser.OpDefineLocal, symbol.Index)
+			symbol.LocalAssigned = true
+		}
+		return nil
+	case *parser.SelectorExpr, *parser.IndexExpr:
+		return c.compileAssign(node, []parser.Expr{target},
+			[]parser.Expr{value}, op)
+	case *parser.SpreadExpr:
+		return c.errorf(target, "rest element must be last in destructuring")
+	default:
+		return c.errorf(target, "invalid destructuring target: %s",
+			target.String())
+	}
+}
+
+func (c *Compiler) compileRecordLit(node *parser.RecordLit, name string) error {
+	var fields, methods []*parser.MapElementLit
+	names := make(map[string]bool)
+	for _, elt := range node.Elements {
+		if names[elt.Key] {
+			return c.errorf(elt, "duplicate record element '%s'", elt.Key)
+		}
+		names[elt.Key] = true
+
+		if fn, ok := elt.Value.(*parser.FuncLit); ok {
+			if len(fn.Type.Params.List) == 0 {
+				return c.errorf(elt,
+					"method '%s' must have a receiver parameter", elt.Key)
+			}
+			methods = append(methods, elt)
+		} else {
+			fields = append(fields, elt)
+		}
+	}
+
+	c.emit(node, parser.OpConstant, c.addConstant(&String{Value: name}))
+	for _, elts := range [][]*parser.MapElementLit{fields, methods} {
+		for _, elt := range elts {
+			if len(elt.Key) > MaxStringLen {
+				return c.error(node, ErrStringLimit)
+			}
+			c.emit(node, parser.OpConstant,
+				c.addConstant(&String{Value: elt.Key}))
+			if err := c.Compile(elt.Value); err != nil {
+				return err
+			}
+		}
+	}
+	c.emit(node, parser.OpRecord, len(fields), len(methods))
+	return nil
+}
+
+// compileChain compiles selector, index, slice and call expressions. If the
+// receiver of an optional link ("?.") is undefined, the rest of the chain is
+// skipped and the whole expression evaluates to undefined.
+func (c *Compiler) compileChain(node parser.Expr) error {
+	var jumps []int
+	if err := c.compileChainLink(node, &jumps); err != nil {
+		return err
+	}
+	for _, pos := range jumps {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+	return nil
+}
+
+func (c *Compiler) compileChainLink(node parser.Expr, jumps *[]int) error {
+	switch node := node.(type) {
+	case *parser.SelectorExpr:
+		if err := c.compileChainLink(node.Expr, jumps); err != nil {
+			return err
+		}
+		if node.Optional {
+			*jumps = append(*jumps, c.emit(node, parser.OpOptionalJump, 0))
+		}
+		if err := c.Compile(node.Sel); err != nil {
+			return err
+		}
+		c.emit(node, parser.OpIndex)
+	case *parser.IndexExpr:
+		if err := c.compileChainLink(node.Expr, jumps); err != nil {
+			return err
+		}
+		if node.Optional {
+			*jumps = append(*jumps, c.emit(node, parser.OpOptionalJump, 0))
+		}
+		if err := c.Compile(node.Index); err != nil {
+			return err
+		}
+		c.emit(node, parser.OpIndex)
+	case *parser.SliceExpr:
+		if err := c.compileChainLink(node.Expr, jumps); err != nil {
+			return err
+		}
+		if node.Optional {
+			*jumps = append(*jumps, c.emit(node, parser.OpOptionalJump, 0))
+		}
+		if node.Low != nil {
+			if err := c.Compile(node.Low); err != nil {
+				return err
+			}
+		} else {
+			c.emit(node, parser.OpNull)
+		}
+		if node.High != nil {
+			if err := c.Compile(node.High); err != nil {
+				return err
+			}
+		} else {
+			c.emit(node, parser.OpNull)
+		}
+		c.emit(node, parser.OpSliceIndex)
+	case *parser.CallExpr:
+		if err := c.compileChainLink(node.Func, jumps); err != nil {
+			return err
+		}
+		for _, arg := range node.Args {
+			if err := c.Compile(arg); err != nil {
+				return err
+			}
+		}
+		c.emit(node, parser.OpCall, len(node.Args))
+	default:
+		return c.Compile(node)
+	}
+	return nil
+}
+
 func (c *Compiler) compileLogical(node *parser.BinaryExpr) error {
 	// left side term
 	if err := c.Compile(node.LHS); err != nil {
@@ -752,9 +1154,12 @@ func (c *Compiler) compileLogical(node *parser.BinaryExpr) error {
 
 	// jump position
 	var jumpPos int
-	if node.Token == token.LAnd {
+	switch node.Token {
+	case token.LAnd:
 		jumpPos = c.emit(node, parser.OpAndJump, 0)
-	} else {
+	case token.Coalesce:
+		jumpPos = c.emit(node, parser.OpCoalesceJump, 0)
+	default:
 		jumpPos = c.emit(node, parser.OpOrJump, 0)
 	}
 
@@ -767,7 +1172,31 @@ func (c *Compiler) compileLogical(node *parser.BinaryExpr) error {
 	return nil
 }
 
-func (c *Compiler) compileForStmt(stmt *parser.ForStmt) error {
+func (c *Compiler) compileLabeledStmt(stmt *parser.LabeledStmt) error {
+	label := stmt.Label.Name
+	if c.labeledLoop(label) != nil {
+		return c.errorf(stmt, "label '%s' already defined", label)
+	}
+	switch loop := stmt.Stmt.(type) {
+	case *parser.ForStmt:
+		return c.compileForStmt(loop, label)
+	case *parser.ForInStmt:
+		return c.compileForInStmt(loop, label)
+	default:
+		return c.errorf(stmt, "label '%s' must be followed by a loop", label)
+	}
+}
+
+func (c *Compiler) compileForStmt(stmt *parser.ForStmt, label string) error {
+	if c.policy != nil && c.policy.DisallowInfiniteLoops {
+		if stmt.Cond == nil {
+			return c.errorf(stmt, "for loop without condition not allowed")
+		}
+		if isConstantTrue(stmt.Cond) {
+			return c.errorf(stmt, "for loop with constant condition not allowed")
+		}
+	}
+
 	c.symbolTable = c.symbolTable.Fork(true)
 	defer func() {
 		c.symbolTable = c.symbolTable.Parent(false)
@@ -794,7 +1223,7 @@ func (c *Compiler) compileForStmt(stmt *parser.ForStmt) error {
 	}
 
 	// enter loop
-	loop := c.enterLoop()
+	loop := c.enterLoop(label)
 
 	// body statement
 	if err := c.Compile(stmt.Body); err != nil {
@@ -833,12 +1262,45 @@ func (c *Compiler) compileForStmt(stmt *parser.ForStmt) error {
 	return nil
 }
 
-func (c *Compiler) compileForInStmt(stmt *parser.ForInStmt) error {
+func (c *Compiler) compileForInStmt(
+	stmt *parser.ForInStmt,
+	label string,
+) error {
 	c.symbolTable = c.symbolTable.Fork(true)
 	defer func() {
 		c.symbolTable = c.symbolTable.Parent(false)
 	}()
 
+	var loop *loop
+	postBodyPos, postStmtPos, err := c.compileForIn(stmt, stmt.Key,
+		stmt.Value, stmt.Iterable, func() error {
+			loop = c.enterLoop(label)
+			defer c.leaveLoop()
+			return c.Compile(stmt.Body)
+		})
+	if err != nil {
+		return err
+	}
+
+	// update all break/continue jump positions
+	for _, pos := range loop.Breaks {
+		c.changeOperand(pos, postStmtPos)
+	}
+	for _, pos := range loop.Continues {
+		c.changeOperand(pos, postBodyPos)
+	}
+	return nil
+}
+
+// compileForIn compiles an iteration over iterable in the current block,
+// calling body to compile the loop body. It returns the positions after the
+// body and after the loop.
+func (c *Compiler) compileForIn(
+	node parser.Node,
+	key, value *parser.Ident,
+	iterable parser.Expr,
+	body func() error,
+) (postBodyPos, postStmtPos int, err error) {
 	// for-in statement is compiled like following:
 	//
 	//   for :it := iterator(iterable); :it.next();  {
@@ -853,14 +1315,14 @@ func (c *Compiler) compileForInStmt(stmt *parser.ForInStmt) error {
 	// init
 	//   :it = iterator(iterable)
 	itSymbol := c.symbolTable.Define(":it")
-	if err := c.Compile(stmt.Iterable); err != nil {
-		return err
+	if err := c.Compile(iterable); err != nil {
+		return 0, 0, err
 	}
-	c.emit(stmt, parser.OpIteratorInit)
+	c.emit(node, parser.OpIteratorInit)
 	if itSymbol.Scope == ScopeGlobal {
-		c.emit(stmt, parser.OpSetGlobal, itSymbol.Index)
+		c.emit(node, parser.OpSetGlobal, itSymbol.Index)
 	} else {
-		c.emit(stmt, parser.OpDefineLocal, itSymbol.Index)
+		c.emit(node, parser.OpDefineLocal, itSymbol.Index)
 	}
 
 	// pre-condition position
@@ -869,74 +1331,164 @@ func (c *Compiler) compileForInStmt(stmt *parser.ForInStmt) error {
 	// condition
 	//  :it.HasMore()
 	if itSymbol.Scope == ScopeGlobal {
-		c.emit(stmt, parser.OpGetGlobal, itSymbol.Index)
+		c.emit(node, parser.OpGetGlobal, itSymbol.Index)
 	} else {
-		c.emit(stmt, parser.OpGetLocal, itSymbol.Index)
+		c.emit(node, parser.OpGetLocal, itSymbol.Index)
 	}
-	c.emit(stmt, parser.OpIteratorNext)
+	c.emit(node, parser.OpIteratorNext)
 
 	// condition jump position
-	postCondPos := c.emit(stmt, parser.OpJumpFalsy, 0)
-
-	// enter loop
-	loop := c.enterLoop()
+	postCondPos := c.emit(node, parser.OpJumpFalsy, 0)
 
 	// assign key variable
-	if stmt.Key.Name != "_" {
-		keySymbol := c.symbolTable.Define(stmt.Key.Name)
+	if key.Name != "_" {
+		keySymbol := c.symbolTable.Define(key.Name)
 		if itSymbol.Scope == ScopeGlobal {
-			c.emit(stmt, parser.OpGetGlobal, itSymbol.Index)
+			c.emit(node, parser.OpGetGlobal, itSymbol.Index)
 		} else {
-			c.emit(stmt, parser.OpGetLocal, itSymbol.Index)
+			c.emit(node, parser.OpGetLocal, itSymbol.Index)
 		}
-		c.emit(stmt, parser.OpIteratorKey)
+		c.emit(node, parser.OpIteratorKey)
 		if keySymbol.Scope == ScopeGlobal {
-			c.emit(stmt, parser.OpSetGlobal, keySymbol.Index)
+			c.emit(node, parser.OpSetGlobal, keySymbol.Index)
 		} else {
-			c.emit(stmt, parser.OpDefineLocal, keySymbol.Index)
+			c.emit(node, parser.OpDefineLocal, keySymbol.Index)
 		}
 	}
 
 	// assign value variable
-	if stmt.Value.Name != "_" {
-		valueSymbol := c.symbolTable.Define(stmt.Value.Name)
+	if value.Name != "_" {
+		valueSymbol := c.symbolTable.Define(value.Name)
 		if itSymbol.Scope == ScopeGlobal {
-			c.emit(stmt, parser.OpGetGlobal, itSymbol.Index)
+			c.emit(node, parser.OpGetGlobal, itSymbol.Index)
 		} else {
-			c.emit(stmt, parser.OpGetLocal, itSymbol.Index)
+			c.emit(node, parser.OpGetLocal, itSymbol.Index)
 		}
-		c.emit(stmt, parser.OpIteratorValue)
+		c.emit(node, parser.OpIteratorValue)
 		if valueSymbol.Scope == ScopeGlobal {
-			c.emit(stmt, parser.OpSetGlobal, valueSymbol.Index)
+			c.emit(node, parser.OpSetGlobal, valueSymbol.Index)
 		} else {
-			c.emit(stmt, parser.OpDefineLocal, valueSymbol.Index)
+			c.emit(node, parser.OpDefineLocal, valueSymbol.Index)
 		}
 	}
 
 	// body statement
-	if err := c.Compile(stmt.Body); err != nil {
-		c.leaveLoop()
-		return err
+	if err := body(); err != nil {
+		return 0, 0, err
 	}
 
-	c.leaveLoop()
-
 	// post-body position
-	postBodyPos := len(c.currentInstructions())
+	postBodyPos = len(c.currentInstructions())
 
 	// back to condition
-	c.emit(stmt, parser.OpJump, preCondPos)
+	c.emit(node, parser.OpJump, preCondPos)
 
 	// post-statement position
-	postStmtPos := len(c.currentInstructions())
+	postStmtPos = len(c.currentInstructions())
 	c.changeOperand(postCondPos, postStmtPos)
+	return postBodyPos, postStmtPos, nil
+}
 
-	// update all break/continue jump positions
-	for _, pos := range loop.Breaks {
-		c.changeOperand(pos, postStmtPos)
+// compileComprehension compiles an array (key is nil) or a map
+// comprehension. The result is accumulated in a hidden ":c" variable:
+//
+//	:c := []
+//	for k, v in iterable {
+//	  if cond { :c = append(:c, value) }  // or :c[key] = value
+//	}
+//	:c
+func (c *Compiler) compileComprehension(
+	node parser.Expr,
+	key, value parser.Expr,
+	clauses []*parser.ComprehensionClause,
+) error {
+	c.symbolTable = c.symbolTable.Fork(true)
+	defer func() {
+		c.symbolTable = c.symbolTable.Parent(false)
+	}()
+
+	if key == nil {
+		c.emit(node, parser.OpArray, 0)
+	} else {
+		c.emit(node, parser.OpMap, 0)
 	}
-	for _, pos := range loop.Continues {
-		c.changeOperand(pos, postBodyPos)
+	acc := c.symbolTable.Define(":c")
+	if acc.Scope == ScopeGlobal {
+		c.emit(node, parser.OpSetGlobal, acc.Index)
+	} else {
+		c.emit(node, parser.OpDefineLocal, acc.Index)
+	}
+
+	var compileClause func(i int) error
+	compileClause = func(i int) error {
+		if i == len(clauses) {
+			return c.compileComprehensionElement(node, acc, key, value)
+		}
+		clause := clauses[i]
+		c.symbolTable = c.symbolTable.Fork(true)
+		defer func() {
+			c.symbolTable = c.symbolTable.Parent(false)
+		}()
+		_, _, err := c.compileForIn(clause, clause.Key, clause.Value,
+			clause.Iterable, func() error {
+				if clause.Cond == nil {
+					return compileClause(i + 1)
+				}
+				if err := c.Compile(clause.Cond); err != nil {
+					return err
+				}
+				jumpPos := c.emit(clause, parser.OpJumpFalsy, 0)
+				if err := compileClause(i + 1); err != nil {
+					return err
+				}
+				c.changeOperand(jumpPos, len(c.currentInstructions()))
+				return nil
+			})
+		return err
+	}
+	if err := compileClause(0); err != nil {
+		return err
+	}
+
+	if acc.Scope == ScopeGlobal {
+		c.emit(node, parser.OpGetGlobal, acc.Index)
+	} else {
+		c.emit(node, parser.OpGetLocal, acc.Index)
+	}
+	return nil
+}
+
+func (c *Compiler) compileComprehensionElement(
+	node parser.Expr,
+	acc *Symbol,
+	key, value parser.Expr,
+) error {
+	if key == nil {
+		// appends value to :c in place: unlike the append builtin function,
+		// it cannot be removed or replaced by the script options.
+		if acc.Scope == ScopeGlobal {
+			c.emit(node, parser.OpGetGlobal, acc.Index)
+		} else {
+			c.emit(node, parser.OpGetLocal, acc.Index)
+		}
+		if err := c.Compile(value); err != nil {
+			return err
+		}
+		c.emit(node, parser.OpAppend)
+		return nil
+	}
+
+	// :c[key] = value
+	if err := c.Compile(value); err != nil {
+		return err
+	}
+	if err := c.Compile(key); err != nil {
+		return err
+	}
+	if acc.Scope == ScopeGlobal {
+		c.emit(node, parser.OpSetSelGlobal, acc.Index, 1)
+	} else {
+		c.emit(node, parser.OpSetSelLocal, acc.Index, 1)
 	}
 	return nil
 }
@@ -1017,8 +1569,8 @@ func (c *Compiler) storeCompiledModule(
 	c.compiledModules[modulePath] = module
 }
 
-func (c *Compiler) enterLoop() *loop {
-	loop := &loop{}
+func (c *Compiler) enterLoop(label string) *loop {
+	loop := &loop{Label: label}
 	c.loops = append(c.loops, loop)
 	c.loopIndex++
 	if c.trace != nil {
@@ -1042,6 +1594,16 @@ func (c *Compiler) currentLoop() *loop {
 	return nil
 }
 
+// labeledLoop returns the enclosing loop with the given label, or nil.
+func (c *Compiler) labeledLoop(label string) *loop {
+	for i := c.loopIndex; i >= 0; i-- {
+		if c.loops[i].Label == label {
+			return c.loops[i]
+		}
+	}
+	return nil
+}
+
 func (c *Compiler) currentInstructions() []byte {
 	return c.scopes[c.scopeIndex].Instructions
 }
@@ -1083,7 +1645,9 @@ func (c *Compiler) fork(
 	modulePath string,
 	symbolTable *SymbolTable,
 ) *Compiler {
-	child := NewCompiler(file, symbolTable, nil, c.modules, c.trace)
+	child := newCompiler(file, symbolTable, nil, c.modules, c.trace)
+	child.builtins = c.builtins
+	child.policy = c.policy
 	child.modulePath = modulePath // module file path
 	child.parent = c              // parent to set to current compiler
 	return child
@@ -1157,7 +1721,8 @@ func (c *Compiler) optimizeFunc(node parser.Node) {
 		func(pos int, opcode parser.Opcode, operands []int) bool {
 			switch opcode {
 			case parser.OpJump, parser.OpJumpFalsy,
-				parser.OpAndJump, parser.OpOrJump:
+				parser.OpAndJump, parser.OpOrJump,
+				parser.OpOptionalJump, parser.OpCoalesceJump:
 				dsts[operands[0]] = true
 			}
 			return true
@@ -1196,7 +1761,7 @@ func (c *Compiler) optimizeFunc(node parser.Node) {
 		func(pos int, opcode parser.Opcode, operands []int) bool {
 			switch opcode {
 			case parser.OpJump, parser.OpJumpFalsy, parser.OpAndJump,
-				parser.OpOrJump:
+				parser.OpOrJump, parser.OpOptionalJump, parser.OpCoalesceJump:
 				newDst, ok := posMap[operands[0]]
 				if ok {
 					copy(newInsts[pos:],
@@ -1286,6 +1851,41 @@ func resolveAssignLHS(
 	return
 }
 
+func isOptionalChain(expr parser.Expr) bool {
+	switch term := expr.(type) {
+	case *parser.SelectorExpr:
+		return term.Optional || isOptionalChain(term.Expr)
+	case *parser.IndexExpr:
+		return term.Optional || isOptionalChain(term.Expr)
+	}
+	return false
+}
+
+// isConstantTrue returns true if expr is a literal, or, a negation of a
+// literal that is always truthy.
+func isConstantTrue(expr parser.Expr) bool {
+	switch expr := expr.(type) {
+	case *parser.BoolLit:
+		return expr.Value
+	case *parser.IntLit:
+		return expr.Value != 0
+	case *parser.FloatLit:
+		return !math.IsNaN(expr.Value)
+	case *parser.CharLit:
+		return expr.Value != 0
+	case *parser.StringLit:
+		return expr.Value != ""
+	case *parser.ParenExpr:
+		return isConstantTrue(expr.Expr)
+	case *parser.UnaryExpr:
+		if lit, ok := expr.Expr.(*parser.BoolLit); ok &&
+			expr.Token == token.Not {
+			return !lit.Value
+		}
+	}
+	return false
+}
+
 func iterateInstructions(
 	b []byte,
 	fn func(pos int, opcode parser.Opcode, operands []int) bool,
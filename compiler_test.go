@@ -943,6 +943,31 @@ func() {
 				tengo.MakeInstruction(parser.OpSuspend)),
 			objectsArray()))
 
+	expectCompile(t, `a := []; [x for x in a]`,
+		bytecode(
+			concatInsts(
+				tengo.MakeInstruction(parser.OpArray, 0),
+				tengo.MakeInstruction(parser.OpSetGlobal, 0),
+				tengo.MakeInstruction(parser.OpArray, 0),
+				tengo.MakeInstruction(parser.OpSetGlobal, 1),
+				tengo.MakeInstruction(parser.OpGetGlobal, 0),
+				tengo.MakeInstruction(parser.OpIteratorInit),
+				tengo.MakeInstruction(parser.OpSetGlobal, 2),
+				tengo.MakeInstruction(parser.OpGetGlobal, 2),
+				tengo.MakeInstruction(parser.OpIteratorNext),
+				tengo.MakeInstruction(parser.OpJumpFalsy, 43),
+				tengo.MakeInstruction(parser.OpGetGlobal, 2),
+				tengo.MakeInstruction(parser.OpIteratorValue),
+				tengo.MakeInstruction(parser.OpSetGlobal, 3),
+				tengo.MakeInstruction(parser.OpGetGlobal, 1),
+				tengo.MakeInstruction(parser.OpGetGlobal, 3),
+				tengo.MakeInstruction(parser.OpAppend),
+				tengo.MakeInstruction(parser.OpJump, 19),
+				tengo.MakeInstruction(parser.OpGetGlobal, 1),
+				tengo.MakeInstruction(parser.OpPop),
+				tengo.MakeInstruction(parser.OpSuspend)),
+			objectsArray()))
+
 	expectCompile(t, `a := 0; a == 0 && a != 1 || a < 1`,
 		bytecode(
 			concatInsts(
@@ -965,6 +990,26 @@ func() {
 				intObject(0),
 				intObject(1))))
 
+	expectCompile(t, `a := {}; a?.b.c ?? 1`,
+		bytecode(
+			concatInsts(
+				tengo.MakeInstruction(parser.OpMap, 0),
+				tengo.MakeInstruction(parser.OpSetGlobal, 0),
+				tengo.MakeInstruction(parser.OpGetGlobal, 0),
+				tengo.MakeInstruction(parser.OpOptionalJump, 20),
+				tengo.MakeInstruction(parser.OpConstant, 0),
+				tengo.MakeInstruction(parser.OpIndex),
+				tengo.MakeInstruction(parser.OpConstant, 1),
+				tengo.MakeInstruction(parser.OpIndex),
+				tengo.MakeInstruction(parser.OpCoalesceJump, 26),
+				tengo.MakeInstruction(parser.OpConstant, 2),
+				tengo.MakeInstruction(parser.OpPop),
+				tengo.MakeInstruction(parser.OpSuspend)),
+			objectsArray(
+				stringObject("b"),
+				stringObject("c"),
+				intObject(1))))
+
 	// unknown module name
 	expectCompileError(t, `import("user1")`, "module 'user1' not found")
 
@@ -1011,6 +1056,63 @@ func TestCompilerErrorReport(t *testing.T) {
 		"Compile Error: export not allowed inside function\n\tat test:1:10")
 }
 
+func TestCompiler_SetBuiltin(t *testing.T) {
+	compileWith := func(src string, builtins []*tengo.BuiltinFunction) (
+		*tengo.Bytecode, error,
+	) {
+		fileSet := parser.NewFileSet()
+		file := fileSet.AddFile("test", -1, len(src))
+		p := parser.NewParser(file, []byte(src), nil)
+		f, err := p.ParseFile()
+		require.NoError(t, err)
+		c := tengo.NewCompiler(file, tengo.NewSymbolTable(), nil, nil, nil)
+		for _, fn := range builtins {
+			c.SetBuiltin(fn)
+		}
+		if err := c.Compile(f); err != nil {
+			return nil, err
+		}
+		return c.Bytecode(), nil
+	}
+	newBuiltin := func(name string) *tengo.BuiltinFunction {
+		return &tengo.BuiltinFunction{
+			Name: name,
+			Value: func(args ...tengo.Object) (tengo.Object, error) {
+				return tengo.UndefinedValue, nil
+			},
+		}
+	}
+	defaults := tengo.GetAllBuiltinFunctions()
+
+	// a replaced builtin function keeps its slot
+	myLen, foo := newBuiltin("len"), newBuiltin("foo")
+	b, err := compileWith(`len(foo())`,
+		[]*tengo.BuiltinFunction{foo, myLen, newBuiltin("foo")})
+	require.NoError(t, err)
+	require.Equal(t, len(defaults)+1, len(b.Builtins))
+	for i, fn := range defaults {
+		if fn.Name == "len" {
+			require.True(t, b.Builtins[i] == myLen)
+		}
+	}
+	require.False(t, b.Builtins[len(defaults)] == foo)
+
+	// the operand of OpGetBuiltin has one byte
+	var builtins []*tengo.BuiltinFunction
+	for i := len(defaults); i < tengo.MaxBuiltins; i++ {
+		builtins = append(builtins, newBuiltin(fmt.Sprintf("f%d", i)))
+	}
+	last := fmt.Sprintf("f%d()", tengo.MaxBuiltins-1)
+	b, err = compileWith(last, builtins)
+	require.NoError(t, err)
+	require.Equal(t, tengo.MaxBuiltins, len(b.Builtins))
+	builtins = append(builtins, newBuiltin("g"))
+	_, err = compileWith(last, builtins)
+	require.Error(t, err)
+	require.True(t, strings.Contains(err.Error(),
+		"too many builtin functions: 257 > 256"), err.Error())
+}
+
 func TestCompilerDeadCode(t *testing.T) {
 	expectCompile(t, `
 func() {
@@ -0,0 +1,272 @@
+package tengo
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+var (
+	bigOne = big.NewInt(1)
+	bigTen = big.NewInt(10)
+)
+
+// pow10 returns 10**n for a non-negative n.
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(bigTen, big.NewInt(int64(n)), nil)
+}
+
+// decimalFromInt returns a Decimal of an int value.
+func decimalFromInt(v int64) *Decimal {
+	return &Decimal{Value: big.NewInt(v)}
+}
+
+// decimalFromBigInt returns a Decimal of a big integer value.
+func decimalFromBigInt(v *big.Int) *Decimal {
+	return &Decimal{Value: new(big.Int).Set(v)}
+}
+
+// decimalFromFloat returns a Decimal of the shortest decimal representation
+// of f. It fails for NaN and infinite values.
+func decimalFromFloat(f float64) (*Decimal, bool) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, false
+	}
+	return ParseDecimal(strconv.FormatFloat(f, 'e', -1, 64))
+}
+
+// decimalFromRat returns a Decimal of a rational value. A value that has no
+// finite decimal representation is rounded to DecimalDivisionPrecision
+// digits after the decimal point.
+func decimalFromRat(r *big.Rat) *Decimal {
+	return decimalQuo(
+		&Decimal{Value: new(big.Int).Set(r.Num())},
+		&Decimal{Value: new(big.Int).Set(r.Denom())})
+}
+
+// ParseDecimal parses a decimal number such as "-12.50" or "1.5e-3" into a
+// Decimal value.
+func ParseDecimal(s string) (*Decimal, bool) {
+	mantissa, exp := s, 0
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		e, err := strconv.ParseInt(s[i+1:], 10, 16)
+		if err != nil {
+			return nil, false
+		}
+		mantissa, exp = s[:i], int(e)
+	}
+
+	var neg bool
+	switch {
+	case strings.HasPrefix(mantissa, "-"):
+		neg = true
+		mantissa = mantissa[1:]
+	case strings.HasPrefix(mantissa, "+"):
+		mantissa = mantissa[1:]
+	}
+
+	intPart, fracPart := mantissa, ""
+	if i := strings.IndexByte(mantissa, '.'); i >= 0 {
+		intPart, fracPart = mantissa[:i], mantissa[i+1:]
+	}
+	if intPart == "" && fracPart == "" {
+		return nil, false
+	}
+	digits := intPart + fracPart
+	for i := 0; i < len(digits); i++ {
+		if digits[i] < '0' || digits[i] > '9' {
+			return nil, false
+		}
+	}
+
+	v, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return nil, false
+	}
+	if neg {
+		v.Neg(v)
+	}
+	scale := len(fracPart) - exp
+	if scale < 0 {
+		v.Mul(v, pow10(-scale))
+		scale = 0
+	}
+	return &Decimal{Value: v, Scale: scale}, true
+}
+
+// rescale returns the unscaled value of o at a scale that is not less than
+// the scale of o.
+func (o *Decimal) rescale(scale int) *big.Int {
+	if scale == o.Scale {
+		return o.Value
+	}
+	return new(big.Int).Mul(o.Value, pow10(scale-o.Scale))
+}
+
+// alignDecimals returns the unscaled values of a and b at a common scale.
+func alignDecimals(a, b *Decimal) (x, y *big.Int, scale int) {
+	scale = a.Scale
+	if b.Scale > scale {
+		scale = b.Scale
+	}
+	return a.rescale(scale), b.rescale(scale), scale
+}
+
+// cmp compares o and x and returns -1, 0 or +1.
+func (o *Decimal) cmp(x *Decimal) int {
+	a, b, _ := alignDecimals(o, x)
+	return a.Cmp(b)
+}
+
+// trim removes trailing zeros after the decimal point without reducing the
+// scale below minScale.
+func (o *Decimal) trim(minScale int) *Decimal {
+	v, scale := o.Value, o.Scale
+	q, r := new(big.Int), new(big.Int)
+	for scale > minScale {
+		q.QuoRem(v, bigTen, r)
+		if r.Sign() != 0 {
+			break
+		}
+		v, q = q, new(big.Int)
+		scale--
+	}
+	return &Decimal{Value: v, Scale: scale}
+}
+
+// round returns o rounded half to even to the given number of digits after
+// the decimal point.
+func (o *Decimal) round(scale int) *Decimal {
+	if scale >= o.Scale {
+		return &Decimal{Value: o.rescale(scale), Scale: scale}
+	}
+	return &Decimal{
+		Value: quoRoundHalfEven(o.Value, pow10(o.Scale-scale)),
+		Scale: scale,
+	}
+}
+
+// quoRoundHalfEven returns x/y rounded half to even.
+func quoRoundHalfEven(x, y *big.Int) *big.Int {
+	q, r := new(big.Int).QuoRem(x, y, new(big.Int))
+	if r.Sign() == 0 {
+		return q
+	}
+	c := new(big.Int).Lsh(r, 1).CmpAbs(y)
+	if c > 0 || (c == 0 && q.Bit(0) == 1) {
+		if x.Sign()*y.Sign() < 0 {
+			q.Sub(q, bigOne)
+		} else {
+			q.Add(q, bigOne)
+		}
+	}
+	return q
+}
+
+// decimalQuo returns a/b for a non-zero b. Exact results keep the scale of a
+// less the scale of b (but never less than 0), and, inexact results are
+// rounded to DecimalDivisionPrecision digits after the decimal point.
+func decimalQuo(a, b *Decimal) *Decimal {
+	ideal := a.Scale - b.Scale
+	if ideal < 0 {
+		ideal = 0
+	}
+	scale := DecimalDivisionPrecision
+	if scale < ideal {
+		scale = ideal
+	}
+	x := new(big.Int).Mul(a.Value, pow10(scale+b.Scale-a.Scale))
+	r := &Decimal{Value: quoRoundHalfEven(x, b.Value), Scale: scale}
+	return r.trim(ideal)
+}
+
+// decimalPow returns x**n.
+func decimalPow(x *Decimal, n int64) (*Decimal, error) {
+	if n < 0 {
+		if x.Value.Sign() == 0 {
+			return nil, ErrDivisionByZero
+		}
+		if n == math.MinInt64 {
+			return nil, ErrBigIntLimit
+		}
+		p, err := decimalPow(x, -n)
+		if err != nil {
+			return nil, err
+		}
+		return decimalQuo(decimalFromInt(1), p), nil
+	}
+	if err := checkBigPow(x.Value, n); err != nil {
+		return nil, err
+	}
+	if x.Scale > 0 && n > int64(MaxBigIntBits/x.Scale) {
+		return nil, ErrBigIntLimit
+	}
+	return &Decimal{
+		Value: new(big.Int).Exp(x.Value, big.NewInt(n), nil),
+		Scale: x.Scale * int(n),
+	}, nil
+}
+
+// checkBigPow returns ErrBigIntLimit if the bit length of x**n can exceed
+// MaxBigIntBits for a non-negative n. The bit length of x**n is exactly
+// (bits-1)*n+1 if x is a power of two, and, at most bits*n otherwise.
+func checkBigPow(x *big.Int, n int64) error {
+	bits := int64(x.BitLen())
+	if bits <= 1 {
+		return nil
+	}
+	if x.TrailingZeroBits() == uint(bits-1) {
+		if n > int64(MaxBigIntBits-1)/(bits-1) {
+			return ErrBigIntLimit
+		}
+		return nil
+	}
+	if n > int64(MaxBigIntBits)/bits {
+		return ErrBigIntLimit
+	}
+	return nil
+}
+
+// checkBigMul returns ErrBigIntLimit if the bit length of x*y can exceed
+// MaxBigIntBits.
+func checkBigMul(x, y *big.Int) error {
+	if x.BitLen()+y.BitLen() > MaxBigIntBits {
+		return ErrBigIntLimit
+	}
+	return nil
+}
+
+// truncate returns the integer part of o.
+func (o *Decimal) truncate() *big.Int {
+	if o.Scale == 0 {
+		return o.Value
+	}
+	return new(big.Int).Quo(o.Value, pow10(o.Scale))
+}
+
+// rat returns the value of o as a rational number.
+func (o *Decimal) rat() *big.Rat {
+	return new(big.Rat).SetFrac(o.Value, pow10(o.Scale))
+}
+
+// float64 returns the nearest float64 value of o.
+func (o *Decimal) float64() float64 {
+	f, _ := o.rat().Float64()
+	return f
+}
+
+// toDecimal converts an int, big integer, float or decimal value to Decimal.
+func toDecimal(o Object) (*Decimal, bool) {
+	switch o := o.(type) {
+	case *Decimal:
+		return o, true
+	case *Int:
+		return decimalFromInt(o.Value), true
+	case *BigInt:
+		return decimalFromBigInt(o.Value), true
+	case *Float:
+		return decimalFromFloat(o.Value)
+	}
+	return nil, false
+}
@@ -39,6 +39,10 @@ var (
 	// exceeds the limit.
 	ErrStringLimit = errors.New("exceeding string size limit")
 
+	// ErrBigIntLimit represents an error where the bit length of a bigint or
+	// a decimal value exceeds the limit.
+	ErrBigIntLimit = errors.New("exceeding bigint size limit")
+
 	// ErrNotIndexable is an error where an Object is not indexable.
 	ErrNotIndexable = errors.New("not indexable")
 
@@ -49,6 +53,27 @@ var (
 	// ErrNotImplemented is an error where an Object has not implemented a
 	// required method.
 	ErrNotImplemented = errors.New("not implemented")
+
+	// ErrUnknownField is an error where a record does not have a field or a
+	// method with the given name.
+	ErrUnknownField = errors.New("unknown field")
+
+	// ErrDivisionByZero is an error where a number is divided by zero.
+	ErrDivisionByZero = errors.New("division by zero")
+
+	// ErrCompiledFunctionCall is an error where a compiled function is
+	// called outside the VM, e.g. by a builtin function called from Go.
+	ErrCompiledFunctionCall = errors.New(
+		"compiled function cannot be called outside the VM")
+
+	// ErrNotHashable is an error where a value cannot be an element of a
+	// set.
+	ErrNotHashable = errors.New("not hashable")
+
+	// ErrIntegerOverflow is an error where the result of an integer
+	// operation does not fit in int64. It is only returned when overflow
+	// checking is enabled.
+	ErrIntegerOverflow = errors.New("integer overflow")
 )
 
 // ErrInvalidArgumentType represents an invalid argument value type error.
@@ -0,0 +1,95 @@
+package tengo
+
+import (
+	"context"
+	"sort"
+
+	"github.com/d5/tengo/v2/parser"
+)
+
+// Expression is a compiled expression that can be evaluated many times with
+// different parameters. Expression is safe for concurrent use. Use
+// CompileExpression to create an Expression.
+type Expression struct {
+	program *Program
+	result  int // global index of the result
+}
+
+// CompileExpression parses and compiles a single expression, e.g.
+// "price * qty > 100". params are the names of the parameters the
+// expression can refer to.
+func CompileExpression(expr string, params ...string) (*Expression, error) {
+	fileSet := parser.NewFileSet()
+	srcFile := fileSet.AddFile("(expr)", -1, len(expr))
+	p := parser.NewParser(srcFile, []byte(expr), nil)
+	node, err := p.ParseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	symbolTable := NewSymbolTable()
+	c := NewCompiler(srcFile, symbolTable, nil, nil, nil)
+	globalIndexes := make(map[string]int, len(params))
+	for _, name := range params {
+		globalIndexes[name] = symbolTable.Define(name).Index
+	}
+	// the expression cannot refer to the result as its name is not a valid
+	// identifier
+	result := symbolTable.Define("(result)")
+	if err := c.Compile(node); err != nil {
+		return nil, err
+	}
+	c.emit(node, parser.OpSetGlobal, result.Index)
+
+	globals := make([]Object, symbolTable.MaxSymbols()+1)
+	for _, idx := range globalIndexes {
+		globals[idx] = UndefinedValue
+	}
+	globals[result.Index] = UndefinedValue
+
+	bytecode := c.Bytecode()
+	bytecode.RemoveDuplicates()
+	return &Expression{
+		program: &Program{
+			globalIndexes: globalIndexes,
+			globals:       globals,
+			pool:          newVMPool(bytecode, -1, false),
+		},
+		result: result.Index,
+	}, nil
+}
+
+// Eval evaluates the expression with the parameters, and, returns the
+// result converted using ToInterface. The parameters that are not given are
+// undefined, and, an error is returned for the names that are not the
+// parameters of the expression.
+func (e *Expression) Eval(
+	ctx context.Context,
+	params map[string]interface{},
+) (interface{}, error) {
+	env, err := e.program.RunContext(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	return ToInterface(env.globals[e.result]), nil
+}
+
+// Eval compiles and evaluates the expression expr with the parameters. Use
+// CompileExpression to evaluate the same expression many times.
+func Eval(
+	ctx context.Context,
+	expr string,
+	params map[string]interface{},
+) (interface{}, error) {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	e, err := CompileExpression(expr, names...)
+	if err != nil {
+		return nil, err
+	}
+	return e.Eval(ctx, params)
+}
@@ -0,0 +1,124 @@
+package tengo_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/d5/tengo/v2"
+	"github.com/d5/tengo/v2/require"
+)
+
+func TestEval(t *testing.T) {
+	eval := func(expr string, params M, expected interface{}) {
+		ctx := context.Background()
+		actual, err := tengo.Eval(ctx, expr, params)
+		require.NoError(t, err)
+		require.Equal(t, expected, actual)
+	}
+
+	eval(`1 + 2`, nil, int64(3))
+	eval(`price * qty > 100`, M{"price": 30, "qty": 4}, true)
+	eval(`price * qty > 100`, M{"price": 30.5, "qty": 3}, false)
+	eval(`name + "!"`, M{"name": "foo"}, "foo!")
+	eval(`a ? len(b) : -1`, M{"a": true, "b": []interface{}{1, 2}},
+		int64(2))
+	eval(`[x * 2 for x in a][1]`, M{"a": []interface{}{1, 2}}, int64(4))
+	eval(`m.a.b`, M{"m": M{"a": M{"b": "c"}}}, "c")
+	eval(`func(x) { return x + 1 }(a)`, M{"a": 1}, int64(2))
+	eval("a +\nb\n", M{"a": 1, "b": 2}, int64(3))
+
+	// parse errors
+	_, err := tengo.Eval(context.Background(), `a := 1`, nil)
+	require.Error(t, err)
+	_, err = tengo.Eval(context.Background(), `1; 2`, nil)
+	require.Error(t, err)
+	_, err = tengo.Eval(context.Background(), ``, nil)
+	require.Error(t, err)
+
+	// compile error
+	_, err = tengo.Eval(context.Background(), `a + b`, M{"a": 1})
+	require.Error(t, err)
+	require.Equal(t,
+		"Compile Error: unresolved reference 'b'\n\tat (expr):1:5",
+		err.Error())
+
+	// runtime error
+	_, err = tengo.Eval(context.Background(), `a / 0`, M{"a": 1})
+	require.Error(t, err)
+	require.Equal(t,
+		"Runtime Error: division by zero\n\tat (expr):1:1",
+		err.Error())
+
+	// timeout
+	ctx, cancel := context.WithTimeout(context.Background(),
+		1*time.Millisecond)
+	defer cancel()
+	_, err = tengo.Eval(ctx, `func() { for true {} }()`, nil)
+	require.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestExpression(t *testing.T) {
+	e, err := tengo.CompileExpression(`price * qty > limit`,
+		"price", "qty", "limit")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	res, err := e.Eval(ctx, M{"price": 30, "qty": 4, "limit": 100})
+	require.NoError(t, err)
+	require.Equal(t, true, res)
+	res, err = e.Eval(ctx, M{"price": 30, "qty": 3, "limit": 100})
+	require.NoError(t, err)
+	require.Equal(t, false, res)
+
+	// unknown parameter
+	_, err = e.Eval(ctx, M{"foo": 1})
+	require.Error(t, err)
+	require.Equal(t, "'foo' is not defined", err.Error())
+
+	// missing parameters are undefined
+	e, err = tengo.CompileExpression(`is_undefined(a) ? "none" : a`, "a")
+	require.NoError(t, err)
+	res, err = e.Eval(ctx, nil)
+	require.NoError(t, err)
+	require.Equal(t, "none", res)
+	res, err = e.Eval(ctx, M{"a": "foo"})
+	require.NoError(t, err)
+	require.Equal(t, "foo", res)
+
+	// the result is not accessible
+	_, err = e.Eval(ctx, M{"(result)": 1})
+	require.Error(t, err)
+
+	// concurrent evaluations
+	e, err = tengo.CompileExpression(`a * 2`, "a")
+	require.NoError(t, err)
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			res, err := e.Eval(ctx, M{"a": i})
+			require.NoError(t, err)
+			require.Equal(t, int64(i*2), res)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func BenchmarkExpression_Eval(b *testing.B) {
+	e, err := tengo.CompileExpression(`price * qty > 100`, "price", "qty")
+	if err != nil {
+		b.Fatal(err)
+	}
+	ctx := context.Background()
+	params := M{"price": 30, "qty": 4}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := e.Eval(ctx, params); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package tengo
 
 import (
+	"math/big"
 	"strconv"
 	"sync"
 	"unicode/utf8"
@@ -871,6 +872,41 @@ func (p *pp) fmtFloat(v float64, size int, verb rune) {
 	}
 }
 
+// fmtBigInt formats an arbitrary-precision integer.
+func (p *pp) fmtBigInt(v *big.Int, verb rune) {
+	switch verb {
+	case 'b', 'o', 'O', 'd', 'x', 'X':
+		v.Format(p, verb)
+	case 's':
+		p.fmt.fmtS(v.String())
+	default:
+		p.badVerb(verb)
+	}
+}
+
+// fmtDecimal formats an arbitrary-precision decimal. The %f verb rounds the
+// exact decimal value, and, the other floating-point verbs format the
+// nearest binary floating-point value with enough precision.
+func (p *pp) fmtDecimal(v *Decimal, verb rune) {
+	switch verb {
+	case 'f', 'F':
+		prec := 6
+		if p.fmt.precPresent {
+			prec = p.fmt.prec
+		}
+		v = v.round(prec)
+		fallthrough
+	case 'e', 'E', 'g', 'G':
+		prec := uint(len(v.Value.String()))*4 + 64
+		f := new(big.Float).SetPrec(prec).SetRat(v.rat())
+		f.Format(p, verb)
+	case 's':
+		p.fmt.fmtS(v.String())
+	default:
+		p.badVerb(verb)
+	}
+}
+
 func (p *pp) fmtString(v string, verb rune) {
 	switch verb {
 	case 'v':
@@ -957,6 +993,10 @@ func (p *pp) printArg(arg Object, verb rune) {
 		p.fmtFloat(f.Value, 64, verb)
 	case *Int:
 		p.fmtInteger(uint64(f.Value), signed, verb)
+	case *BigInt:
+		p.fmtBigInt(f.Value, verb)
+	case *Decimal:
+		p.fmtDecimal(f, verb)
 	case *String:
 		p.fmtString(f.Value, verb)
 	case *Bytes:
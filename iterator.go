@@ -107,15 +107,25 @@ func (i *BytesIterator) Value() Object {
 	return &Int{Value: int64(i.v[i.i-1])}
 }
 
-// MapIterator represents an iterator for the map.
+// MapIterator represents an iterator for the map. It returns the elements in
+// the order of the map.
 type MapIterator struct {
 	ObjectImpl
 	v map[string]Object
-	k []string
+	h map[string]MapEntry
+	k []mapKey
 	i int
 	l int
 }
 
+func newMapIterator(
+	v map[string]Object,
+	h map[string]MapEntry,
+	keys []mapKey,
+) *MapIterator {
+	return &MapIterator{v: v, h: h, k: keys, l: len(keys)}
+}
+
 // TypeName returns the name of the type.
 func (i *MapIterator) TypeName() string {
 	return "map-iterator"
@@ -138,25 +148,184 @@ func (i *MapIterator) Equals(Object) bool {
 
 // Copy returns a copy of the type.
 func (i *MapIterator) Copy() Object {
-	return &MapIterator{v: i.v, k: i.k, i: i.i, l: i.l}
+	return &MapIterator{v: i.v, h: i.h, k: i.k, i: i.i, l: i.l}
 }
 
-// Next returns true if there are more elements to iterate.
+// Next returns true if there are more elements to iterate. Elements removed
+// from the map after the iterator was created are skipped.
 func (i *MapIterator) Next() bool {
-	i.i++
-	return i.i <= i.l
+	for i.i++; i.i <= i.l; i.i++ {
+		if i.k[i.i-1].exists(i.v, i.h) {
+			return true
+		}
+	}
+	return false
 }
 
 // Key returns the key or index value of the current element.
 func (i *MapIterator) Key() Object {
 	k := i.k[i.i-1]
-	return &String{Value: k}
+	if k.hashed {
+		return i.h[k.key].Key
+	}
+	return &String{Value: k.key}
 }
 
 // Value returns the value of the current element.
 func (i *MapIterator) Value() Object {
 	k := i.k[i.i-1]
-	return i.v[k]
+	if k.hashed {
+		return i.h[k.key].Value
+	}
+	return i.v[k.key]
+}
+
+// ProxyIterator is an iterator for a proxy.
+type ProxyIterator struct {
+	ObjectImpl
+	entries []MapEntry
+	i       int
+	l       int
+}
+
+// TypeName returns the name of the type.
+func (i *ProxyIterator) TypeName() string {
+	return "proxy-iterator"
+}
+
+func (i *ProxyIterator) String() string {
+	return "<proxy-iterator>"
+}
+
+// IsFalsy returns true if the value of the type is falsy.
+func (i *ProxyIterator) IsFalsy() bool {
+	return true
+}
+
+// Equals returns true if the value of the type is equal to the value of
+// another object.
+func (i *ProxyIterator) Equals(Object) bool {
+	return false
+}
+
+// Copy returns a copy of the type.
+func (i *ProxyIterator) Copy() Object {
+	return &ProxyIterator{entries: i.entries, i: i.i, l: i.l}
+}
+
+// Next returns true if there are more elements to iterate.
+func (i *ProxyIterator) Next() bool {
+	i.i++
+	return i.i <= i.l
+}
+
+// Key returns the key or index value of the current element.
+func (i *ProxyIterator) Key() Object {
+	return i.entries[i.i-1].Key
+}
+
+// Value returns the value of the current element.
+func (i *ProxyIterator) Value() Object {
+	return i.entries[i.i-1].Value
+}
+
+// RangeIterator is an iterator for a range.
+type RangeIterator struct {
+	ObjectImpl
+	r *Range
+	i int64
+	l int64
+}
+
+// TypeName returns the name of the type.
+func (i *RangeIterator) TypeName() string {
+	return "range-iterator"
+}
+
+func (i *RangeIterator) String() string {
+	return "<range-iterator>"
+}
+
+// IsFalsy returns true if the value of the type is falsy.
+func (i *RangeIterator) IsFalsy() bool {
+	return true
+}
+
+// Equals returns true if the value of the type is equal to the value of
+// another object.
+func (i *RangeIterator) Equals(Object) bool {
+	return false
+}
+
+// Copy returns a copy of the type.
+func (i *RangeIterator) Copy() Object {
+	return &RangeIterator{r: i.r, i: i.i, l: i.l}
+}
+
+// Next returns true if there are more elements to iterate.
+func (i *RangeIterator) Next() bool {
+	i.i++
+	return i.i <= i.l
+}
+
+// Key returns the key or index value of the current element.
+func (i *RangeIterator) Key() Object {
+	return &Int{Value: i.i - 1}
+}
+
+// Value returns the value of the current element.
+func (i *RangeIterator) Value() Object {
+	return &Int{Value: i.r.at(i.i - 1)}
+}
+
+// SetIterator is an iterator for a set. The key of an element is its index
+// in the order the elements were added.
+type SetIterator struct {
+	ObjectImpl
+	v []Object
+	i int
+	l int
+}
+
+// TypeName returns the name of the type.
+func (i *SetIterator) TypeName() string {
+	return "set-iterator"
+}
+
+func (i *SetIterator) String() string {
+	return "<set-iterator>"
+}
+
+// IsFalsy returns true if the value of the type is falsy.
+func (i *SetIterator) IsFalsy() bool {
+	return true
+}
+
+// Equals returns true if the value of the type is equal to the value of
+// another object.
+func (i *SetIterator) Equals(Object) bool {
+	return false
+}
+
+// Copy returns a copy of the type.
+func (i *SetIterator) Copy() Object {
+	return &SetIterator{v: i.v, i: i.i, l: i.l}
+}
+
+// Next returns true if there are more elements to iterate.
+func (i *SetIterator) Next() bool {
+	i.i++
+	return i.i <= i.l
+}
+
+// Key returns the key or index value of the current element.
+func (i *SetIterator) Key() Object {
+	return &Int{Value: int64(i.i - 1)}
+}
+
+// Value returns the value of the current element.
+func (i *SetIterator) Value() Object {
+	return i.v[i.i-1]
 }
 
 // StringIterator represents an iterator for a string.
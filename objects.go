@@ -2,10 +2,16 @@ package tengo
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"math"
+	"math/big"
+	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/d5/tengo/v2/parser"
@@ -79,6 +85,31 @@ type Object interface {
 	CanCall() bool
 }
 
+// Hashable is implemented by objects that can be used as map keys and set
+// elements. Objects of the same type that are equal should return the same
+// hash key, and, objects that are not equal should return different keys.
+// Objects of different types never share a key, so the key does not need to
+// include the type name.
+type Hashable interface {
+	// HashKey should return the key that identifies the value of the object,
+	// or, false if the value cannot be hashed (e.g. an immutable array with a
+	// mutable element).
+	HashKey() (string, bool)
+}
+
+// CallableContext is implemented by callable objects that receive the
+// context of the run. The VM calls CallContext instead of Call with the
+// context passed to RunContext, or, context.Background if the script is run
+// without a context. This includes the objects called by the builtin
+// functions in the VM, e.g. the comparison function of sort_by. Call is used
+// when the object is called outside the VM, e.g. by a Go function, and,
+// should behave like CallContext with context.Background.
+type CallableContext interface {
+	// CallContext should take the context and the arguments and return a
+	// value and an error like Call does.
+	CallContext(ctx context.Context, args ...Object) (Object, error)
+}
+
 // ObjectImpl represents a default Object Implementation. To defined a new
 // value type, one can embed ObjectImpl in their type declarations to avoid
 // implementing all non-significant methods. TypeName() and String() methods
@@ -263,6 +294,149 @@ func (o *Array) CanIterate() bool {
 	return true
 }
 
+// BigInt represents an arbitrary-precision integer value.
+type BigInt struct {
+	ObjectImpl
+	Value *big.Int
+}
+
+func (o *BigInt) String() string {
+	return o.Value.String()
+}
+
+// TypeName returns the name of the type.
+func (o *BigInt) TypeName() string {
+	return "bigint"
+}
+
+// BinaryOp returns another object that is the result of a given binary
+// operator and a right-hand side object.
+func (o *BigInt) BinaryOp(op token.Token, rhs Object) (Object, error) {
+	var y *big.Int
+	switch rhs := rhs.(type) {
+	case *BigInt:
+		y = rhs.Value
+	case *Int:
+		y = big.NewInt(rhs.Value)
+	case *Float:
+		return (&Float{Value: o.float64()}).BinaryOp(op, rhs)
+	case *Decimal:
+		return decimalFromBigInt(o.Value).BinaryOp(op, rhs)
+	default:
+		return nil, ErrInvalidOperator
+	}
+
+	x := o.Value
+	switch op {
+	case token.Add:
+		return &BigInt{Value: new(big.Int).Add(x, y)}, nil
+	case token.Sub:
+		return &BigInt{Value: new(big.Int).Sub(x, y)}, nil
+	case token.Mul:
+		if err := checkBigMul(x, y); err != nil {
+			return nil, err
+		}
+		return &BigInt{Value: new(big.Int).Mul(x, y)}, nil
+	case token.Quo:
+		if y.Sign() == 0 {
+			return nil, ErrDivisionByZero
+		}
+		return &BigInt{Value: new(big.Int).Quo(x, y)}, nil
+	case token.Rem:
+		if y.Sign() == 0 {
+			return nil, ErrDivisionByZero
+		}
+		return &BigInt{Value: new(big.Int).Rem(x, y)}, nil
+	case token.And:
+		return &BigInt{Value: new(big.Int).And(x, y)}, nil
+	case token.Or:
+		return &BigInt{Value: new(big.Int).Or(x, y)}, nil
+	case token.Xor:
+		return &BigInt{Value: new(big.Int).Xor(x, y)}, nil
+	case token.AndNot:
+		return &BigInt{Value: new(big.Int).AndNot(x, y)}, nil
+	case token.Shl, token.Shr:
+		if y.Sign() < 0 || !y.IsInt64() {
+			return nil, ErrInvalidOperator
+		}
+		if op == token.Shl {
+			if x.Sign() != 0 && y.Sign() > 0 &&
+				y.Int64() > int64(MaxBigIntBits-x.BitLen()) {
+				return nil, ErrBigIntLimit
+			}
+			return &BigInt{Value: new(big.Int).Lsh(x, uint(y.Int64()))}, nil
+		}
+		return &BigInt{Value: new(big.Int).Rsh(x, uint(y.Int64()))}, nil
+	case token.Pow:
+		if y.Sign() < 0 {
+			return &Float{
+				Value: math.Pow(o.float64(), (&BigInt{Value: y}).float64()),
+			}, nil
+		}
+		if x.BitLen() > 1 {
+			if !y.IsInt64() {
+				return nil, ErrBigIntLimit
+			}
+			if err := checkBigPow(x, y.Int64()); err != nil {
+				return nil, err
+			}
+		}
+		return &BigInt{Value: new(big.Int).Exp(x, y, nil)}, nil
+	case token.Less:
+		if x.Cmp(y) < 0 {
+			return TrueValue, nil
+		}
+		return FalseValue, nil
+	case token.Greater:
+		if x.Cmp(y) > 0 {
+			return TrueValue, nil
+		}
+		return FalseValue, nil
+	case token.LessEq:
+		if x.Cmp(y) <= 0 {
+			return TrueValue, nil
+		}
+		return FalseValue, nil
+	case token.GreaterEq:
+		if x.Cmp(y) >= 0 {
+			return TrueValue, nil
+		}
+		return FalseValue, nil
+	}
+	return nil, ErrInvalidOperator
+}
+
+// float64 returns the nearest float64 value of o.
+func (o *BigInt) float64() float64 {
+	f, _ := new(big.Float).SetInt(o.Value).Float64()
+	return f
+}
+
+// Copy returns a copy of the type.
+func (o *BigInt) Copy() Object {
+	return &BigInt{Value: new(big.Int).Set(o.Value)}
+}
+
+// IsFalsy returns true if the value of the type is falsy.
+func (o *BigInt) IsFalsy() bool {
+	return o.Value.Sign() == 0
+}
+
+// Equals returns true if the value of the type is equal to the value of
+// another object.
+func (o *BigInt) Equals(x Object) bool {
+	t, ok := x.(*BigInt)
+	if !ok {
+		return false
+	}
+	return o.Value.Cmp(t.Value) == 0
+}
+
+// HashKey returns the key that identifies the value of the type.
+func (o *BigInt) HashKey() (string, bool) {
+	return o.Value.String(), true
+}
+
 // Bool represents a boolean value.
 type Bool struct {
 	ObjectImpl
@@ -301,6 +475,11 @@ func (o *Bool) Equals(x Object) bool {
 	return o == x
 }
 
+// HashKey returns the key that identifies the value of the type.
+func (o *Bool) HashKey() (string, bool) {
+	return o.String(), true
+}
+
 // GobDecode decodes bool value from input bytes.
 func (o *Bool) GobDecode(b []byte) (err error) {
 	o.value = b[0] == 1
@@ -317,6 +496,49 @@ func (o *Bool) GobEncode() (b []byte, err error) {
 	return
 }
 
+// BoundMethod represents a method of a record bound to its receiver. Calling
+// a bound method calls the method with the receiver as the first argument.
+type BoundMethod struct {
+	ObjectImpl
+	Receiver Object
+	Method   *CompiledFunction
+}
+
+// TypeName returns the name of the type.
+func (o *BoundMethod) TypeName() string {
+	return "bound-method"
+}
+
+func (o *BoundMethod) String() string {
+	return "<bound-method>"
+}
+
+// Copy returns a copy of the type.
+func (o *BoundMethod) Copy() Object {
+	return &BoundMethod{Receiver: o.Receiver, Method: o.Method}
+}
+
+// Equals returns true if the value of the type is equal to the value of
+// another object.
+func (o *BoundMethod) Equals(x Object) bool {
+	t, ok := x.(*BoundMethod)
+	if !ok {
+		return false
+	}
+	return o.Receiver == t.Receiver && o.Method == t.Method
+}
+
+// CanCall returns whether the Object can be Called.
+func (o *BoundMethod) CanCall() bool {
+	return true
+}
+
+// Call returns ErrCompiledFunctionCall as the method is a compiled function
+// that can only be called by the VM.
+func (o *BoundMethod) Call(_ ...Object) (Object, error) {
+	return nil, ErrCompiledFunctionCall
+}
+
 // BuiltinFunction represents a builtin function.
 type BuiltinFunction struct {
 	ObjectImpl
@@ -567,6 +789,11 @@ func (o *Char) Equals(x Object) bool {
 	return o.Value == t.Value
 }
 
+// HashKey returns the key that identifies the value of the type.
+func (o *Char) HashKey() (string, bool) {
+	return string(o.Value), true
+}
+
 // CompiledFunction represents a compiled function.
 type CompiledFunction struct {
 	ObjectImpl
@@ -620,6 +847,130 @@ func (o *CompiledFunction) CanCall() bool {
 	return true
 }
 
+// Decimal represents an arbitrary-precision decimal number value. Its value
+// is Value * 10**-Scale, so a Decimal with Value 1250 and Scale 2 is 12.50.
+type Decimal struct {
+	ObjectImpl
+	Value *big.Int
+	Scale int
+}
+
+func (o *Decimal) String() string {
+	s := new(big.Int).Abs(o.Value).String()
+	if o.Scale > 0 {
+		if len(s) <= o.Scale {
+			s = strings.Repeat("0", o.Scale-len(s)+1) + s
+		}
+		s = s[:len(s)-o.Scale] + "." + s[len(s)-o.Scale:]
+	}
+	if o.Value.Sign() < 0 {
+		return "-" + s
+	}
+	return s
+}
+
+// TypeName returns the name of the type.
+func (o *Decimal) TypeName() string {
+	return "decimal"
+}
+
+// BinaryOp returns another object that is the result of a given binary
+// operator and a right-hand side object.
+func (o *Decimal) BinaryOp(op token.Token, rhs Object) (Object, error) {
+	if op == token.Pow {
+		switch rhs := rhs.(type) {
+		case *Int:
+			return decimalPow(o, rhs.Value)
+		case *BigInt:
+			if rhs.Value.IsInt64() {
+				return decimalPow(o, rhs.Value.Int64())
+			}
+		}
+		return nil, ErrInvalidOperator
+	}
+
+	y, ok := toDecimal(rhs)
+	if !ok {
+		return nil, ErrInvalidOperator
+	}
+
+	switch op {
+	case token.Add:
+		a, b, scale := alignDecimals(o, y)
+		return &Decimal{Value: new(big.Int).Add(a, b), Scale: scale}, nil
+	case token.Sub:
+		a, b, scale := alignDecimals(o, y)
+		return &Decimal{Value: new(big.Int).Sub(a, b), Scale: scale}, nil
+	case token.Mul:
+		if err := checkBigMul(o.Value, y.Value); err != nil {
+			return nil, err
+		}
+		return &Decimal{
+			Value: new(big.Int).Mul(o.Value, y.Value),
+			Scale: o.Scale + y.Scale,
+		}, nil
+	case token.Quo:
+		if y.Value.Sign() == 0 {
+			return nil, ErrDivisionByZero
+		}
+		return decimalQuo(o, y), nil
+	case token.Rem:
+		if y.Value.Sign() == 0 {
+			return nil, ErrDivisionByZero
+		}
+		a, b, scale := alignDecimals(o, y)
+		return &Decimal{Value: new(big.Int).Rem(a, b), Scale: scale}, nil
+	case token.Less:
+		if o.cmp(y) < 0 {
+			return TrueValue, nil
+		}
+		return FalseValue, nil
+	case token.Greater:
+		if o.cmp(y) > 0 {
+			return TrueValue, nil
+		}
+		return FalseValue, nil
+	case token.LessEq:
+		if o.cmp(y) <= 0 {
+			return TrueValue, nil
+		}
+		return FalseValue, nil
+	case token.GreaterEq:
+		if o.cmp(y) >= 0 {
+			return TrueValue, nil
+		}
+		return FalseValue, nil
+	}
+	return nil, ErrInvalidOperator
+}
+
+// Copy returns a copy of the type.
+func (o *Decimal) Copy() Object {
+	return &Decimal{Value: new(big.Int).Set(o.Value), Scale: o.Scale}
+}
+
+// IsFalsy returns true if the value of the type is falsy.
+func (o *Decimal) IsFalsy() bool {
+	return o.Value.Sign() == 0
+}
+
+// Equals returns true if the value of the type is equal to the value of
+// another object. Decimals of the same value are equal regardless of their
+// scales.
+func (o *Decimal) Equals(x Object) bool {
+	t, ok := x.(*Decimal)
+	if !ok {
+		return false
+	}
+	return o.cmp(t) == 0
+}
+
+// HashKey returns the key that identifies the value of the type. Decimals
+// that differ only in scale have the same key.
+func (o *Decimal) HashKey() (string, bool) {
+	return o.trim(0).String(), true
+}
+
 // Error represents an error value.
 type Error struct {
 	ObjectImpl
@@ -704,11 +1055,20 @@ func (o *Float) BinaryOp(op token.Token, rhs Object) (Object, error) {
 			}
 			return &Float{Value: r}, nil
 		case token.Quo:
+			if rhs.Value == 0 {
+				return nil, ErrDivisionByZero
+			}
 			r := o.Value / rhs.Value
 			if r == o.Value {
 				return o, nil
 			}
 			return &Float{Value: r}, nil
+		case token.Pow:
+			r := math.Pow(o.Value, rhs.Value)
+			if r == o.Value {
+				return o, nil
+			}
+			return &Float{Value: r}, nil
 		case token.Less:
 			if o.Value < rhs.Value {
 				return TrueValue, nil
@@ -751,11 +1111,20 @@ func (o *Float) BinaryOp(op token.Token, rhs Object) (Object, error) {
 			}
 			return &Float{Value: r}, nil
 		case token.Quo:
+			if rhs.Value == 0 {
+				return nil, ErrDivisionByZero
+			}
 			r := o.Value / float64(rhs.Value)
 			if r == o.Value {
 				return o, nil
 			}
 			return &Float{Value: r}, nil
+		case token.Pow:
+			r := math.Pow(o.Value, float64(rhs.Value))
+			if r == o.Value {
+				return o, nil
+			}
+			return &Float{Value: r}, nil
 		case token.Less:
 			if o.Value < float64(rhs.Value) {
 				return TrueValue, nil
@@ -777,6 +1146,14 @@ func (o *Float) BinaryOp(op token.Token, rhs Object) (Object, error) {
 			}
 			return FalseValue, nil
 		}
+	case *BigInt:
+		return o.BinaryOp(op, &Float{Value: rhs.float64()})
+	case *Decimal:
+		d, ok := decimalFromFloat(o.Value)
+		if !ok {
+			return nil, ErrInvalidOperator
+		}
+		return d.BinaryOp(op, rhs)
 	}
 	return nil, ErrInvalidOperator
 }
@@ -801,6 +1178,14 @@ func (o *Float) Equals(x Object) bool {
 	return o.Value == t.Value
 }
 
+// HashKey returns the key that identifies the value of the type.
+func (o *Float) HashKey() (string, bool) {
+	if o.Value == 0 {
+		return "0", true // -0 == 0
+	}
+	return strconv.FormatFloat(o.Value, 'g', -1, 64), true
+}
+
 // ImmutableArray represents an immutable array of objects.
 type ImmutableArray struct {
 	ObjectImpl
@@ -869,6 +1254,22 @@ func (o *ImmutableArray) Equals(x Object) bool {
 	return true
 }
 
+// HashKey returns the key that identifies the value of the type. An
+// immutable array is hashable only if all of its elements are hashable.
+func (o *ImmutableArray) HashKey() (string, bool) {
+	var b strings.Builder
+	for _, e := range o.Value {
+		key, ok := hashKey(e)
+		if !ok {
+			return "", false
+		}
+		b.WriteString(strconv.Itoa(len(key)))
+		b.WriteByte(':')
+		b.WriteString(key)
+	}
+	return b.String(), true
+}
+
 // IndexGet returns an element at a given index.
 func (o *ImmutableArray) IndexGet(index Object) (res Object, err error) {
 	intIdx, ok := index.(*Int)
@@ -902,6 +1303,10 @@ func (o *ImmutableArray) CanIterate() bool {
 type ImmutableMap struct {
 	ObjectImpl
 	Value map[string]Object
+	// Hashed holds the elements whose keys are hashable values other than
+	// strings, indexed by the hash keys of their keys.
+	Hashed map[string]MapEntry
+	order  []mapKey
 }
 
 // TypeName returns the name of the type.
@@ -910,80 +1315,108 @@ func (o *ImmutableMap) TypeName() string {
 }
 
 func (o *ImmutableMap) String() string {
-	var pairs []string
-	for k, v := range o.Value {
-		pairs = append(pairs, fmt.Sprintf("%s: %s", k, v.String()))
-	}
-	return fmt.Sprintf("{%s}", strings.Join(pairs, ", "))
+	return mapString(o.Value, o.Hashed, o.keys())
 }
 
 // Copy returns a copy of the type.
 func (o *ImmutableMap) Copy() Object {
-	c := make(map[string]Object)
-	for k, v := range o.Value {
-		c[k] = v.Copy()
-	}
-	return &Map{Value: c}
+	v, h := mapCopy(o.Value, o.Hashed)
+	return &Map{Value: v, Hashed: h, order: append([]mapKey{}, o.keys()...)}
 }
 
 // IsFalsy returns true if the value of the type is falsy.
 func (o *ImmutableMap) IsFalsy() bool {
-	return len(o.Value) == 0
+	return len(o.Value) == 0 && len(o.Hashed) == 0
 }
 
 // IndexGet returns the value for the given key.
 func (o *ImmutableMap) IndexGet(index Object) (res Object, err error) {
-	strIdx, ok := ToString(index)
-	if !ok {
-		err = ErrInvalidIndexType
-		return
-	}
-	res, ok = o.Value[strIdx]
-	if !ok {
-		res = UndefinedValue
-	}
-	return
+	return mapIndexGet(o.Value, o.Hashed, index)
 }
 
 // Equals returns true if the value of the type is equal to the value of
 // another object.
 func (o *ImmutableMap) Equals(x Object) bool {
-	var xVal map[string]Object
-	switch x := x.(type) {
-	case *Map:
-		xVal = x.Value
-	case *ImmutableMap:
-		xVal = x.Value
-	default:
-		return false
-	}
-	if len(o.Value) != len(xVal) {
-		return false
-	}
-	for k, v := range o.Value {
-		tv := xVal[k]
-		if !v.Equals(tv) {
-			return false
-		}
-	}
-	return true
+	return mapEquals(o.Value, o.Hashed, x)
 }
 
 // Iterate creates an immutable map iterator.
 func (o *ImmutableMap) Iterate() Iterator {
-	var keys []string
-	for k := range o.Value {
-		keys = append(keys, k)
+	return newMapIterator(o.Value, o.Hashed, o.keys())
+}
+
+// CanIterate returns whether the Object can be Iterated.
+func (o *ImmutableMap) CanIterate() bool {
+	return true
+}
+
+// keys returns the keys in order.
+func (o *ImmutableMap) keys() []mapKey {
+	return mapOrder(o.Value, o.Hashed, o.order)
+}
+
+// ImmutableSet represents an immutable set of unique values.
+type ImmutableSet struct {
+	ObjectImpl
+	setElems
+}
+
+// NewImmutableSet returns an immutable set of the given elements. It returns
+// ErrNotHashable if an element cannot be a set element.
+func NewImmutableSet(elems ...Object) (*ImmutableSet, error) {
+	s, err := NewSet(elems...)
+	if err != nil {
+		return nil, err
 	}
-	return &MapIterator{
-		v: o.Value,
-		k: keys,
-		l: len(keys),
+	return &ImmutableSet{setElems: s.setElems}, nil
+}
+
+// TypeName returns the name of the type.
+func (o *ImmutableSet) TypeName() string {
+	return "immutable-set"
+}
+
+func (o *ImmutableSet) String() string {
+	return o.string()
+}
+
+// BinaryOp returns another object that is the result of a given binary
+// operator and a right-hand side object.
+func (o *ImmutableSet) BinaryOp(op token.Token, rhs Object) (Object, error) {
+	return o.binaryOp(op, rhs)
+}
+
+// Copy returns a copy of the type.
+func (o *ImmutableSet) Copy() Object {
+	return &Set{setElems: o.copy()}
+}
+
+// IsFalsy returns true if the value of the type is falsy.
+func (o *ImmutableSet) IsFalsy() bool {
+	return len(o.elems) == 0
+}
+
+// Equals returns true if the value of the type is equal to the value of
+// another object.
+func (o *ImmutableSet) Equals(x Object) bool {
+	return o.equals(x)
+}
+
+// IndexGet returns true if the index is an element of the set.
+func (o *ImmutableSet) IndexGet(index Object) (Object, error) {
+	if o.Has(index) {
+		return TrueValue, nil
 	}
+	return FalseValue, nil
+}
+
+// Iterate creates a set iterator.
+func (o *ImmutableSet) Iterate() Iterator {
+	return &SetIterator{v: o.elems, l: len(o.elems)}
 }
 
 // CanIterate returns whether the Object can be Iterated.
-func (o *ImmutableMap) CanIterate() bool {
+func (o *ImmutableSet) CanIterate() bool {
 	return true
 }
 
@@ -1027,12 +1460,18 @@ func (o *Int) BinaryOp(op token.Token, rhs Object) (Object, error) {
 			}
 			return &Int{Value: r}, nil
 		case token.Quo:
+			if rhs.Value == 0 {
+				return nil, ErrDivisionByZero
+			}
 			r := o.Value / rhs.Value
 			if r == o.Value {
 				return o, nil
 			}
 			return &Int{Value: r}, nil
 		case token.Rem:
+			if rhs.Value == 0 {
+				return nil, ErrDivisionByZero
+			}
 			r := o.Value % rhs.Value
 			if r == o.Value {
 				return o, nil
@@ -1074,6 +1513,17 @@ func (o *Int) BinaryOp(op token.Token, rhs Object) (Object, error) {
 				return o, nil
 			}
 			return &Int{Value: r}, nil
+		case token.Pow:
+			if rhs.Value < 0 {
+				return &Float{
+					Value: math.Pow(float64(o.Value), float64(rhs.Value)),
+				}, nil
+			}
+			r := intPow(o.Value, rhs.Value)
+			if r == o.Value {
+				return o, nil
+			}
+			return &Int{Value: r}, nil
 		case token.Less:
 			if o.Value < rhs.Value {
 				return TrueValue, nil
@@ -1104,7 +1554,12 @@ func (o *Int) BinaryOp(op token.Token, rhs Object) (Object, error) {
 		case token.Mul:
 			return &Float{Value: float64(o.Value) * rhs.Value}, nil
 		case token.Quo:
+			if rhs.Value == 0 {
+				return nil, ErrDivisionByZero
+			}
 			return &Float{Value: float64(o.Value) / rhs.Value}, nil
+		case token.Pow:
+			return &Float{Value: math.Pow(float64(o.Value), rhs.Value)}, nil
 		case token.Less:
 			if float64(o.Value) < rhs.Value {
 				return TrueValue, nil
@@ -1126,6 +1581,10 @@ func (o *Int) BinaryOp(op token.Token, rhs Object) (Object, error) {
 			}
 			return FalseValue, nil
 		}
+	case *BigInt:
+		return (&BigInt{Value: big.NewInt(o.Value)}).BinaryOp(op, rhs)
+	case *Decimal:
+		return decimalFromInt(o.Value).BinaryOp(op, rhs)
 	case *Char:
 		switch op {
 		case token.Add:
@@ -1157,6 +1616,18 @@ func (o *Int) BinaryOp(op token.Token, rhs Object) (Object, error) {
 	return nil, ErrInvalidOperator
 }
 
+// intPow returns x**y for a non-negative y, wrapping around on overflow.
+func intPow(x, y int64) int64 {
+	r := int64(1)
+	for ; y > 0; y >>= 1 {
+		if y&1 == 1 {
+			r *= x
+		}
+		x *= x
+	}
+	return r
+}
+
 // Copy returns a copy of the type.
 func (o *Int) Copy() Object {
 	return &Int{Value: o.Value}
@@ -1177,10 +1648,22 @@ func (o *Int) Equals(x Object) bool {
 	return o.Value == t.Value
 }
 
-// Map represents a map of objects.
+// HashKey returns the key that identifies the value of the type.
+func (o *Int) HashKey() (string, bool) {
+	return strconv.FormatInt(o.Value, 10), true
+}
+
+// Map represents a map of objects. String keys are stored in Value, and,
+// other hashable keys (see Hashable) are stored in Hashed. A map keeps the
+// order in which elements were added with IndexSet. Elements added to Value
+// or Hashed directly follow them in the order of their keys.
 type Map struct {
 	ObjectImpl
 	Value map[string]Object
+	// Hashed holds the elements whose keys are hashable values other than
+	// strings, indexed by the hash keys of their keys.
+	Hashed map[string]MapEntry
+	order  []mapKey
 }
 
 // TypeName returns the name of the type.
@@ -1189,87 +1672,89 @@ func (o *Map) TypeName() string {
 }
 
 func (o *Map) String() string {
-	var pairs []string
-	for k, v := range o.Value {
-		pairs = append(pairs, fmt.Sprintf("%s: %s", k, v.String()))
-	}
-	return fmt.Sprintf("{%s}", strings.Join(pairs, ", "))
+	return mapString(o.Value, o.Hashed, o.keys())
 }
 
 // Copy returns a copy of the type.
 func (o *Map) Copy() Object {
-	c := make(map[string]Object)
-	for k, v := range o.Value {
-		c[k] = v.Copy()
-	}
-	return &Map{Value: c}
+	v, h := mapCopy(o.Value, o.Hashed)
+	return &Map{Value: v, Hashed: h, order: append([]mapKey{}, o.keys()...)}
 }
 
 // IsFalsy returns true if the value of the type is falsy.
 func (o *Map) IsFalsy() bool {
-	return len(o.Value) == 0
+	return len(o.Value) == 0 && len(o.Hashed) == 0
 }
 
 // Equals returns true if the value of the type is equal to the value of
 // another object.
 func (o *Map) Equals(x Object) bool {
-	var xVal map[string]Object
-	switch x := x.(type) {
-	case *Map:
-		xVal = x.Value
-	case *ImmutableMap:
-		xVal = x.Value
-	default:
-		return false
-	}
-	if len(o.Value) != len(xVal) {
-		return false
-	}
-	for k, v := range o.Value {
-		tv := xVal[k]
-		if !v.Equals(tv) {
-			return false
-		}
-	}
-	return true
+	return mapEquals(o.Value, o.Hashed, x)
 }
 
 // IndexGet returns the value for the given key.
 func (o *Map) IndexGet(index Object) (res Object, err error) {
-	strIdx, ok := ToString(index)
-	if !ok {
-		err = ErrInvalidIndexType
-		return
-	}
-	res, ok = o.Value[strIdx]
-	if !ok {
-		res = UndefinedValue
-	}
-	return
+	return mapIndexGet(o.Value, o.Hashed, index)
 }
 
 // IndexSet sets the value for the given key.
 func (o *Map) IndexSet(index, value Object) (err error) {
-	strIdx, ok := ToString(index)
+	if str, ok := index.(*String); ok {
+		if _, exists := o.Value[str.Value]; !exists {
+			o.addKey(mapKey{key: str.Value})
+		}
+		o.Value[str.Value] = value
+		return nil
+	}
+	key, ok := hashKey(index)
 	if !ok {
-		err = ErrInvalidIndexType
-		return
+		return ErrInvalidIndexType
+	}
+	if o.Hashed == nil {
+		o.Hashed = make(map[string]MapEntry)
 	}
-	o.Value[strIdx] = value
+	if _, exists := o.Hashed[key]; !exists {
+		o.addKey(mapKey{key: key, hashed: true})
+	}
+	o.Hashed[key] = MapEntry{Key: index, Value: value}
 	return nil
 }
 
+// Delete removes the element for the given key. It does nothing if the key
+// does not exist or is not hashable.
+func (o *Map) Delete(key Object) {
+	var mk mapKey
+	if str, ok := key.(*String); ok {
+		if _, exists := o.Value[str.Value]; !exists {
+			return
+		}
+		delete(o.Value, str.Value)
+		mk = mapKey{key: str.Value}
+	} else {
+		hk, ok := hashKey(key)
+		if !ok {
+			return
+		}
+		if _, exists := o.Hashed[hk]; !exists {
+			return
+		}
+		delete(o.Hashed, hk)
+		mk = mapKey{key: hk, hashed: true}
+	}
+	for i, k := range o.order {
+		if k == mk {
+			// the order can be shared with an immutable map or an iterator
+			order := make([]mapKey, 0, len(o.order)-1)
+			order = append(order, o.order[:i]...)
+			o.order = append(order, o.order[i+1:]...)
+			break
+		}
+	}
+}
+
 // Iterate creates a map iterator.
 func (o *Map) Iterate() Iterator {
-	var keys []string
-	for k := range o.Value {
-		keys = append(keys, k)
-	}
-	return &MapIterator{
-		v: o.Value,
-		k: keys,
-		l: len(keys),
-	}
+	return newMapIterator(o.Value, o.Hashed, o.keys())
 }
 
 // CanIterate returns whether the Object can be Iterated.
@@ -1277,6 +1762,197 @@ func (o *Map) CanIterate() bool {
 	return true
 }
 
+// keys returns the keys in order. It does not update the order so that
+// reading a map shared by concurrent runs does not write to it.
+func (o *Map) keys() []mapKey {
+	return mapOrder(o.Value, o.Hashed, o.order)
+}
+
+// addKey appends a key of a new element to the order. The elements added to
+// the Go maps directly are put in the order first.
+func (o *Map) addKey(k mapKey) {
+	if len(o.order) != len(o.Value)+len(o.Hashed) {
+		o.order = mapOrder(o.Value, o.Hashed, o.order)
+	}
+	o.order = append(o.order, k)
+}
+
+// MapEntry is an element of a map whose key is not a string.
+type MapEntry struct {
+	Key   Object
+	Value Object
+}
+
+// mapKey is the key of a map element in the insertion order: a key of Value,
+// or, a hash key of Hashed.
+type mapKey struct {
+	key    string
+	hashed bool
+}
+
+func (k mapKey) exists(v map[string]Object, h map[string]MapEntry) bool {
+	var ok bool
+	if k.hashed {
+		_, ok = h[k.key]
+	} else {
+		_, ok = v[k.key]
+	}
+	return ok
+}
+
+// mapOrder returns the keys of a map in the insertion order. Keys of the
+// removed elements are dropped, and, keys of the elements that are not in
+// order (added to the Go maps directly) follow in sorted order. It returns
+// order if it is up to date.
+func mapOrder(
+	v map[string]Object,
+	h map[string]MapEntry,
+	order []mapKey,
+) []mapKey {
+	n := len(v) + len(h)
+	if len(order) == n {
+		upToDate := true
+		for _, k := range order {
+			if !k.exists(v, h) {
+				upToDate = false
+				break
+			}
+		}
+		if upToDate {
+			return order
+		}
+	}
+
+	keys := make([]mapKey, 0, n)
+	seen := make(map[mapKey]bool, n)
+	for _, k := range order {
+		if !seen[k] && k.exists(v, h) {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+	rest := len(keys)
+	for k := range v {
+		if mk := (mapKey{key: k}); !seen[mk] {
+			keys = append(keys, mk)
+		}
+	}
+	for k := range h {
+		if mk := (mapKey{key: k, hashed: true}); !seen[mk] {
+			keys = append(keys, mk)
+		}
+	}
+	added := keys[rest:]
+	sort.Slice(added, func(i, j int) bool {
+		if added[i].hashed != added[j].hashed {
+			return !added[i].hashed
+		}
+		return added[i].key < added[j].key
+	})
+	return keys
+}
+
+func mapString(
+	v map[string]Object,
+	h map[string]MapEntry,
+	keys []mapKey,
+) string {
+	var pairs []string
+	for _, k := range keys {
+		if k.hashed {
+			e := h[k.key]
+			pairs = append(pairs, fmt.Sprintf("%s: %s",
+				e.Key.String(), e.Value.String()))
+		} else {
+			pairs = append(pairs, fmt.Sprintf("%s: %s",
+				k.key, v[k.key].String()))
+		}
+	}
+	return fmt.Sprintf("{%s}", strings.Join(pairs, ", "))
+}
+
+func mapCopy(
+	v map[string]Object,
+	h map[string]MapEntry,
+) (map[string]Object, map[string]MapEntry) {
+	cv := make(map[string]Object)
+	for k, v := range v {
+		cv[k] = v.Copy()
+	}
+	var ch map[string]MapEntry
+	if len(h) > 0 {
+		ch = make(map[string]MapEntry, len(h))
+		for k, e := range h {
+			// hashable keys are immutable, so they are shared
+			ch[k] = MapEntry{Key: e.Key, Value: e.Value.Copy()}
+		}
+	}
+	return cv, ch
+}
+
+func mapIndexGet(
+	v map[string]Object,
+	h map[string]MapEntry,
+	index Object,
+) (Object, error) {
+	if str, ok := index.(*String); ok {
+		if res, ok := v[str.Value]; ok {
+			return res, nil
+		}
+		return UndefinedValue, nil
+	}
+	key, ok := hashKey(index)
+	if !ok {
+		return nil, ErrInvalidIndexType
+	}
+	if e, ok := h[key]; ok {
+		return e.Value, nil
+	}
+	return UndefinedValue, nil
+}
+
+func mapHas(v map[string]Object, h map[string]MapEntry, key Object) bool {
+	if str, ok := key.(*String); ok {
+		_, ok = v[str.Value]
+		return ok
+	}
+	hk, ok := hashKey(key)
+	if !ok {
+		return false
+	}
+	_, ok = h[hk]
+	return ok
+}
+
+func mapEquals(v map[string]Object, h map[string]MapEntry, x Object) bool {
+	var xv map[string]Object
+	var xh map[string]MapEntry
+	switch x := x.(type) {
+	case *Map:
+		xv, xh = x.Value, x.Hashed
+	case *ImmutableMap:
+		xv, xh = x.Value, x.Hashed
+	default:
+		return false
+	}
+	if len(v) != len(xv) || len(h) != len(xh) {
+		return false
+	}
+	for k, v := range v {
+		tv, ok := xv[k]
+		if !ok || !v.Equals(tv) {
+			return false
+		}
+	}
+	for k, e := range h {
+		te, ok := xh[k]
+		if !ok || !e.Value.Equals(te.Value) {
+			return false
+		}
+	}
+	return true
+}
+
 // ObjectPtr represents a free variable.
 type ObjectPtr struct {
 	ObjectImpl
@@ -1308,6 +1984,920 @@ func (o *ObjectPtr) Equals(x Object) bool {
 	return o == x
 }
 
+// Proxy represents a Go value exposed to the scripts by reference. Reading
+// and writing the fields of a struct, the elements of a map or a slice through
+// a proxy reads and writes the Go value directly, and, the exported methods
+// of the value can be called as functions. Nested structs, maps and slices
+// are exposed as proxies as well. Struct values stored in maps are not
+// addressable: they are exposed as read-only proxies of their copies, and,
+// setting their fields returns ErrNotIndexAssignable. Store pointers to the
+// structs in the maps to modify them through proxies. Use NewProxy to create
+// a Proxy.
+type Proxy struct {
+	ObjectImpl
+	v        reflect.Value // pointer to struct, map, slice or pointer to them
+	members  map[string]bool
+	prefix   string
+	readOnly bool
+	methods  sync.Map // name to *UserFunction
+}
+
+// NewProxy creates a Proxy for v which must be a non-nil pointer to a struct,
+// or, a map or a slice (or a pointer to them). If members are given, only the
+// struct fields and the methods of those names are exposed; the members of
+// the nested structs are named by their paths (e.g. "Owner.Name").
+func NewProxy(v interface{}, members ...string) (*Proxy, error) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return nil, errors.New("proxy of nil pointer")
+		}
+		switch rv.Elem().Kind() {
+		case reflect.Struct, reflect.Map, reflect.Slice:
+		default:
+			return nil, fmt.Errorf("proxy of unsupported type: %T", v)
+		}
+	case reflect.Map, reflect.Slice:
+	default:
+		return nil, fmt.Errorf("proxy of unsupported type: %T", v)
+	}
+
+	p := &Proxy{v: rv}
+	if len(members) > 0 {
+		p.members = make(map[string]bool, len(members))
+		for _, m := range members {
+			p.members[m] = true
+		}
+	}
+	return p, nil
+}
+
+// TypeName returns the name of the type.
+func (o *Proxy) TypeName() string {
+	return "proxy:" + o.v.Type().String()
+}
+
+func (o *Proxy) String() string {
+	return fmt.Sprintf("%v", o.target().Interface())
+}
+
+// Value returns the Go value of the proxy.
+func (o *Proxy) Value() interface{} {
+	return o.v.Interface()
+}
+
+// Copy returns the proxy itself as it refers to the Go value.
+func (o *Proxy) Copy() Object {
+	return o
+}
+
+// IsFalsy returns true if the value of the type is falsy.
+func (o *Proxy) IsFalsy() bool {
+	t := o.target()
+	switch t.Kind() {
+	case reflect.Map, reflect.Slice:
+		return t.Len() == 0
+	}
+	return false
+}
+
+// Equals returns true if the value of the type is equal to the value of
+// another object.
+func (o *Proxy) Equals(x Object) bool {
+	p, ok := x.(*Proxy)
+	if !ok || o.v.Type() != p.v.Type() {
+		return false
+	}
+	if o.v.Kind() == reflect.Slice && o.v.Len() != p.v.Len() {
+		return false
+	}
+	return o.v.Pointer() == p.v.Pointer()
+}
+
+// IndexGet returns the value of the struct field, the element of the map or
+// the slice, or the method for the given index.
+func (o *Proxy) IndexGet(index Object) (Object, error) {
+	t := o.target()
+	if t.Kind() != reflect.Struct {
+		e, err := o.element(t, index)
+		if e.IsValid() {
+			return o.proxyValue(e, "")
+		}
+		if name, ok := index.(*String); ok {
+			if m, ok := o.method(name.Value); ok {
+				return m, nil
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		return UndefinedValue, nil
+	}
+
+	name, ok := index.(*String)
+	if !ok {
+		return nil, ErrInvalidIndexType
+	}
+	if f := o.field(name.Value); f != nil {
+		fv, ok := fieldByIndex(t, f.index)
+		if !ok {
+			return UndefinedValue, nil
+		}
+		return o.proxyValue(fv, f.name+".")
+	}
+	if m, ok := o.method(name.Value); ok {
+		return m, nil
+	}
+	return nil, ErrUnknownField
+}
+
+// IndexSet sets the value of the struct field, or the element of the map or
+// the slice for the given index. The value is converted to the Go type of
+// the field or the element as Decode does.
+func (o *Proxy) IndexSet(index, value Object) error {
+	if o.readOnly {
+		return ErrNotIndexAssignable
+	}
+	t := o.target()
+	switch t.Kind() {
+	case reflect.Map:
+		k := reflect.New(t.Type().Key()).Elem()
+		if err := decodeValue(index, k); err != nil {
+			return ErrInvalidIndexType
+		}
+		e := reflect.New(t.Type().Elem()).Elem()
+		if err := decodeValue(value, e); err != nil {
+			return err
+		}
+		if t.IsNil() {
+			if !t.CanSet() {
+				return ErrNotIndexAssignable
+			}
+			t.Set(reflect.MakeMap(t.Type()))
+		}
+		t.SetMapIndex(k, e)
+		return nil
+	case reflect.Slice:
+		i, ok := index.(*Int)
+		if !ok {
+			return ErrInvalidIndexType
+		}
+		if i.Value < 0 || i.Value >= int64(t.Len()) {
+			return ErrIndexOutOfBounds
+		}
+		return decodeValue(value, t.Index(int(i.Value)))
+	}
+
+	name, ok := index.(*String)
+	if !ok {
+		return ErrInvalidIndexType
+	}
+	f := o.field(name.Value)
+	if f == nil {
+		if _, ok := o.method(name.Value); ok {
+			return ErrNotIndexAssignable
+		}
+		return ErrUnknownField
+	}
+	fv := t
+	for i, x := range f.index {
+		if i > 0 && fv.Kind() == reflect.Ptr {
+			if fv.IsNil() {
+				if !fv.CanSet() {
+					return ErrNotIndexAssignable
+				}
+				fv.Set(reflect.New(fv.Type().Elem()))
+			}
+			fv = fv.Elem()
+		}
+		fv = fv.Field(x)
+	}
+	if !fv.CanSet() {
+		return ErrNotIndexAssignable
+	}
+	if err := decodeValue(value, fv); err != nil {
+		return fmt.Errorf("cannot set %s: %s", f.name, err.Error())
+	}
+	return nil
+}
+
+// Iterate creates an iterator of the struct fields, or the elements of the
+// map or the slice.
+func (o *Proxy) Iterate() Iterator {
+	var entries []MapEntry
+	t := o.target()
+	switch t.Kind() {
+	case reflect.Map:
+		for _, k := range t.MapKeys() {
+			ko, err := FromInterface(k.Interface())
+			if err != nil {
+				continue
+			}
+			vo, err := o.proxyValue(t.MapIndex(k), "")
+			if err != nil {
+				continue
+			}
+			entries = append(entries, MapEntry{Key: ko, Value: vo})
+		}
+		sort.SliceStable(entries, func(i, j int) bool {
+			return entries[i].Key.String() < entries[j].Key.String()
+		})
+	case reflect.Slice:
+		for i := 0; i < t.Len(); i++ {
+			vo, err := o.proxyValue(t.Index(i), "")
+			if err != nil {
+				continue
+			}
+			entries = append(entries, MapEntry{
+				Key:   &Int{Value: int64(i)},
+				Value: vo,
+			})
+		}
+	default:
+		for _, f := range structFields(t.Type()) {
+			if !o.allows(f.name) {
+				continue
+			}
+			fv, ok := fieldByIndex(t, f.index)
+			if !ok || !fv.CanInterface() {
+				continue
+			}
+			vo, err := o.proxyValue(fv, f.name+".")
+			if err != nil {
+				continue
+			}
+			entries = append(entries, MapEntry{
+				Key:   &String{Value: f.name},
+				Value: vo,
+			})
+		}
+	}
+	return &ProxyIterator{entries: entries, l: len(entries)}
+}
+
+// CanIterate returns whether the Object can be Iterated.
+func (o *Proxy) CanIterate() bool {
+	return true
+}
+
+// element returns the element of the map or the slice t for the given index.
+// The returned value is invalid if the element does not exist.
+func (o *Proxy) element(t reflect.Value, index Object) (reflect.Value, error) {
+	if t.Kind() == reflect.Map {
+		k := reflect.New(t.Type().Key()).Elem()
+		if err := decodeValue(index, k); err != nil {
+			return reflect.Value{}, ErrInvalidIndexType
+		}
+		return t.MapIndex(k), nil
+	}
+	i, ok := index.(*Int)
+	if !ok {
+		return reflect.Value{}, ErrInvalidIndexType
+	}
+	if i.Value < 0 || i.Value >= int64(t.Len()) {
+		return reflect.Value{}, nil
+	}
+	return t.Index(int(i.Value)), nil
+}
+
+// length returns the length of the map or the slice the proxy refers to.
+func (o *Proxy) length() (int, bool) {
+	t := o.target()
+	if t.Kind() == reflect.Struct {
+		return 0, false
+	}
+	return t.Len(), true
+}
+
+// target returns the struct, the map or the slice the proxy refers to.
+func (o *Proxy) target() reflect.Value {
+	if o.v.Kind() == reflect.Ptr {
+		return o.v.Elem()
+	}
+	return o.v
+}
+
+func (o *Proxy) allows(name string) bool {
+	return o.members == nil || o.members[o.prefix+name]
+}
+
+func (o *Proxy) field(name string) *structField {
+	if !o.allows(name) {
+		return nil
+	}
+	return typeFields(o.target().Type()).byName[name]
+}
+
+// method returns the exported method of the Go value as a function. The
+// functions are wrapped once for each proxy.
+func (o *Proxy) method(name string) (Object, bool) {
+	if !o.allows(name) {
+		return nil, false
+	}
+	if fn, ok := o.methods.Load(name); ok {
+		return fn.(Object), true
+	}
+	m := o.v.MethodByName(name)
+	if !m.IsValid() {
+		return nil, false
+	}
+	wrapped, err := WrapFunc(m.Interface())
+	if err != nil {
+		return nil, false
+	}
+	fn, _ := o.methods.LoadOrStore(name,
+		&UserFunction{Name: name, Value: wrapped})
+	return fn.(Object), true
+}
+
+// proxyValue converts a Go value of a field or an element to an object.
+// Structs, maps and slices are converted to proxies. The member names of the
+// nested proxies are prefixed by prefix.
+func (o *Proxy) proxyValue(v reflect.Value, prefix string) (Object, error) {
+	if v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return UndefinedValue, nil
+		}
+		v = v.Elem()
+	}
+	nested := func(v reflect.Value) Object {
+		return &Proxy{
+			v:        v,
+			members:  o.members,
+			prefix:   o.prefix + prefix,
+			readOnly: o.readOnly,
+		}
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() && v.Elem().Kind() == reflect.Struct &&
+			!isConvertedStruct(v.Elem().Type()) {
+			return nested(v), nil
+		}
+	case reflect.Struct:
+		if isConvertedStruct(v.Type()) {
+			break
+		}
+		if v.CanAddr() {
+			return nested(v.Addr()), nil
+		}
+		// a struct in a map cannot be modified in place
+		c := reflect.New(v.Type())
+		c.Elem().Set(v)
+		return &Proxy{
+			v:        c,
+			members:  o.members,
+			prefix:   o.prefix + prefix,
+			readOnly: true,
+		}, nil
+	case reflect.Map:
+		if v.CanAddr() {
+			return nested(v.Addr()), nil
+		}
+		if !v.IsNil() {
+			return nested(v), nil
+		}
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			break
+		}
+		if v.CanAddr() {
+			return nested(v.Addr()), nil
+		}
+		if !v.IsNil() {
+			return nested(v), nil
+		}
+	}
+	return FromInterface(v.Interface())
+}
+
+// isConvertedStruct returns true for the struct types that are converted to
+// objects by their values.
+func isConvertedStruct(t reflect.Type) bool {
+	switch t {
+	case timeType, bigIntType, bigRatType, bigFloatType:
+		return true
+	}
+	return false
+}
+
+// Range represents a lazy sequence of integers from Start up to, but not
+// including, Stop by Step. Step must not be zero.
+type Range struct {
+	ObjectImpl
+	Start int64
+	Stop  int64
+	Step  int64
+}
+
+// TypeName returns the name of the type.
+func (o *Range) TypeName() string {
+	return "range"
+}
+
+func (o *Range) String() string {
+	return fmt.Sprintf("range(%d, %d, %d)", o.Start, o.Stop, o.Step)
+}
+
+// Len returns the number of integers in the range.
+func (o *Range) Len() int64 {
+	var diff, step uint64
+	switch {
+	case o.Step > 0 && o.Start < o.Stop:
+		diff, step = uint64(o.Stop)-uint64(o.Start), uint64(o.Step)
+	case o.Step < 0 && o.Start > o.Stop:
+		diff, step = uint64(o.Start)-uint64(o.Stop), uint64(-o.Step)
+	default:
+		return 0
+	}
+	n := (diff-1)/step + 1
+	if n > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return int64(n)
+}
+
+// at returns the i-th integer of the range.
+func (o *Range) at(i int64) int64 {
+	return o.Start + i*o.Step
+}
+
+// contains returns true if v is one of the integers in the range.
+func (o *Range) contains(v int64) bool {
+	var diff, step uint64
+	switch {
+	case o.Step > 0 && o.Start <= v && v < o.Stop:
+		diff, step = uint64(v)-uint64(o.Start), uint64(o.Step)
+	case o.Step < 0 && o.Stop < v && v <= o.Start:
+		diff, step = uint64(o.Start)-uint64(v), uint64(-o.Step)
+	default:
+		return false
+	}
+	return diff%step == 0
+}
+
+// Copy returns a copy of the type.
+func (o *Range) Copy() Object {
+	return &Range{Start: o.Start, Stop: o.Stop, Step: o.Step}
+}
+
+// IsFalsy returns true if the value of the type is falsy.
+func (o *Range) IsFalsy() bool {
+	return o.Len() == 0
+}
+
+// Equals returns true if the value of the type is equal to the value of
+// another object. Ranges are equal if they have the same integers.
+func (o *Range) Equals(x Object) bool {
+	t, ok := x.(*Range)
+	if !ok {
+		return false
+	}
+	n := o.Len()
+	switch {
+	case n != t.Len():
+		return false
+	case n == 0:
+		return true
+	case n == 1:
+		return o.Start == t.Start
+	}
+	return o.Start == t.Start && o.Step == t.Step
+}
+
+// IndexGet returns the integer at the given index.
+func (o *Range) IndexGet(index Object) (res Object, err error) {
+	intIdx, ok := index.(*Int)
+	if !ok {
+		err = ErrInvalidIndexType
+		return
+	}
+	if intIdx.Value < 0 || intIdx.Value >= o.Len() {
+		res = UndefinedValue
+		return
+	}
+	res = &Int{Value: o.at(intIdx.Value)}
+	return
+}
+
+// Iterate creates a range iterator.
+func (o *Range) Iterate() Iterator {
+	return &RangeIterator{r: o, l: o.Len()}
+}
+
+// CanIterate returns whether the Object can be Iterated.
+func (o *Range) CanIterate() bool {
+	return true
+}
+
+// Record represents an instance of a user-defined record type.
+type Record struct {
+	ObjectImpl
+	Type   *RecordType
+	Values []Object // field values in the order of Type.Fields
+}
+
+// TypeName returns the name of the type.
+func (o *Record) TypeName() string {
+	return o.Type.Name
+}
+
+func (o *Record) String() string {
+	var fields []string
+	for i, name := range o.Type.Fields {
+		fields = append(fields,
+			fmt.Sprintf("%s: %s", name, o.Values[i].String()))
+	}
+	return fmt.Sprintf("%s{%s}", o.Type.Name, strings.Join(fields, ", "))
+}
+
+// Copy returns a copy of the type.
+func (o *Record) Copy() Object {
+	values := make([]Object, len(o.Values))
+	for i, v := range o.Values {
+		values[i] = v.Copy()
+	}
+	return &Record{Type: o.Type, Values: values}
+}
+
+// Equals returns true if the value of the type is equal to the value of
+// another object.
+func (o *Record) Equals(x Object) bool {
+	t, ok := x.(*Record)
+	if !ok || o.Type != t.Type {
+		return false
+	}
+	for i, v := range o.Values {
+		if !v.Equals(t.Values[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// IndexGet returns the value of the field or the bound method for the given
+// name.
+func (o *Record) IndexGet(index Object) (res Object, err error) {
+	name, ok := index.(*String)
+	if !ok {
+		err = ErrInvalidIndexType
+		return
+	}
+	if idx := o.Type.FieldIndex(name.Value); idx >= 0 {
+		res = o.Values[idx]
+		return
+	}
+	if method, ok := o.Type.Methods[name.Value]; ok {
+		res = &BoundMethod{Receiver: o, Method: method}
+		return
+	}
+	err = ErrUnknownField
+	return
+}
+
+// IndexSet sets the value of the field for the given name.
+func (o *Record) IndexSet(index, value Object) (err error) {
+	name, ok := index.(*String)
+	if !ok {
+		err = ErrInvalidIndexType
+		return
+	}
+	if idx := o.Type.FieldIndex(name.Value); idx >= 0 {
+		o.Values[idx] = value
+		return
+	}
+	if _, ok := o.Type.Methods[name.Value]; ok {
+		err = ErrNotIndexAssignable
+		return
+	}
+	err = ErrUnknownField
+	return
+}
+
+// RecordType represents a user-defined record type. Calling a record type
+// creates a new record whose fields are initialized with the arguments in
+// order, and with the copies of the default values for the rest.
+type RecordType struct {
+	ObjectImpl
+	Name     string
+	Fields   []string
+	Defaults []Object
+	Methods  map[string]*CompiledFunction
+}
+
+// TypeName returns the name of the type.
+func (o *RecordType) TypeName() string {
+	return "record-type"
+}
+
+func (o *RecordType) String() string {
+	return "<record-type:" + o.Name + ">"
+}
+
+// Copy returns a copy of the type.
+func (o *RecordType) Copy() Object {
+	return o
+}
+
+// Equals returns true if the value of the type is equal to the value of
+// another object.
+func (o *RecordType) Equals(x Object) bool {
+	return o == x
+}
+
+// FieldIndex returns the index of the field with the given name, or -1 if
+// the record type does not have the field.
+func (o *RecordType) FieldIndex(name string) int {
+	for i, field := range o.Fields {
+		if field == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Call creates a new record of the type.
+func (o *RecordType) Call(args ...Object) (Object, error) {
+	if len(args) > len(o.Fields) {
+		return nil, ErrWrongNumArguments
+	}
+	values := make([]Object, len(o.Fields))
+	for i := range o.Fields {
+		if i < len(args) {
+			values[i] = args[i]
+		} else {
+			values[i] = o.Defaults[i].Copy()
+		}
+	}
+	return &Record{Type: o, Values: values}, nil
+}
+
+// CanCall returns whether the Object can be Called.
+func (o *RecordType) CanCall() bool {
+	return true
+}
+
+// Set represents a set of unique values. Only hashable values (see Hashable)
+// can be set elements. Elements are kept in the order they were added.
+type Set struct {
+	ObjectImpl
+	setElems
+}
+
+// NewSet returns a set of the given elements. It returns ErrNotHashable if
+// an element cannot be a set element.
+func NewSet(elems ...Object) (*Set, error) {
+	s := &Set{}
+	for _, e := range elems {
+		if !s.add(e) {
+			return nil, ErrNotHashable
+		}
+	}
+	return s, nil
+}
+
+// TypeName returns the name of the type.
+func (o *Set) TypeName() string {
+	return "set"
+}
+
+func (o *Set) String() string {
+	return o.string()
+}
+
+// BinaryOp returns another object that is the result of a given binary
+// operator and a right-hand side object.
+func (o *Set) BinaryOp(op token.Token, rhs Object) (Object, error) {
+	return o.binaryOp(op, rhs)
+}
+
+// Copy returns a copy of the type.
+func (o *Set) Copy() Object {
+	return &Set{setElems: o.copy()}
+}
+
+// IsFalsy returns true if the value of the type is falsy.
+func (o *Set) IsFalsy() bool {
+	return len(o.elems) == 0
+}
+
+// Equals returns true if the value of the type is equal to the value of
+// another object.
+func (o *Set) Equals(x Object) bool {
+	return o.equals(x)
+}
+
+// IndexGet returns true if the index is an element of the set.
+func (o *Set) IndexGet(index Object) (Object, error) {
+	if o.Has(index) {
+		return TrueValue, nil
+	}
+	return FalseValue, nil
+}
+
+// IndexSet adds the index to the set if the value is truthy, or, removes it
+// from the set otherwise.
+func (o *Set) IndexSet(index, value Object) error {
+	if value.IsFalsy() {
+		o.Remove(index)
+		return nil
+	}
+	return o.Add(index)
+}
+
+// Add adds an element to the set. It returns ErrNotHashable if the element
+// cannot be a set element.
+func (o *Set) Add(elem Object) error {
+	if !o.add(elem) {
+		return ErrNotHashable
+	}
+	return nil
+}
+
+// Remove removes an element from the set.
+func (o *Set) Remove(elem Object) {
+	key, ok := hashKey(elem)
+	if !ok {
+		return
+	}
+	i, ok := o.index[key]
+	if !ok {
+		return
+	}
+	delete(o.index, key)
+	if o.iterated {
+		// the iterators keep the elements they iterate over
+		o.elems = append(make([]Object, 0, len(o.elems)), o.elems...)
+		o.iterated = false
+	}
+	n := len(o.elems) - 1
+	copy(o.elems[i:], o.elems[i+1:])
+	copy(o.keys[i:], o.keys[i+1:])
+	o.elems[n] = nil
+	o.elems, o.keys = o.elems[:n], o.keys[:n]
+	for j := i; j < n; j++ {
+		o.index[o.keys[j]] = j
+	}
+}
+
+// Iterate creates a set iterator.
+func (o *Set) Iterate() Iterator {
+	o.iterated = true
+	return &SetIterator{v: o.elems, l: len(o.elems)}
+}
+
+// CanIterate returns whether the Object can be Iterated.
+func (o *Set) CanIterate() bool {
+	return true
+}
+
+// setElems holds the elements of Set and ImmutableSet.
+type setElems struct {
+	elems []Object
+	keys  []string // hash keys of the elements
+	index map[string]int
+	// iterated is true if an iterator may hold the elements, so that they
+	// are copied before an element is removed.
+	iterated bool
+}
+
+func newSetElems(elems []Object) setElems {
+	s := setElems{
+		elems: elems,
+		keys:  make([]string, len(elems)),
+		index: make(map[string]int, len(elems)),
+	}
+	for i, e := range elems {
+		key, _ := hashKey(e)
+		s.keys[i] = key
+		s.index[key] = i
+	}
+	return s
+}
+
+// Len returns the number of elements.
+func (s *setElems) Len() int {
+	return len(s.elems)
+}
+
+// Elements returns the elements in the order they were added.
+func (s *setElems) Elements() []Object {
+	return append([]Object{}, s.elems...)
+}
+
+// Has returns true if elem is an element of the set.
+func (s *setElems) Has(elem Object) bool {
+	key, ok := hashKey(elem)
+	if !ok {
+		return false
+	}
+	_, ok = s.index[key]
+	return ok
+}
+
+func (s *setElems) add(elem Object) bool {
+	key, ok := hashKey(elem)
+	if !ok {
+		return false
+	}
+	if _, exists := s.index[key]; !exists {
+		if s.index == nil {
+			s.index = make(map[string]int)
+		}
+		s.index[key] = len(s.elems)
+		s.elems = append(s.elems, elem)
+		s.keys = append(s.keys, key)
+	}
+	return true
+}
+
+func (s *setElems) copy() setElems {
+	// hashable values are immutable, so the elements are shared
+	return newSetElems(s.Elements())
+}
+
+func (s *setElems) string() string {
+	var elements []string
+	for _, e := range s.elems {
+		elements = append(elements, e.String())
+	}
+	return fmt.Sprintf("set(%s)", strings.Join(elements, ", "))
+}
+
+func (s *setElems) binaryOp(op token.Token, rhs Object) (Object, error) {
+	var other *setElems
+	switch rhs := rhs.(type) {
+	case *Set:
+		other = &rhs.setElems
+	case *ImmutableSet:
+		other = &rhs.setElems
+	default:
+		return nil, ErrInvalidOperator
+	}
+
+	res := &Set{}
+	switch op {
+	case token.Or:
+		for _, e := range s.elems {
+			res.add(e)
+		}
+		for _, e := range other.elems {
+			res.add(e)
+		}
+	case token.And:
+		for _, e := range s.elems {
+			if other.Has(e) {
+				res.add(e)
+			}
+		}
+	case token.Sub:
+		for _, e := range s.elems {
+			if !other.Has(e) {
+				res.add(e)
+			}
+		}
+	default:
+		return nil, ErrInvalidOperator
+	}
+	return res, nil
+}
+
+func (s *setElems) equals(x Object) bool {
+	var other *setElems
+	switch x := x.(type) {
+	case *Set:
+		other = &x.setElems
+	case *ImmutableSet:
+		other = &x.setElems
+	default:
+		return false
+	}
+	if len(s.elems) != len(other.elems) {
+		return false
+	}
+	for key := range s.index {
+		if _, ok := other.index[key]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// hashKey returns the key that identifies a map key or a set element, or,
+// false if the value is not hashable.
+func hashKey(o Object) (string, bool) {
+	h, ok := o.(Hashable)
+	if !ok {
+		return "", false
+	}
+	key, ok := h.HashKey()
+	if !ok {
+		return "", false
+	}
+	return o.TypeName() + ":" + key, true
+}
+
 // String represents a string value.
 type String struct {
 	ObjectImpl
@@ -1366,6 +2956,11 @@ func (o *String) Equals(x Object) bool {
 	return o.Value == t.Value
 }
 
+// HashKey returns the key that identifies the value of the type.
+func (o *String) HashKey() (string, bool) {
+	return o.Value, true
+}
+
 // IndexGet returns a character at a given index.
 func (o *String) IndexGet(index Object) (res Object, err error) {
 	intIdx, ok := index.(*Int)
@@ -1482,6 +3077,11 @@ func (o *Time) Equals(x Object) bool {
 	return o.Value.Equal(t.Value)
 }
 
+// HashKey returns the key that identifies the value of the type.
+func (o *Time) HashKey() (string, bool) {
+	return o.Value.UTC().Format(time.RFC3339Nano), true
+}
+
 // Undefined represents an undefined value.
 type Undefined struct {
 	ObjectImpl
@@ -1542,6 +3142,53 @@ func (o *Undefined) Value() Object {
 	return o
 }
 
+// UserContextFunction represents a user function that receives the context of
+// the run: the context passed to RunContext, or, context.Background if the
+// script is run without a context. See CallableContext.
+type UserContextFunction struct {
+	ObjectImpl
+	Name  string
+	Value CallableContextFunc
+}
+
+// TypeName returns the name of the type.
+func (o *UserContextFunction) TypeName() string {
+	return "user-function:" + o.Name
+}
+
+func (o *UserContextFunction) String() string {
+	return "<user-function>"
+}
+
+// Copy returns a copy of the type.
+func (o *UserContextFunction) Copy() Object {
+	return &UserContextFunction{Name: o.Name, Value: o.Value}
+}
+
+// Equals returns true if the value of the type is equal to the value of
+// another object.
+func (o *UserContextFunction) Equals(_ Object) bool {
+	return false
+}
+
+// Call invokes a user function with context.Background.
+func (o *UserContextFunction) Call(args ...Object) (Object, error) {
+	return o.Value(context.Background(), args...)
+}
+
+// CallContext invokes a user function with the context.
+func (o *UserContextFunction) CallContext(
+	ctx context.Context,
+	args ...Object,
+) (Object, error) {
+	return o.Value(ctx, args...)
+}
+
+// CanCall returns whether the Object can be Called.
+func (o *UserContextFunction) CanCall() bool {
+	return true
+}
+
 // UserFunction represents a user function.
 type UserFunction struct {
 	ObjectImpl
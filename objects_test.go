@@ -1,6 +1,11 @@
 package tengo_test
 
 import (
+	"errors"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/d5/tengo/v2"
@@ -31,6 +36,8 @@ func TestObject_TypeName(t *testing.T) {
 	require.Equal(t, "map-iterator", o.TypeName())
 	o = &tengo.BuiltinFunction{Name: "fn"}
 	require.Equal(t, "builtin-function:fn", o.TypeName())
+	o = &tengo.UserContextFunction{Name: "fn"}
+	require.Equal(t, "user-function:fn", o.TypeName())
 	o = &tengo.UserFunction{Name: "fn"}
 	require.Equal(t, "user-function:fn", o.TypeName())
 	o = &tengo.CompiledFunction{}
@@ -41,6 +48,20 @@ func TestObject_TypeName(t *testing.T) {
 	require.Equal(t, "error", o.TypeName())
 	o = &tengo.Bytes{}
 	require.Equal(t, "bytes", o.TypeName())
+	o = &tengo.BigInt{}
+	require.Equal(t, "bigint", o.TypeName())
+	o = &tengo.Decimal{}
+	require.Equal(t, "decimal", o.TypeName())
+	o = &tengo.Set{}
+	require.Equal(t, "set", o.TypeName())
+	o = &tengo.ImmutableSet{}
+	require.Equal(t, "immutable-set", o.TypeName())
+	o = &tengo.SetIterator{}
+	require.Equal(t, "set-iterator", o.TypeName())
+	o = &tengo.Range{}
+	require.Equal(t, "range", o.TypeName())
+	o = &tengo.RangeIterator{}
+	require.Equal(t, "range-iterator", o.TypeName())
 }
 
 func TestObject_IsFalsy(t *testing.T) {
@@ -86,6 +107,22 @@ func TestObject_IsFalsy(t *testing.T) {
 	require.True(t, o.IsFalsy())
 	o = &tengo.Bytes{Value: []byte{1, 2}}
 	require.False(t, o.IsFalsy())
+	o = &tengo.BigInt{Value: big.NewInt(0)}
+	require.True(t, o.IsFalsy())
+	o = &tengo.BigInt{Value: big.NewInt(-1)}
+	require.False(t, o.IsFalsy())
+	o = &tengo.Decimal{Value: big.NewInt(0), Scale: 2}
+	require.True(t, o.IsFalsy())
+	o = &tengo.Decimal{Value: big.NewInt(1), Scale: 2}
+	require.False(t, o.IsFalsy())
+	o = &tengo.Set{}
+	require.True(t, o.IsFalsy())
+	o, _ = tengo.NewSet(&tengo.Int{Value: 0})
+	require.False(t, o.IsFalsy())
+	o = &tengo.Range{Start: 1, Stop: 1, Step: 1}
+	require.True(t, o.IsFalsy())
+	o = &tengo.Range{Start: 1, Stop: 0, Step: -1}
+	require.False(t, o.IsFalsy())
 }
 
 func TestObject_String(t *testing.T) {
@@ -125,6 +162,20 @@ func TestObject_String(t *testing.T) {
 	require.Equal(t, "", o.String())
 	o = &tengo.Bytes{Value: []byte("foo")}
 	require.Equal(t, "foo", o.String())
+	o = &tengo.BigInt{Value: big.NewInt(-1984)}
+	require.Equal(t, "-1984", o.String())
+	o = &tengo.Decimal{Value: big.NewInt(0)}
+	require.Equal(t, "0", o.String())
+	o = &tengo.Decimal{Value: big.NewInt(1250), Scale: 2}
+	require.Equal(t, "12.50", o.String())
+	o = &tengo.Decimal{Value: big.NewInt(-5), Scale: 3}
+	require.Equal(t, "-0.005", o.String())
+	o = &tengo.Set{}
+	require.Equal(t, "set()", o.String())
+	o, _ = tengo.NewSet(&tengo.Int{Value: 1}, &tengo.String{Value: "a"})
+	require.Equal(t, `set(1, "a")`, o.String())
+	o = &tengo.Range{Start: 5, Stop: -1, Step: -2}
+	require.Equal(t, "range(5, -1, -2)", o.String())
 }
 
 func TestObject_BinaryOp(t *testing.T) {
@@ -214,6 +265,155 @@ func TestArray_BinaryOp(t *testing.T) {
 	}})
 }
 
+func TestBigInt_BinaryOp(t *testing.T) {
+	b := func(v int64) *tengo.BigInt { return &tengo.BigInt{Value: big.NewInt(v)} }
+	huge, _ := new(big.Int).SetString("100000000000000000000", 10)
+
+	testBinaryOp(t, b(7), token.Add, b(2), b(9))
+	testBinaryOp(t, b(7), token.Sub, &tengo.Int{Value: 2}, b(5))
+	testBinaryOp(t, b(7), token.Mul, b(-2), b(-14))
+	testBinaryOp(t, b(7), token.Quo, b(-2), b(-3))
+	testBinaryOp(t, b(-7), token.Rem, b(2), b(-1))
+	testBinaryOp(t, b(6), token.And, b(3), b(2))
+	testBinaryOp(t, b(6), token.Or, b(3), b(7))
+	testBinaryOp(t, b(6), token.Xor, b(3), b(5))
+	testBinaryOp(t, b(6), token.AndNot, b(3), b(4))
+	testBinaryOp(t, b(1), token.Shl, &tengo.Int{Value: 4}, b(16))
+	testBinaryOp(t, b(16), token.Shr, b(4), b(1))
+	testBinaryOp(t, b(10), token.Pow, &tengo.Int{Value: 20},
+		&tengo.BigInt{Value: huge})
+	testBinaryOp(t, b(2), token.Pow, b(-1), &tengo.Float{Value: 0.5})
+	testBinaryOp(t, b(1), token.Less, b(2), tengo.TrueValue)
+	testBinaryOp(t, b(1), token.Greater, &tengo.Int{Value: 2}, tengo.FalseValue)
+	testBinaryOp(t, b(2), token.LessEq, b(2), tengo.TrueValue)
+	testBinaryOp(t, b(1), token.GreaterEq, b(2), tengo.FalseValue)
+
+	// mixed with int, float and decimal
+	testBinaryOp(t, &tengo.Int{Value: 1}, token.Add, b(2), b(3))
+	testBinaryOp(t, &tengo.Int{Value: 1}, token.Less, b(2), tengo.TrueValue)
+	testBinaryOp(t, b(1), token.Add, &tengo.Float{Value: 0.5},
+		&tengo.Float{Value: 1.5})
+	testBinaryOp(t, &tengo.Float{Value: 0.5}, token.Mul, b(3),
+		&tengo.Float{Value: 1.5})
+	testBinaryOp(t, b(1), token.Add,
+		&tengo.Decimal{Value: big.NewInt(5), Scale: 1},
+		&tengo.Decimal{Value: big.NewInt(15), Scale: 1})
+
+	testBinaryOpError(t, b(1), token.Quo, b(0), tengo.ErrDivisionByZero)
+	testBinaryOpError(t, b(1), token.Rem, &tengo.Int{Value: 0},
+		tengo.ErrDivisionByZero)
+	testBinaryOpError(t, b(1), token.Shl, b(-1), tengo.ErrInvalidOperator)
+	testBinaryOpError(t, b(2), token.Pow, &tengo.Int{Value: 1000000000},
+		tengo.ErrBigIntLimit)
+	testBinaryOpError(t, b(3), token.Pow, &tengo.BigInt{Value: huge},
+		tengo.ErrBigIntLimit)
+	testBinaryOpError(t, b(1), token.Shl, &tengo.Int{Value: 1000000000},
+		tengo.ErrBigIntLimit)
+	testBinaryOp(t, b(-1), token.Pow, &tengo.BigInt{Value: huge}, b(1))
+	maxBits := int64(tengo.MaxBigIntBits)
+	testBinaryOp(t, b(2), token.Pow, &tengo.Int{Value: maxBits - 1},
+		&tengo.BigInt{Value: new(big.Int).Lsh(big.NewInt(1),
+			uint(maxBits-1))})
+	testBinaryOpError(t, b(2), token.Pow, &tengo.Int{Value: maxBits},
+		tengo.ErrBigIntLimit)
+	half := &tengo.BigInt{Value: new(big.Int).Lsh(big.NewInt(1),
+		uint(maxBits/2))}
+	testBinaryOpError(t, half, token.Mul, half, tengo.ErrBigIntLimit)
+	testBinaryOpError(t, &tengo.Decimal{Value: half.Value, Scale: 1},
+		token.Mul, &tengo.Decimal{Value: half.Value}, tengo.ErrBigIntLimit)
+	testBinaryOp(t, b(0), token.Shl, &tengo.Int{Value: 1000000000}, b(0))
+	testBinaryOpError(t, b(1), token.Add, &tengo.String{Value: "1"},
+		tengo.ErrInvalidOperator)
+
+	// operands are not modified
+	x := b(5)
+	testBinaryOp(t, x, token.Add, b(1), b(6))
+	require.Equal(t, b(5), x)
+}
+
+func TestDecimal_BinaryOp(t *testing.T) {
+	d := func(s string) *tengo.Decimal {
+		v, ok := tengo.ParseDecimal(s)
+		require.True(t, ok, s)
+		return v
+	}
+
+	testBinaryOp(t, d("0.1"), token.Add, d("0.2"), d("0.3"))
+	testBinaryOp(t, d("1.50"), token.Add, d("1"), d("2.50"))
+	testBinaryOp(t, d("1"), token.Sub, d("0.01"), d("0.99"))
+	testBinaryOp(t, d("1.5"), token.Mul, d("1.5"), d("2.25"))
+	testBinaryOp(t, d("10.00"), token.Quo, d("4"), d("2.50"))
+	testBinaryOp(t, d("1"), token.Quo, d("4"), d("0.25"))
+	testBinaryOp(t, d("1"), token.Quo, d("3"), d("0.3333333333333333"))
+	testBinaryOp(t, d("2"), token.Quo, d("3"), d("0.6666666666666667"))
+	testBinaryOp(t, d("-2"), token.Quo, d("3"), d("-0.6666666666666667"))
+	testBinaryOp(t, d("6"), token.Quo, d("0.5"), d("12"))
+	testBinaryOp(t, d("7.5"), token.Rem, d("2"), d("1.5"))
+	testBinaryOp(t, d("-7.5"), token.Rem, d("2"), d("-1.5"))
+	testBinaryOp(t, d("1.1"), token.Pow, &tengo.Int{Value: 2}, d("1.21"))
+	testBinaryOp(t, d("2"), token.Pow, &tengo.Int{Value: -2}, d("0.25"))
+	testBinaryOp(t, d("0.1"), token.Less, d("0.10"), tengo.FalseValue)
+	testBinaryOp(t, d("0.1"), token.LessEq, d("0.10"), tengo.TrueValue)
+	testBinaryOp(t, d("0.2"), token.Greater, d("0.10"), tengo.TrueValue)
+	testBinaryOp(t, d("0.2"), token.GreaterEq, d("0.3"), tengo.FalseValue)
+
+	// mixed with int, bigint and float
+	testBinaryOp(t, d("0.5"), token.Add, &tengo.Int{Value: 1}, d("1.5"))
+	testBinaryOp(t, &tengo.Int{Value: 1}, token.Sub, d("0.5"), d("0.5"))
+	testBinaryOp(t, d("0.5"), token.Mul,
+		&tengo.BigInt{Value: big.NewInt(3)}, d("1.5"))
+	testBinaryOp(t, d("0.5"), token.Add, &tengo.Float{Value: 0.1}, d("0.6"))
+	testBinaryOp(t, &tengo.Float{Value: 0.1}, token.Mul, d("3"), d("0.3"))
+	testBinaryOp(t, &tengo.Int{Value: 1}, token.Less, d("1.01"),
+		tengo.TrueValue)
+
+	testBinaryOpError(t, d("1"), token.Quo, d("0.00"), tengo.ErrDivisionByZero)
+	testBinaryOpError(t, d("1"), token.Rem, &tengo.Int{Value: 0},
+		tengo.ErrDivisionByZero)
+	testBinaryOpError(t, d("0"), token.Pow, &tengo.Int{Value: -1},
+		tengo.ErrDivisionByZero)
+	testBinaryOpError(t, d("2"), token.Pow, d("0.5"), tengo.ErrInvalidOperator)
+	testBinaryOpError(t, d("1.1"), token.Pow, &tengo.Int{Value: 1000000000},
+		tengo.ErrBigIntLimit)
+	testBinaryOpError(t, d("1.1"), token.Pow, &tengo.Int{Value: -1000000000},
+		tengo.ErrBigIntLimit)
+	testBinaryOpError(t, d("0.1"), token.Pow, &tengo.Int{Value: math.MaxInt64},
+		tengo.ErrBigIntLimit)
+	testBinaryOpError(t, d("2"), token.Pow, &tengo.Int{Value: math.MinInt64},
+		tengo.ErrBigIntLimit)
+	testBinaryOpError(t, d("1"), token.Shl, &tengo.Int{Value: 1},
+		tengo.ErrInvalidOperator)
+
+	require.True(t, d("1.0").Equals(d("1")))
+	require.False(t, d("1").Equals(&tengo.Int{Value: 1}))
+}
+
+func TestParseDecimal(t *testing.T) {
+	for _, tc := range []struct {
+		input    string
+		expected string
+	}{
+		{"0", "0"},
+		{"-12.50", "-12.50"},
+		{"+.5", "0.5"},
+		{"3.", "3"},
+		{"1.5e-3", "0.0015"},
+		{"1.5E3", "1500"},
+		{"00012", "12"},
+		{"123456789012345678901234567890.1", "123456789012345678901234567890.1"},
+	} {
+		d, ok := tengo.ParseDecimal(tc.input)
+		require.True(t, ok, tc.input)
+		require.Equal(t, tc.expected, d.String())
+	}
+
+	for _, input := range []string{"", "-", ".", "1.2.3", "1e", "abc",
+		"1_000", "0x10", "1e100000"} {
+		_, ok := tengo.ParseDecimal(input)
+		require.False(t, ok, input)
+	}
+}
+
 func TestError_Equals(t *testing.T) {
 	err1 := &tengo.Error{Value: &tengo.String{Value: "some error"}}
 	err2 := err1
@@ -260,6 +460,16 @@ func TestFloat_BinaryOp(t *testing.T) {
 		}
 	}
 
+	// float / 0
+	testBinaryOpError(t, &tengo.Float{Value: 1}, token.Quo,
+		&tengo.Float{Value: 0}, tengo.ErrDivisionByZero)
+
+	// float ** float
+	testBinaryOp(t, &tengo.Float{Value: 4}, token.Pow,
+		&tengo.Float{Value: 0.5}, &tengo.Float{Value: 2})
+	testBinaryOp(t, &tengo.Float{Value: 2}, token.Pow,
+		&tengo.Float{Value: -1}, &tengo.Float{Value: 0.5})
+
 	// float < float
 	for l := float64(-2); l <= 2.1; l += 0.4 {
 		for r := float64(-2); r <= 2.1; r += 0.4 {
@@ -327,6 +537,16 @@ func TestFloat_BinaryOp(t *testing.T) {
 		}
 	}
 
+	// float / 0
+	testBinaryOpError(t, &tengo.Float{Value: 1}, token.Quo,
+		&tengo.Int{Value: 0}, tengo.ErrDivisionByZero)
+
+	// float ** int
+	testBinaryOp(t, &tengo.Float{Value: 1.5}, token.Pow,
+		&tengo.Int{Value: 2}, &tengo.Float{Value: 2.25})
+	testBinaryOp(t, &tengo.Float{Value: 1.5}, token.Pow,
+		&tengo.Int{Value: 1}, &tengo.Float{Value: 1.5})
+
 	// float < int
 	for l := float64(-2); l <= 2.1; l += 0.4 {
 		for r := int64(-2); r <= 2; r++ {
@@ -405,6 +625,26 @@ func TestInt_BinaryOp(t *testing.T) {
 		}
 	}
 
+	// int / 0, int % 0
+	testBinaryOpError(t, &tengo.Int{Value: 1}, token.Quo,
+		&tengo.Int{Value: 0}, tengo.ErrDivisionByZero)
+	testBinaryOpError(t, &tengo.Int{Value: 1}, token.Rem,
+		&tengo.Int{Value: 0}, tengo.ErrDivisionByZero)
+
+	// int ** int
+	for l := int64(-3); l <= 3; l++ {
+		expected := int64(1)
+		for r := int64(0); r <= 5; r++ {
+			testBinaryOp(t, &tengo.Int{Value: l}, token.Pow,
+				&tengo.Int{Value: r}, &tengo.Int{Value: expected})
+			expected *= l
+		}
+	}
+	testBinaryOp(t, &tengo.Int{Value: 2}, token.Pow,
+		&tengo.Int{Value: -2}, &tengo.Float{Value: 0.25})
+	testBinaryOp(t, &tengo.Int{Value: 2}, token.Pow,
+		&tengo.Int{Value: 64}, &tengo.Int{Value: 0}) // wraps around
+
 	// int & int
 	testBinaryOp(t,
 		&tengo.Int{Value: 0}, token.And, &tengo.Int{Value: 0},
@@ -650,6 +890,14 @@ func TestInt_BinaryOp(t *testing.T) {
 		}
 	}
 
+	// int / 0.0
+	testBinaryOpError(t, &tengo.Int{Value: 1}, token.Quo,
+		&tengo.Float{Value: 0}, tengo.ErrDivisionByZero)
+
+	// int ** float
+	testBinaryOp(t, &tengo.Int{Value: 9}, token.Pow,
+		&tengo.Float{Value: 0.5}, &tengo.Float{Value: 3})
+
 	// int < float
 	for l := int64(-2); l <= 2; l++ {
 		for r := float64(-2); r <= 2.1; r += 0.5 {
@@ -696,6 +944,374 @@ func TestMap_Index(t *testing.T) {
 	require.Equal(t, v, res)
 }
 
+func TestMap_HashedKeys(t *testing.T) {
+	m := &tengo.Map{Value: make(map[string]tengo.Object)}
+	keys := []tengo.Object{
+		&tengo.Int{Value: 1},
+		&tengo.Char{Value: '1'},
+		&tengo.Bool{},
+		&tengo.String{Value: "1"},
+		&tengo.Float{Value: 1},
+		&tengo.ImmutableArray{Value: []tengo.Object{&tengo.Int{Value: 1}}},
+		&tengo.ImmutableArray{Value: []tengo.Object{&tengo.String{Value: "1"}}},
+		&tengo.ImmutableArray{Value: []tengo.Object{
+			&tengo.String{Value: "1:1"}}},
+		&tengo.ImmutableArray{Value: []tengo.Object{
+			&tengo.String{Value: "1"}, &tengo.String{Value: "1"}}},
+	}
+	for i, k := range keys {
+		require.NoError(t, m.IndexSet(k, &tengo.Int{Value: int64(i)}))
+	}
+	require.Equal(t, 1, len(m.Value))
+	require.Equal(t, len(keys)-1, len(m.Hashed))
+	for i, k := range keys {
+		res, err := m.IndexGet(k)
+		require.NoError(t, err)
+		require.Equal(t, &tengo.Int{Value: int64(i)}, res)
+	}
+
+	err := m.IndexSet(&tengo.Array{}, tengo.TrueValue)
+	require.Equal(t, tengo.ErrInvalidIndexType, err)
+	err = m.IndexSet(&tengo.ImmutableArray{Value: []tengo.Object{
+		&tengo.Map{}}}, tengo.TrueValue)
+	require.Equal(t, tengo.ErrInvalidIndexType, err)
+	_, err = m.IndexGet(tengo.UndefinedValue)
+	require.Equal(t, tengo.ErrInvalidIndexType, err)
+
+	// host objects can be keys by implementing Hashable
+	k1, k2 := &hashableObject{id: 7}, &hashableObject{id: 7}
+	require.NoError(t, m.IndexSet(k1, tengo.TrueValue))
+	res, err := m.IndexGet(k2)
+	require.NoError(t, err)
+	require.Equal(t, tengo.TrueValue, res)
+}
+
+func TestMap_Order(t *testing.T) {
+	m := &tengo.Map{Value: map[string]tengo.Object{
+		"d": &tengo.Int{Value: 1},
+		"c": &tengo.Int{Value: 2},
+	}}
+	// elements added to Value directly are in sorted order
+	require.Equal(t, "{c: 2, d: 1}", m.String())
+
+	require.NoError(t, m.IndexSet(&tengo.String{Value: "b"}, tengo.TrueValue))
+	require.NoError(t, m.IndexSet(&tengo.Int{Value: 1}, tengo.TrueValue))
+	require.NoError(t, m.IndexSet(&tengo.String{Value: "a"}, tengo.TrueValue))
+	require.Equal(t, "{c: 2, d: 1, b: true, 1: true, a: true}", m.String())
+
+	delete(m.Value, "b")
+	m.Value["0"] = tengo.FalseValue
+	require.Equal(t, "{c: 2, d: 1, 1: true, a: true, 0: false}", m.String())
+
+	var keys []string
+	it := m.Copy().Iterate()
+	for it.Next() {
+		keys = append(keys, it.Key().String())
+	}
+	require.Equal(t, `"c","d",1,"a","0"`, strings.Join(keys, ","))
+}
+
+type hashableObject struct {
+	tengo.ObjectImpl
+	id int
+}
+
+func (o *hashableObject) TypeName() string {
+	return "hashable"
+}
+
+func (o *hashableObject) String() string {
+	return "hashable"
+}
+
+func (o *hashableObject) HashKey() (string, bool) {
+	return strconv.Itoa(o.id), true
+}
+
+type proxyOwner struct {
+	Name string
+}
+
+type proxyAccount struct {
+	ID      int64             `tengo:"id"`
+	Balance float64           `tengo:"balance"`
+	Owner   *proxyOwner       `tengo:"owner"`
+	Tags    []string          `tengo:"tags"`
+	Limits  map[string]uint16 `tengo:"limits"`
+	secret  string
+}
+
+func (a *proxyAccount) Deposit(amount float64) (float64, error) {
+	if amount <= 0 {
+		return 0, errors.New("invalid amount")
+	}
+	a.Balance += amount
+	return a.Balance, nil
+}
+
+func TestProxy(t *testing.T) {
+	acc := &proxyAccount{
+		ID:     1,
+		Owner:  &proxyOwner{Name: "alice"},
+		Tags:   []string{"a", "b"},
+		Limits: map[string]uint16{"daily": 10},
+		secret: "s",
+	}
+	p, err := tengo.NewProxy(acc)
+	require.NoError(t, err)
+	require.Equal(t, "proxy:*tengo_test.proxyAccount", p.TypeName())
+
+	run := func(p *tengo.Proxy, src string) (*tengo.Compiled, error) {
+		s := tengo.NewScript([]byte(src))
+		require.NoError(t, s.Add("acc", p))
+		return s.Run()
+	}
+
+	c, err := run(p, `
+acc.balance = 10
+acc.owner.Name = "bob"
+acc.tags[1] = "c"
+acc.limits.daily += 5
+acc.limits["weekly"] = 50
+total := acc.Deposit(2.5)
+err := acc.Deposit(-1)
+id := acc.id
+n := len(acc.tags)
+fields := []
+for k, _ in acc { fields = append(fields, k) }
+nfields := len(fields)
+`)
+	require.NoError(t, err)
+	require.Equal(t, 12.5, acc.Balance)
+	require.Equal(t, "bob", acc.Owner.Name)
+	require.Equal(t, "c", acc.Tags[1])
+	require.Equal(t, 15, int(acc.Limits["daily"]))
+	require.Equal(t, 50, int(acc.Limits["weekly"]))
+	require.Equal(t, 12.5, c.Get("total").Float())
+	require.Equal(t, `error: "invalid amount"`, c.Get("err").Object().String())
+	require.Equal(t, int64(1), c.Get("id").Int64())
+	require.Equal(t, int64(2), c.Get("n").Int64())
+	require.Equal(t, int64(5), c.Get("nfields").Int64())
+	require.True(t, c.Get("acc").Value() == acc)
+
+	// runtime errors
+	expectError := func(p *tengo.Proxy, src, expected string) {
+		_, err := run(p, src)
+		require.Error(t, err)
+		require.True(t, strings.Contains(err.Error(), expected), err.Error())
+	}
+	expectError(p, `acc.id = "x"`,
+		"cannot set id: cannot decode string into int64")
+	expectError(p, `acc.limits.daily = -1`, "cannot decode int into uint16")
+	expectError(p, `a := acc.secret`, "unknown field")
+	expectError(p, `acc.tags[5] = "x"`, "index out of bounds")
+	expectError(p, `acc.Deposit = 1`, "not index-assignable")
+
+	// exposed members
+	p, err = tengo.NewProxy(acc, "balance", "owner", "owner.Name")
+	require.NoError(t, err)
+	c, err = run(p, `b := acc.balance; name := acc.owner.Name`)
+	require.NoError(t, err)
+	require.Equal(t, 12.5, c.Get("b").Float())
+	require.Equal(t, "bob", c.Get("name").String())
+	expectError(p, `a := acc.id`, "unknown field")
+	expectError(p, `acc.Deposit(1)`, "unknown field")
+
+	// maps and slices
+	m := map[string]int{"a": 1}
+	p, err = tengo.NewProxy(m)
+	require.NoError(t, err)
+	_, err = run(p, `acc.b = acc.a + 1`)
+	require.NoError(t, err)
+	require.Equal(t, 2, m["b"])
+	sl := []int{1, 2}
+	p, err = tengo.NewProxy(sl)
+	require.NoError(t, err)
+	_, err = run(p, `acc[0] = 5`)
+	require.NoError(t, err)
+	require.Equal(t, 5, sl[0])
+
+	// slices are indexed by ints only, like arrays
+	_, err = p.IndexGet(&tengo.String{Value: "1"})
+	require.Equal(t, tengo.ErrInvalidIndexType, err)
+	err = p.IndexSet(&tengo.String{Value: "1"}, &tengo.Int{Value: 3})
+	require.Equal(t, tengo.ErrInvalidIndexType, err)
+	err = p.IndexSet(&tengo.Float{Value: 1}, &tengo.Int{Value: 3})
+	require.Equal(t, tengo.ErrInvalidIndexType, err)
+	err = p.IndexSet(&tengo.Int{Value: 2}, &tengo.Int{Value: 3})
+	require.Equal(t, tengo.ErrIndexOutOfBounds, err)
+	require.Equal(t, []int{5, 2}, sl)
+
+	// structs in maps are read-only
+	owners := map[string]proxyOwner{"a": {Name: "alice"}}
+	p, err = tengo.NewProxy(owners)
+	require.NoError(t, err)
+	c, err = run(p, `name := acc.a.Name`)
+	require.NoError(t, err)
+	require.Equal(t, "alice", c.Get("name").String())
+	expectError(p, `acc.a.Name = "bob"`, "not index-assignable")
+	require.Equal(t, "alice", owners["a"].Name)
+	_, err = run(p, `acc.a = {Name: "bob"}`)
+	require.NoError(t, err)
+	require.Equal(t, "bob", owners["a"].Name)
+
+	// methods are wrapped once
+	p, err = tengo.NewProxy(acc)
+	require.NoError(t, err)
+	m1, err := p.IndexGet(&tengo.String{Value: "Deposit"})
+	require.NoError(t, err)
+	m2, err := p.IndexGet(&tengo.String{Value: "Deposit"})
+	require.NoError(t, err)
+	require.True(t, m1 == m2)
+
+	_, err = tengo.NewProxy(proxyAccount{})
+	require.Error(t, err)
+	_, err = tengo.NewProxy((*proxyAccount)(nil))
+	require.Error(t, err)
+	_, err = tengo.NewProxy(1)
+	require.Error(t, err)
+}
+
+func TestRecordType(t *testing.T) {
+	rt := &tengo.RecordType{
+		Name:     "Point",
+		Fields:   []string{"x", "y"},
+		Defaults: []tengo.Object{&tengo.Int{Value: 0}, &tengo.Int{Value: 0}},
+		Methods:  map[string]*tengo.CompiledFunction{"len": {}},
+	}
+	require.Equal(t, "record-type", rt.TypeName())
+	require.Equal(t, "<record-type:Point>", rt.String())
+	require.Equal(t, 1, rt.FieldIndex("y"))
+	require.Equal(t, -1, rt.FieldIndex("len"))
+
+	o, err := rt.Call(&tengo.Int{Value: 3})
+	require.NoError(t, err)
+	r := o.(*tengo.Record)
+	require.Equal(t, "Point", r.TypeName())
+	require.Equal(t, "Point{x: 3, y: 0}", r.String())
+	require.False(t, r.IsFalsy())
+
+	_, err = rt.Call(&tengo.Int{}, &tengo.Int{}, &tengo.Int{})
+	require.Equal(t, tengo.ErrWrongNumArguments, err)
+
+	// fields
+	res, err := r.IndexGet(&tengo.String{Value: "x"})
+	require.NoError(t, err)
+	require.Equal(t, &tengo.Int{Value: 3}, res)
+	err = r.IndexSet(&tengo.String{Value: "y"}, &tengo.Int{Value: 4})
+	require.NoError(t, err)
+	require.Equal(t, "Point{x: 3, y: 4}", r.String())
+	_, err = r.IndexGet(&tengo.String{Value: "z"})
+	require.Equal(t, tengo.ErrUnknownField, err)
+	err = r.IndexSet(&tengo.String{Value: "z"}, &tengo.Int{})
+	require.Equal(t, tengo.ErrUnknownField, err)
+	_, err = r.IndexGet(&tengo.Int{})
+	require.Equal(t, tengo.ErrInvalidIndexType, err)
+
+	// methods
+	res, err = r.IndexGet(&tengo.String{Value: "len"})
+	require.NoError(t, err)
+	require.Equal(t, &tengo.BoundMethod{
+		Receiver: r, Method: rt.Methods["len"],
+	}, res)
+	err = r.IndexSet(&tengo.String{Value: "len"}, &tengo.Int{})
+	require.Equal(t, tengo.ErrNotIndexAssignable, err)
+	require.True(t, res.CanCall())
+	_, err = res.Call()
+	require.Equal(t, tengo.ErrCompiledFunctionCall, err)
+
+	// equality and copy
+	c := r.Copy()
+	require.True(t, r.Equals(c))
+	require.True(t, c.Equals(r))
+	_ = c.IndexSet(&tengo.String{Value: "x"}, &tengo.Int{Value: 5})
+	require.False(t, r.Equals(c))
+	o, _ = (&tengo.RecordType{
+		Name:     "Point",
+		Fields:   rt.Fields,
+		Defaults: rt.Defaults,
+	}).Call(&tengo.Int{Value: 3}, &tengo.Int{Value: 4})
+	require.False(t, r.Equals(o))
+}
+
+func TestSetObject(t *testing.T) {
+	newSet := func(elems ...int64) *tengo.Set {
+		s := &tengo.Set{}
+		for _, e := range elems {
+			require.NoError(t, s.Add(&tengo.Int{Value: e}))
+		}
+		return s
+	}
+
+	testBinaryOp(t, newSet(1, 2), token.Or, newSet(2, 3), newSet(1, 2, 3))
+	testBinaryOp(t, newSet(1, 2), token.And, newSet(2, 3), newSet(2))
+	testBinaryOp(t, newSet(1, 2), token.Sub, newSet(2, 3), newSet(1))
+	testBinaryOp(t, newSet(1, 2), token.Sub, &tengo.ImmutableSet{},
+		newSet(1, 2))
+	testBinaryOpError(t, newSet(1), token.Add, newSet(2),
+		tengo.ErrInvalidOperator)
+	testBinaryOpError(t, newSet(1), token.Or, &tengo.Array{},
+		tengo.ErrInvalidOperator)
+
+	s := newSet(3, 1, 2, 1)
+	require.Equal(t, 3, s.Len())
+	require.Equal(t, []tengo.Object{&tengo.Int{Value: 3},
+		&tengo.Int{Value: 1}, &tengo.Int{Value: 2}}, s.Elements())
+	require.True(t, s.Has(&tengo.Int{Value: 1}))
+	require.False(t, s.Has(&tengo.Float{Value: 1}))
+	require.False(t, s.Has(&tengo.Array{}))
+	require.True(t, s.Equals(newSet(1, 2, 3)))
+	require.False(t, s.Equals(newSet(1, 2)))
+	require.False(t, s.Equals(&tengo.Array{}))
+
+	s.Remove(&tengo.Int{Value: 1})
+	s.Remove(&tengo.Int{Value: 5})
+	require.True(t, s.Equals(newSet(2, 3)))
+	require.Equal(t, tengo.ErrNotHashable, s.Add(&tengo.Map{}))
+
+	// the elements after the removed one keep their order
+	s = newSet(1, 2, 3, 4)
+	s.Remove(&tengo.Int{Value: 2})
+	require.NoError(t, s.Add(&tengo.Int{Value: 5}))
+	s.Remove(&tengo.Int{Value: 4})
+	require.Equal(t, []tengo.Object{&tengo.Int{Value: 1},
+		&tengo.Int{Value: 3}, &tengo.Int{Value: 5}}, s.Elements())
+	require.True(t, s.Has(&tengo.Int{Value: 5}))
+	require.False(t, s.Has(&tengo.Int{Value: 4}))
+
+	// iterators keep the elements removed during the iteration
+	it := s.Iterate()
+	s.Remove(&tengo.Int{Value: 1})
+	var elems []tengo.Object
+	for it.Next() {
+		elems = append(elems, it.Value())
+	}
+	require.Equal(t, []tengo.Object{&tengo.Int{Value: 1},
+		&tengo.Int{Value: 3}, &tengo.Int{Value: 5}}, elems)
+	require.Equal(t, []tengo.Object{&tengo.Int{Value: 3},
+		&tengo.Int{Value: 5}}, s.Elements())
+
+	_, err := tengo.NewSet(&tengo.String{}, &tengo.Array{})
+	require.Equal(t, tengo.ErrNotHashable, err)
+
+	// values equal to each other are the same element
+	s, err = tengo.NewSet(
+		&tengo.Float{Value: 0}, &tengo.Float{Value: math.Copysign(0, -1)},
+		&tengo.Decimal{Value: big.NewInt(10), Scale: 1},
+		&tengo.Decimal{Value: big.NewInt(1)},
+		&tengo.BigInt{Value: big.NewInt(1)},
+		&tengo.Int{Value: 1}, &tengo.Char{Value: '1'},
+		&tengo.String{Value: "1"}, tengo.TrueValue)
+	require.NoError(t, err)
+	require.Equal(t, 7, s.Len())
+
+	// copies are independent
+	c := s.Copy().(*tengo.Set)
+	c.Remove(tengo.TrueValue)
+	require.Equal(t, 7, s.Len())
+	require.Equal(t, 6, c.Len())
+}
+
 func TestString_BinaryOp(t *testing.T) {
 	lstr := "abcde"
 	rstr := "01234"
@@ -728,6 +1344,18 @@ func testBinaryOp(
 	require.Equal(t, expected, actual)
 }
 
+func testBinaryOpError(
+	t *testing.T,
+	lhs tengo.Object,
+	op token.Token,
+	rhs tengo.Object,
+	expected error,
+) {
+	t.Helper()
+	_, err := lhs.BinaryOp(op, rhs)
+	require.Equal(t, expected, err)
+}
+
 func boolValue(b bool) tengo.Object {
 	if b {
 		return tengo.TrueValue
@@ -12,6 +12,30 @@ type Expr interface {
 	exprNode()
 }
 
+// ArrayComprehension represents an array comprehension expression.
+type ArrayComprehension struct {
+	Value   Expr
+	Clauses []*ComprehensionClause
+	LBrack  Pos
+	RBrack  Pos
+}
+
+func (e *ArrayComprehension) exprNode() {}
+
+// Pos returns the position of first character belonging to the node.
+func (e *ArrayComprehension) Pos() Pos {
+	return e.LBrack
+}
+
+// End returns the position of first character immediately after the node.
+func (e *ArrayComprehension) End() Pos {
+	return e.RBrack + 1
+}
+
+func (e *ArrayComprehension) String() string {
+	return "[" + e.Value.String() + clausesString(e.Clauses) + "]"
+}
+
 // ArrayLit represents an array literal.
 type ArrayLit struct {
 	Elements []Expr
@@ -160,6 +184,46 @@ func (e *CharLit) String() string {
 	return e.Literal
 }
 
+// ComprehensionClause represents a "for key, value in iterable if cond"
+// clause of a comprehension. Cond is nil if the clause has no condition.
+type ComprehensionClause struct {
+	ForPos   Pos
+	Key      *Ident
+	Value    *Ident
+	Iterable Expr
+	Cond     Expr
+}
+
+// Pos returns the position of first character belonging to the node.
+func (c *ComprehensionClause) Pos() Pos {
+	return c.ForPos
+}
+
+// End returns the position of first character immediately after the node.
+func (c *ComprehensionClause) End() Pos {
+	if c.Cond != nil {
+		return c.Cond.End()
+	}
+	return c.Iterable.End()
+}
+
+func (c *ComprehensionClause) String() string {
+	s := "for " + c.Key.String() + ", " + c.Value.String() + " in " +
+		c.Iterable.String()
+	if c.Cond != nil {
+		s += " if " + c.Cond.String()
+	}
+	return s
+}
+
+func clausesString(clauses []*ComprehensionClause) string {
+	var s string
+	for _, c := range clauses {
+		s += " " + c.String()
+	}
+	return s
+}
+
 // CondExpr represents a ternary conditional expression.
 type CondExpr struct {
 	Cond        Expr
@@ -352,10 +416,11 @@ func (e *ImportExpr) String() string {
 
 // IndexExpr represents an index expression.
 type IndexExpr struct {
-	Expr   Expr
-	LBrack Pos
-	Index  Expr
-	RBrack Pos
+	Expr     Expr
+	LBrack   Pos
+	Index    Expr
+	RBrack   Pos
+	Optional bool // "?.[" instead of "["
 }
 
 func (e *IndexExpr) exprNode() {}
@@ -375,9 +440,37 @@ func (e *IndexExpr) String() string {
 	if e.Index != nil {
 		index = e.Index.String()
 	}
+	if e.Optional {
+		return e.Expr.String() + "?.[" + index + "]"
+	}
 	return e.Expr.String() + "[" + index + "]"
 }
 
+// InterpStringLit represents an interpolated string literal
+// (f"text ${expr}"). Parts are the string literals of the text and the
+// embedded expressions in order.
+type InterpStringLit struct {
+	Parts    []Expr
+	ValuePos Pos
+	Literal  string
+}
+
+func (e *InterpStringLit) exprNode() {}
+
+// Pos returns the position of first character belonging to the node.
+func (e *InterpStringLit) Pos() Pos {
+	return e.ValuePos
+}
+
+// End returns the position of first character immediately after the node.
+func (e *InterpStringLit) End() Pos {
+	return Pos(int(e.ValuePos) + len(e.Literal))
+}
+
+func (e *InterpStringLit) String() string {
+	return e.Literal
+}
+
 // IntLit represents an integer literal.
 type IntLit struct {
 	Value    int64
@@ -425,6 +518,32 @@ func (e *MapElementLit) String() string {
 	return e.Key + ": " + e.Value.String()
 }
 
+// MapComprehension represents a map comprehension expression.
+type MapComprehension struct {
+	Key     Expr
+	Value   Expr
+	Clauses []*ComprehensionClause
+	LBrace  Pos
+	RBrace  Pos
+}
+
+func (e *MapComprehension) exprNode() {}
+
+// Pos returns the position of first character belonging to the node.
+func (e *MapComprehension) Pos() Pos {
+	return e.LBrace
+}
+
+// End returns the position of first character immediately after the node.
+func (e *MapComprehension) End() Pos {
+	return e.RBrace + 1
+}
+
+func (e *MapComprehension) String() string {
+	return "{" + e.Key.String() + ": " + e.Value.String() +
+		clausesString(e.Clauses) + "}"
+}
+
 // MapLit represents a map literal.
 type MapLit struct {
 	LBrace   Pos
@@ -475,10 +594,41 @@ func (e *ParenExpr) String() string {
 	return "(" + e.Expr.String() + ")"
 }
 
+// RecordLit represents a record type literal. Elements with function literal
+// values are the methods of the record type, and others are the fields with
+// their default values.
+type RecordLit struct {
+	RecordPos Pos
+	LBrace    Pos
+	Elements  []*MapElementLit
+	RBrace    Pos
+}
+
+func (e *RecordLit) exprNode() {}
+
+// Pos returns the position of first character belonging to the node.
+func (e *RecordLit) Pos() Pos {
+	return e.RecordPos
+}
+
+// End returns the position of first character immediately after the node.
+func (e *RecordLit) End() Pos {
+	return e.RBrace + 1
+}
+
+func (e *RecordLit) String() string {
+	var elements []string
+	for _, m := range e.Elements {
+		elements = append(elements, m.String())
+	}
+	return "record {" + strings.Join(elements, ", ") + "}"
+}
+
 // SelectorExpr represents a selector expression.
 type SelectorExpr struct {
-	Expr Expr
-	Sel  Expr
+	Expr     Expr
+	Sel      Expr
+	Optional bool // "?." instead of "."
 }
 
 func (e *SelectorExpr) exprNode() {}
@@ -494,16 +644,20 @@ func (e *SelectorExpr) End() Pos {
 }
 
 func (e *SelectorExpr) String() string {
+	if e.Optional {
+		return e.Expr.String() + "?." + e.Sel.String()
+	}
 	return e.Expr.String() + "." + e.Sel.String()
 }
 
 // SliceExpr represents a slice expression.
 type SliceExpr struct {
-	Expr   Expr
-	LBrack Pos
-	Low    Expr
-	High   Expr
-	RBrack Pos
+	Expr     Expr
+	LBrack   Pos
+	Low      Expr
+	High     Expr
+	RBrack   Pos
+	Optional bool // "?.[" instead of "["
 }
 
 func (e *SliceExpr) exprNode() {}
@@ -526,9 +680,35 @@ func (e *SliceExpr) String() string {
 	if e.High != nil {
 		high = e.High.String()
 	}
+	if e.Optional {
+		return e.Expr.String() + "?.[" + low + ":" + high + "]"
+	}
 	return e.Expr.String() + "[" + low + ":" + high + "]"
 }
 
+// SpreadExpr represents a spread element ("...x") of an array literal. It is
+// only valid as the last element of an array destructuring pattern.
+type SpreadExpr struct {
+	Expr        Expr
+	EllipsisPos Pos
+}
+
+func (e *SpreadExpr) exprNode() {}
+
+// Pos returns the position of first character belonging to the node.
+func (e *SpreadExpr) Pos() Pos {
+	return e.EllipsisPos
+}
+
+// End returns the position of first character immediately after the node.
+func (e *SpreadExpr) End() Pos {
+	return e.Expr.End()
+}
+
+func (e *SpreadExpr) String() string {
+	return "..." + e.Expr.String()
+}
+
 // StringLit represents a string literal.
 type StringLit struct {
 	Value    string
@@ -47,6 +47,10 @@ const (
 	OpIteratorValue               // Iterator value
 	OpBinaryOp                    // Binary operation
 	OpSuspend                     // Suspend VM
+	OpOptionalJump                // Optional chaining jump
+	OpCoalesceJump                // Undefined coalescing jump
+	OpRecord                      // Record type object
+	OpAppend                      // Append to comprehension array
 )
 
 // OpcodeNames are string representation of opcodes.
@@ -93,6 +97,10 @@ var OpcodeNames = [...]string{
 	OpIteratorValue: "ITVAL",
 	OpBinaryOp:      "BINARYOP",
 	OpSuspend:       "SUSPEND",
+	OpOptionalJump:  "OPTJMP",
+	OpCoalesceJump:  "COALJMP",
+	OpRecord:        "RECORD",
+	OpAppend:        "APPEND",
 }
 
 // OpcodeOperands is the number of operands.
@@ -139,6 +147,10 @@ var OpcodeOperands = [...][]int{
 	OpIteratorValue: {},
 	OpBinaryOp:      {1},
 	OpSuspend:       {},
+	OpOptionalJump:  {2},
+	OpCoalesceJump:  {2},
+	OpRecord:        {2, 2},
+	OpAppend:        {},
 }
 
 // ReadOperands reads operands from the bytecode.
@@ -154,6 +154,38 @@ func (p *Parser) ParseFile() (file *File, err error) {
 	return
 }
 
+// ParseExpr parses the source as a single expression and returns its AST.
+func (p *Parser) ParseExpr() (expr Expr, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			if _, ok := e.(bailout); !ok {
+				panic(e)
+			}
+		}
+
+		p.errors.Sort()
+		err = p.errors.Err()
+		if err != nil {
+			expr = nil
+		}
+	}()
+
+	if p.trace {
+		defer untracep(tracep(p, "Expr"))
+	}
+
+	if p.errors.Len() > 0 {
+		return nil, p.errors.Err()
+	}
+
+	expr = p.parseExpr()
+	if p.token == token.Semicolon {
+		p.next()
+	}
+	p.expect(token.EOF)
+	return
+}
+
 func (p *Parser) parseExpr() Expr {
 	if p.trace {
 		defer untracep(tracep(p, "Expression"))
@@ -183,7 +215,11 @@ func (p *Parser) parseBinaryExpr(prec1 int) Expr {
 
 		pos := p.expect(op)
 
-		y := p.parseBinaryExpr(prec + 1)
+		// '**' is right-associative
+		if op != token.Pow {
+			prec++
+		}
+		y := p.parseBinaryExpr(prec)
 
 		x = &BinaryExpr{
 			LHS:      x,
@@ -250,6 +286,29 @@ L:
 				p.advance(stmtStart)
 				return &BadExpr{From: pos, To: p.pos}
 			}
+		case token.QuestionPeriod:
+			p.next()
+
+			switch p.token {
+			case token.Ident:
+				sel := p.parseSelector(x).(*SelectorExpr)
+				sel.Optional = true
+				x = sel
+			case token.LBrack:
+				switch idx := p.parseIndexOrSlice(x).(type) {
+				case *IndexExpr:
+					idx.Optional = true
+					x = idx
+				case *SliceExpr:
+					idx.Optional = true
+					x = idx
+				}
+			default:
+				pos := p.pos
+				p.errorExpected(pos, "selector or index")
+				p.advance(stmtStart)
+				return &BadExpr{From: pos, To: p.pos}
+			}
 		case token.LBrack:
 			x = p.parseIndexOrSlice(x)
 		case token.LParen:
@@ -368,7 +427,14 @@ func (p *Parser) parseOperand() Expr {
 
 	switch p.token {
 	case token.Ident:
-		return p.parseIdent()
+		x := p.parseIdent()
+		// 'record' is not a keyword, so it can still be used as an
+		// identifier: it starts a record type literal only if it is followed
+		// by '{' outside of the control clauses.
+		if x.Name == "record" && p.token == token.LBrace && p.exprLevel >= 0 {
+			return p.parseRecordLit(x.NamePos)
+		}
+		return x
 	case token.Int:
 		v, _ := strconv.ParseInt(p.tokenLit, 10, 64)
 		x := &IntLit{
@@ -390,6 +456,9 @@ func (p *Parser) parseOperand() Expr {
 	case token.Char:
 		return p.parseCharLit()
 	case token.String:
+		if isInterpString(p.tokenLit) {
+			return p.parseInterpStringLit()
+		}
 		v, _ := strconv.Unquote(p.tokenLit)
 		x := &StringLit{
 			Value:    v,
@@ -454,7 +523,7 @@ func (p *Parser) parseImportExpr() Expr {
 	pos := p.pos
 	p.next()
 	p.expect(token.LParen)
-	if p.token != token.String {
+	if p.token != token.String || isInterpString(p.tokenLit) {
 		p.errorExpected(p.pos, "module name")
 		p.advance(stmtStart)
 		return &BadExpr{From: pos, To: p.pos}
@@ -496,6 +565,85 @@ func (p *Parser) parseCharLit() Expr {
 	}
 }
 
+func (p *Parser) parseInterpStringLit() Expr {
+	if p.trace {
+		defer untracep(tracep(p, "InterpStringLit"))
+	}
+
+	pos := p.pos
+	lit := p.tokenLit
+	p.next()
+
+	src := p.scanner.src
+	start := p.file.Offset(pos) + 2 // after 'f"'
+	end := start + len(lit) - 3     // closing '"'
+	if end < start || interpStringEnd(src, start) != end+1 {
+		// not terminated: the error is already reported by the scanner
+		return &BadExpr{From: pos, To: pos + Pos(len(lit))}
+	}
+
+	x := &InterpStringLit{ValuePos: pos, Literal: lit}
+	var text []byte
+	textStart := start
+	addText := func() {
+		if len(text) == 0 {
+			return
+		}
+		v, _ := strconv.Unquote(`"` + string(text) + `"`)
+		x.Parts = append(x.Parts, &StringLit{
+			Value:    v,
+			ValuePos: p.file.FileSetPos(textStart),
+			Literal:  strconv.Quote(v),
+		})
+		text = nil
+	}
+	for i := start; i < end; i++ {
+		switch {
+		case src[i] == '\\' && src[i+1] == '$':
+			text = append(text, '$')
+			i++
+		case src[i] == '\\':
+			text = append(text, src[i], src[i+1])
+			i++
+		case src[i] == '$' && src[i+1] == '{':
+			addText()
+			exprEnd := interpExprEnd(src, i+2)
+			x.Parts = append(x.Parts, p.parseInterpolation(i+2, exprEnd))
+			i = exprEnd
+			textStart = i + 1
+		default:
+			text = append(text, src[i])
+		}
+	}
+	addText()
+	return x
+}
+
+// parseInterpolation parses the expression of an interpolation ("${expr}")
+// located in [from, to) offsets range of the source.
+func (p *Parser) parseInterpolation(from, to int) Expr {
+	sub := &Parser{
+		file:     p.file,
+		trace:    p.trace,
+		indent:   p.indent,
+		traceOut: p.traceOut,
+	}
+	defer func() {
+		p.errors = append(p.errors, sub.errors...)
+	}()
+	sub.scanner = newSubScanner(p.scanner, from, to,
+		func(pos SourceFilePos, msg string) {
+			sub.errors.Add(pos, msg)
+		})
+	sub.next()
+
+	x := sub.parseExpr()
+	if sub.token != token.EOF {
+		sub.errorExpected(sub.pos, "'}'")
+	}
+	return x
+}
+
 func (p *Parser) parseFuncLit() Expr {
 	if p.trace {
 		defer untracep(tracep(p, "FuncLit"))
@@ -521,7 +669,31 @@ func (p *Parser) parseArrayLit() Expr {
 
 	var elements []Expr
 	for p.token != token.RBrack && p.token != token.EOF {
-		elements = append(elements, p.parseExpr())
+		if p.token == token.Ellipsis {
+			pos := p.pos
+			p.next()
+			elements = append(elements, &SpreadExpr{
+				Expr:        p.parseExpr(),
+				EllipsisPos: pos,
+			})
+		} else {
+			elements = append(elements, p.parseExpr())
+		}
+
+		// [value for ...]
+		if len(elements) == 1 && p.token == token.For {
+			if _, isSpread := elements[0].(*SpreadExpr); !isSpread {
+				clauses := p.parseComprehensionClauses()
+				p.exprLevel--
+				rbrack := p.expect(token.RBrack)
+				return &ArrayComprehension{
+					Value:   elements[0],
+					Clauses: clauses,
+					LBrack:  lbrack,
+					RBrack:  rbrack,
+				}
+			}
+		}
 
 		if !p.expectComma(token.RBrack, "array element") {
 			break
@@ -663,12 +835,17 @@ func (p *Parser) parseStmt() (stmt Stmt) {
 
 	switch p.token {
 	case // simple statements
-		token.Func, token.Error, token.Immutable, token.Ident, token.Int,
-		token.Float, token.Char, token.String, token.True, token.False,
-		token.Undefined, token.Import, token.LParen, token.LBrace,
-		token.LBrack, token.Add, token.Sub, token.Mul, token.And, token.Xor,
-		token.Not:
+		token.Func, token.Error, token.Immutable, token.Ident,
+		token.Int, token.Float, token.Char, token.String, token.True,
+		token.False, token.Undefined, token.Import, token.LParen,
+		token.LBrace, token.LBrack, token.Add, token.Sub, token.Mul,
+		token.And, token.Xor, token.Not:
 		s := p.parseSimpleStmt(false)
+		if x, isExpr := s.(*ExprStmt); isExpr && p.token == token.Colon {
+			if label, isIdent := x.Expr.(*Ident); isIdent {
+				return p.parseLabeledStmt(label)
+			}
+		}
 		p.expectSemi()
 		return s
 	case token.Return:
@@ -696,6 +873,19 @@ func (p *Parser) parseStmt() (stmt Stmt) {
 	}
 }
 
+func (p *Parser) parseLabeledStmt(label *Ident) Stmt {
+	if p.trace {
+		defer untracep(tracep(p, "LabeledStmt"))
+	}
+
+	colon := p.expect(token.Colon)
+	return &LabeledStmt{
+		Label: label,
+		Colon: colon,
+		Stmt:  p.parseStmt(),
+	}
+}
+
 func (p *Parser) parseForStmt() Stmt {
 	if p.trace {
 		defer untracep(tracep(p, "ForStmt"))
@@ -984,7 +1174,8 @@ func (p *Parser) parseSimpleStmt(forIn bool) Stmt {
 	case token.Define,
 		token.AddAssign, token.SubAssign, token.MulAssign, token.QuoAssign,
 		token.RemAssign, token.AndAssign, token.OrAssign, token.XorAssign,
-		token.ShlAssign, token.ShrAssign, token.AndNotAssign:
+		token.ShlAssign, token.ShrAssign, token.AndNotAssign,
+		token.PowAssign:
 		pos, tok := p.pos, p.token
 		p.next()
 		y := p.parseExpr()
@@ -1023,15 +1214,27 @@ func (p *Parser) parseMapElementLit() *MapElementLit {
 
 	pos := p.pos
 	name := "_"
+	isIdent := false
 	if p.token == token.Ident {
 		name = p.tokenLit
-	} else if p.token == token.String {
+		isIdent = true
+	} else if p.token == token.String && !isInterpString(p.tokenLit) {
 		v, _ := strconv.Unquote(p.tokenLit)
 		name = v
 	} else {
 		p.errorExpected(pos, "map key")
 	}
 	p.next()
+
+	// shorthand element: {name} is equivalent to {name: name}
+	if isIdent && (p.token == token.Comma || p.token == token.RBrace) {
+		return &MapElementLit{
+			Key:    name,
+			KeyPos: pos,
+			Value:  &Ident{Name: name, NamePos: pos},
+		}
+	}
+
 	colonPos := p.expect(token.Colon)
 	valueExpr := p.parseExpr()
 	return &MapElementLit{
@@ -1042,7 +1245,7 @@ func (p *Parser) parseMapElementLit() *MapElementLit {
 	}
 }
 
-func (p *Parser) parseMapLit() *MapLit {
+func (p *Parser) parseMapLit() Expr {
 	if p.trace {
 		defer untracep(tracep(p, "MapLit"))
 	}
@@ -1050,9 +1253,33 @@ func (p *Parser) parseMapLit() *MapLit {
 	lbrace := p.expect(token.LBrace)
 	p.exprLevel++
 
+	// {(key): value for ...}
+	if p.token == token.LParen ||
+		p.token == token.String && isInterpString(p.tokenLit) {
+		key := p.parseExpr()
+		p.expect(token.Colon)
+		return p.parseMapComprehension(lbrace, key, p.parseExpr())
+	}
+
 	var elements []*MapElementLit
 	for p.token != token.RBrace && p.token != token.EOF {
-		elements = append(elements, p.parseMapElementLit())
+		keyToken := p.token
+		elt := p.parseMapElementLit()
+
+		// {key: value for ...}
+		if len(elements) == 0 && p.token == token.For &&
+			elt.ColonPos != NoPos {
+			var key Expr = &Ident{Name: elt.Key, NamePos: elt.KeyPos}
+			if keyToken == token.String {
+				key = &StringLit{
+					Value:    elt.Key,
+					ValuePos: elt.KeyPos,
+					Literal:  strconv.Quote(elt.Key),
+				}
+			}
+			return p.parseMapComprehension(lbrace, key, elt.Value)
+		}
+		elements = append(elements, elt)
 
 		if !p.expectComma(token.RBrace, "map element") {
 			break
@@ -1068,6 +1295,85 @@ func (p *Parser) parseMapLit() *MapLit {
 	}
 }
 
+func (p *Parser) parseMapComprehension(lbrace Pos, key, value Expr) Expr {
+	if p.token != token.For {
+		p.errorExpected(p.pos, "'for'")
+	}
+	clauses := p.parseComprehensionClauses()
+	p.exprLevel--
+	rbrace := p.expect(token.RBrace)
+	return &MapComprehension{
+		Key:     key,
+		Value:   value,
+		Clauses: clauses,
+		LBrace:  lbrace,
+		RBrace:  rbrace,
+	}
+}
+
+func (p *Parser) parseComprehensionClauses() []*ComprehensionClause {
+	if p.trace {
+		defer untracep(tracep(p, "ComprehensionClauses"))
+	}
+
+	var clauses []*ComprehensionClause
+	for p.token == token.For {
+		pos := p.expect(token.For)
+		key := &Ident{Name: "_", NamePos: p.pos}
+		value := p.parseIdent()
+		if p.token == token.Comma {
+			p.next()
+			key, value = value, p.parseIdent()
+		}
+		p.expect(token.In)
+		clause := &ComprehensionClause{
+			ForPos:   pos,
+			Key:      key,
+			Value:    value,
+			Iterable: p.parseExpr(),
+		}
+		if p.token == token.If {
+			p.next()
+			clause.Cond = p.parseExpr()
+		}
+		clauses = append(clauses, clause)
+	}
+	return clauses
+}
+
+// parseRecordLit parses a record type literal after its 'record' at pos.
+func (p *Parser) parseRecordLit(pos Pos) *RecordLit {
+	if p.trace {
+		defer untracep(tracep(p, "RecordLit"))
+	}
+
+	lbrace := p.expect(token.LBrace)
+	p.exprLevel++
+
+	var elements []*MapElementLit
+	for p.token != token.RBrace && p.token != token.EOF {
+		elt := p.parseMapElementLit()
+		if elt.ColonPos == NoPos {
+			// field without a default value
+			elt.Value = &UndefinedLit{TokenPos: elt.KeyPos}
+		}
+		elements = append(elements, elt)
+
+		if !p.expectComma(token.RBrace, "record element") {
+			break
+		}
+	}
+
+	p.exprLevel--
+	rbrace := p.expect(token.RBrace)
+	return &RecordLit{
+		RecordPos: pos,
+		LBrace:    lbrace,
+		RBrace:    rbrace,
+		Elements:  elements,
+	}
+}
+
 func (p *Parser) expect(token token.Token) Pos {
 	pos := p.pos
 
@@ -1194,3 +1500,7 @@ func untracep(p *Parser) {
 	p.indent--
 	p.printTrace(")")
 }
+
+func isInterpString(lit string) bool {
+	return len(lit) > 0 && lit[0] == 'f'
+}
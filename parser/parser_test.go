@@ -249,6 +249,32 @@ func TestParseAssignment(t *testing.T) {
 				token.MulAssign,
 				p(1, 3)))
 	})
+
+	expectParse(t, "[a, ...b] := c", func(p pfn) []Stmt {
+		return stmts(
+			assignStmt(
+				exprs(
+					arrayLit(p(1, 1), p(1, 9),
+						ident("a", p(1, 2)),
+						spreadExpr(ident("b", p(1, 8)), p(1, 5)))),
+				exprs(ident("c", p(1, 14))),
+				token.Define,
+				p(1, 11)))
+	})
+
+	expectParse(t, "{a, b: c} = m", func(p pfn) []Stmt {
+		return stmts(
+			assignStmt(
+				exprs(
+					mapLit(p(1, 1), p(1, 9),
+						mapElementLit(
+							"a", p(1, 2), 0, ident("a", p(1, 2))),
+						mapElementLit(
+							"b", p(1, 5), p(1, 6), ident("c", p(1, 8))))),
+				exprs(ident("m", p(1, 13))),
+				token.Assign,
+				p(1, 11)))
+	})
 }
 
 func TestParseBoolean(t *testing.T) {
@@ -521,6 +547,98 @@ func TestParseError(t *testing.T) {
 	expectParseError(t, `error()`) // must have a value
 }
 
+func TestParseComprehension(t *testing.T) {
+	expectParse(t, "[x * 2 for x in a if x > 0]", func(p pfn) []Stmt {
+		return stmts(exprStmt(
+			arrayComprehension(p(1, 1), p(1, 27),
+				binaryExpr(ident("x", p(1, 2)), intLit(2, p(1, 6)),
+					token.Mul, p(1, 4)),
+				comprehensionClause(p(1, 8),
+					ident("_", p(1, 12)), ident("x", p(1, 12)),
+					ident("a", p(1, 17)),
+					binaryExpr(ident("x", p(1, 22)), intLit(0, p(1, 26)),
+						token.Greater, p(1, 24))))))
+	})
+
+	expectParse(t, "{k: v for k, v in m}", func(p pfn) []Stmt {
+		return stmts(exprStmt(
+			mapComprehension(p(1, 1), p(1, 20),
+				ident("k", p(1, 2)), ident("v", p(1, 5)),
+				comprehensionClause(p(1, 7),
+					ident("k", p(1, 11)), ident("v", p(1, 14)),
+					ident("m", p(1, 19)), nil))))
+	})
+
+	expectParse(t, `{"a": v for v in m}`, func(p pfn) []Stmt {
+		return stmts(exprStmt(
+			mapComprehension(p(1, 1), p(1, 19),
+				stringLit("a", p(1, 2)), ident("v", p(1, 7)),
+				comprehensionClause(p(1, 9),
+					ident("_", p(1, 13)), ident("v", p(1, 13)),
+					ident("m", p(1, 18)), nil))))
+	})
+
+	expectParseString(t, "[[x, y] for x in a for y in b if x < y]",
+		"[[x, y] for _, x in a for _, y in b if (x < y)]")
+	expectParseString(t, `{(k + "x"): v for k, v in m}`,
+		`{((k + "x")): v for k, v in m}`)
+	expectParseString(t, `{f"${k}x": v for k, v in m if v}`,
+		`{f"${k}x": v for k, v in m if v}`)
+	expectParseString(t, "a := [x for x in [y for y in b]]",
+		"a := [x for _, x in [y for _, y in b]]")
+
+	expectParseError(t, `[x for]`)
+	expectParseError(t, `[x for x]`)
+	expectParseError(t, `[x for x in a if]`)
+	expectParseError(t, `[x, y for x in a]`)
+	expectParseError(t, `[...x for x in a]`)
+	expectParseError(t, `[x for a.b in c]`)
+	expectParseError(t, `{k for k in m}`)
+	expectParseError(t, `{a: 1, k: v for k in m}`)
+	expectParseError(t, `{(k): v}`)
+}
+
+func TestParseLabeledStmt(t *testing.T) {
+	expectParse(t, "L: for { break L }", func(p pfn) []Stmt {
+		return stmts(
+			labeledStmt(ident("L", p(1, 1)), p(1, 2),
+				forStmt(nil, nil, nil,
+					blockStmt(p(1, 8), p(1, 18),
+						branchStmt(token.Break, p(1, 10),
+							ident("L", p(1, 16)))),
+					p(1, 4))))
+	})
+
+	expectParse(t, "outer:\nfor x in y { continue outer }",
+		func(p pfn) []Stmt {
+			return stmts(
+				labeledStmt(ident("outer", p(1, 1)), p(1, 6),
+					forInStmt(
+						ident("_", p(2, 5)),
+						ident("x", p(2, 5)),
+						ident("y", p(2, 10)),
+						blockStmt(p(2, 12), p(2, 29),
+							branchStmt(token.Continue, p(2, 14),
+								ident("outer", p(2, 23)))),
+						p(2, 1))))
+		})
+
+	expectParse(t, "for { break; continue }", func(p pfn) []Stmt {
+		return stmts(
+			forStmt(nil, nil, nil,
+				blockStmt(p(1, 5), p(1, 23),
+					branchStmt(token.Break, p(1, 7), nil),
+					branchStmt(token.Continue, p(1, 14), nil)),
+				p(1, 1)))
+	})
+
+	expectParseString(t, "a: b: for {}", "a: b: for {}")
+
+	expectParseError(t, `a.b: for {}`)
+	expectParseError(t, `a: `)
+	expectParseError(t, `for { break 1 }`)
+}
+
 func TestParseForIn(t *testing.T) {
 	expectParse(t, "for x in y {}", func(p pfn) []Stmt {
 		return stmts(
@@ -582,6 +700,33 @@ func TestParseForIn(t *testing.T) {
 	})
 }
 
+func TestParseExpr(t *testing.T) {
+	parse := func(input string) (Expr, error) {
+		testFileSet := NewFileSet()
+		testFile := testFileSet.AddFile("test", -1, len(input))
+		return NewParser(testFile, []byte(input), nil).ParseExpr()
+	}
+
+	expr, err := parse("price * qty > 100")
+	require.NoError(t, err)
+	equalExpr(t, binaryExpr(
+		binaryExpr(ident("price", 1), ident("qty", 9), token.Mul, 7),
+		intLit(100, 15), token.Greater, 13), expr)
+
+	expr, err = parse("a ? f(b) : c\n")
+	require.NoError(t, err)
+	require.Equal(t, "(a ? f(b) : c)", expr.String())
+
+	_, err = parse("")
+	require.Error(t, err)
+	_, err = parse("a + ")
+	require.Error(t, err)
+	_, err = parse("a := 1")
+	require.Error(t, err)
+	_, err = parse("a; b")
+	require.Error(t, err)
+}
+
 func TestParseFor(t *testing.T) {
 	expectParse(t, "for {}", func(p pfn) []Stmt {
 		return stmts(
@@ -1243,10 +1388,50 @@ key2: 2,
 }`)
 }
 
+func TestParseRecord(t *testing.T) {
+	expectParse(t, "Point := record { x: 1, y }", func(p pfn) []Stmt {
+		return stmts(assignStmt(
+			exprs(ident("Point", p(1, 1))),
+			exprs(recordLit(p(1, 10), p(1, 17), p(1, 27),
+				mapElementLit("x", p(1, 19), p(1, 20), intLit(1, p(1, 22))),
+				mapElementLit("y", p(1, 25), NoPos, undefinedLit(p(1, 25))))),
+			token.Define,
+			p(1, 7)))
+	})
+
+	expectParseString(t, `record { x: 1, len: func(r) { return r.x } }`,
+		`record {x: 1, len: func(r) {return r.x}}`)
+
+	// 'record' is an identifier unless it is followed by '{'
+	expectParse(t, "record := 1", func(p pfn) []Stmt {
+		return stmts(assignStmt(
+			exprs(ident("record", p(1, 1))),
+			exprs(intLit(1, p(1, 11))),
+			token.Define,
+			p(1, 8)))
+	})
+	expectParseString(t, `record.x + len(record)`,
+		`(record.x + len(record))`)
+	expectParseString(t, `if record { x }`, `if record {x}`)
+	expectParseString(t, `for record { x }`, `for record {x}`)
+	expectParseString(t, `f(record { x })`, `f(record {x: undefined})`)
+
+	expectParseError(t, `record { x: 1, }`)
+	expectParseError(t, `record { 1: 2 }`)
+}
+
 func TestParsePrecedence(t *testing.T) {
 	expectParseString(t, `a + b + c`, `((a + b) + c)`)
 	expectParseString(t, `a + b * c`, `(a + (b * c))`)
 	expectParseString(t, `x = 2 * 1 + 3 / 4`, `x = ((2 * 1) + (3 / 4))`)
+	expectParseString(t, `a ?? b || c`, `(a ?? (b || c))`)
+	expectParseString(t, `a || b ?? c`, `((a || b) ?? c)`)
+	expectParseString(t, `a ?? b ?? c`, `((a ?? b) ?? c)`)
+	expectParseString(t, `a ** b ** c`, `(a ** (b ** c))`)
+	expectParseString(t, `a * b ** c`, `(a * (b ** c))`)
+	expectParseString(t, `a ** b * c`, `((a ** b) * c)`)
+	expectParseString(t, `-a ** b`, `((-a) ** b)`)
+	expectParseString(t, `x **= 2`, `x **= 2`)
 }
 
 func TestParseSelector(t *testing.T) {
@@ -1427,6 +1612,49 @@ func TestParseSelector(t *testing.T) {
 	expectParseError(t, `a.(b.c)`)
 }
 
+func TestParseOptionalChaining(t *testing.T) {
+	expectParse(t, "a?.b.c", func(p pfn) []Stmt {
+		return stmts(
+			exprStmt(
+				selectorExpr(
+					&SelectorExpr{
+						Expr:     ident("a", p(1, 1)),
+						Sel:      stringLit("b", p(1, 4)),
+						Optional: true,
+					},
+					stringLit("c", p(1, 6)))))
+	})
+
+	expectParse(t, "a?.[0]", func(p pfn) []Stmt {
+		return stmts(
+			exprStmt(
+				&IndexExpr{
+					Expr:     ident("a", p(1, 1)),
+					Index:    intLit(0, p(1, 5)),
+					LBrack:   p(1, 4),
+					RBrack:   p(1, 6),
+					Optional: true,
+				}))
+	})
+
+	expectParse(t, "a?.[1:]", func(p pfn) []Stmt {
+		return stmts(
+			exprStmt(
+				&SliceExpr{
+					Expr:     ident("a", p(1, 1)),
+					Low:      intLit(1, p(1, 5)),
+					LBrack:   p(1, 4),
+					RBrack:   p(1, 7),
+					Optional: true,
+				}))
+	})
+
+	expectParseString(t, `a?.b?.[c]?.[1:2]()`, `a?.b?.[c]?.[1:2]()`)
+	expectParseString(t, `a ? .5 : b`, `(a ? .5 : b)`)
+	expectParseError(t, `a?.(b)`)
+	expectParseError(t, `a?.`)
+}
+
 func TestParseSemicolon(t *testing.T) {
 	expectParse(t, "1", func(p pfn) []Stmt {
 		return stmts(
@@ -1483,6 +1711,47 @@ func TestParseString(t *testing.T) {
 				token.Assign,
 				p(1, 3)))
 	})
+
+	expectParse(t, `a = f"x${b}\n${c + 1}"`, func(p pfn) []Stmt {
+		return stmts(
+			assignStmt(
+				exprs(ident("a", p(1, 1))),
+				exprs(interpStringLit(p(1, 5),
+					stringLit("x", p(1, 7)),
+					ident("b", p(1, 10)),
+					stringLit("\n", p(1, 12)),
+					binaryExpr(
+						ident("c", p(1, 16)),
+						intLit(1, p(1, 20)),
+						token.Add,
+						p(1, 18)))),
+				token.Assign,
+				p(1, 3)))
+	})
+
+	expectParse(t, `f"${m["}"]}\${x}"`, func(p pfn) []Stmt {
+		return stmts(
+			exprStmt(
+				interpStringLit(p(1, 1),
+					indexExpr(
+						ident("m", p(1, 5)),
+						stringLit("}", p(1, 7)),
+						p(1, 6), p(1, 10)),
+					stringLit("${x}", p(1, 12)))))
+	})
+
+	expectParse(t, `f""`, func(p pfn) []Stmt {
+		return stmts(exprStmt(interpStringLit(p(1, 1))))
+	})
+
+	expectParseString(t, `a = f"x ${y}"`, `a = f"x ${y}"`)
+	expectParseError(t, `f"${}"`)
+	expectParseError(t, `f"${a b}"`)
+	expectParseError(t, `f"${a"`)
+	expectParseError(t, `f"abc`)
+	expectParseError(t, `f"\q"`)
+	expectParseError(t, `{f"a": 1}`)
+	expectParseError(t, `import(f"a")`)
 }
 
 type pfn func(int, int) Pos          // position conversion function
@@ -1637,6 +1906,14 @@ func ifStmt(
 	}
 }
 
+func labeledStmt(label *Ident, colon Pos, stmt Stmt) *LabeledStmt {
+	return &LabeledStmt{Label: label, Colon: colon, Stmt: stmt}
+}
+
+func branchStmt(tok token.Token, pos Pos, label *Ident) *BranchStmt {
+	return &BranchStmt{Token: tok, TokenPos: pos, Label: label}
+}
+
 func incDecStmt(
 	expr Expr,
 	tok token.Token,
@@ -1721,6 +1998,41 @@ func boolLit(value bool, pos Pos) *BoolLit {
 	return &BoolLit{Value: value, ValuePos: pos}
 }
 
+func interpStringLit(pos Pos, parts ...Expr) *InterpStringLit {
+	return &InterpStringLit{ValuePos: pos, Parts: parts}
+}
+
+func arrayComprehension(
+	lbrack, rbrack Pos,
+	value Expr,
+	clauses ...*ComprehensionClause,
+) *ArrayComprehension {
+	return &ArrayComprehension{
+		Value: value, Clauses: clauses, LBrack: lbrack, RBrack: rbrack,
+	}
+}
+
+func mapComprehension(
+	lbrace, rbrace Pos,
+	key, value Expr,
+	clauses ...*ComprehensionClause,
+) *MapComprehension {
+	return &MapComprehension{
+		Key: key, Value: value, Clauses: clauses,
+		LBrace: lbrace, RBrace: rbrace,
+	}
+}
+
+func comprehensionClause(
+	pos Pos,
+	key, value *Ident,
+	iterable, cond Expr,
+) *ComprehensionClause {
+	return &ComprehensionClause{
+		ForPos: pos, Key: key, Value: value, Iterable: iterable, Cond: cond,
+	}
+}
+
 func arrayLit(lbracket, rbracket Pos, list ...Expr) *ArrayLit {
 	return &ArrayLit{LBrack: lbracket, RBrack: rbracket, Elements: list}
 }
@@ -1743,6 +2055,19 @@ func mapLit(
 	return &MapLit{LBrace: lbrace, RBrace: rbrace, Elements: list}
 }
 
+func recordLit(
+	recordPos, lbrace, rbrace Pos,
+	list ...*MapElementLit,
+) *RecordLit {
+	return &RecordLit{
+		RecordPos: recordPos, LBrace: lbrace, RBrace: rbrace, Elements: list,
+	}
+}
+
+func undefinedLit(pos Pos) *UndefinedLit {
+	return &UndefinedLit{TokenPos: pos}
+}
+
 func funcLit(funcType *FuncType, body *BlockStmt) *FuncLit {
 	return &FuncLit{Type: funcType, Body: body}
 }
@@ -1787,6 +2112,10 @@ func errorExpr(
 	}
 }
 
+func spreadExpr(x Expr, pos Pos) *SpreadExpr {
+	return &SpreadExpr{Expr: x, EllipsisPos: pos}
+}
+
 func selectorExpr(x, sel Expr) *SelectorExpr {
 	return &SelectorExpr{Expr: x, Sel: sel}
 }
@@ -1858,6 +2187,13 @@ func equalStmt(t *testing.T, expected, actual Stmt) {
 			actual.(*ReturnStmt).Result)
 		require.Equal(t, expected.ReturnPos,
 			actual.(*ReturnStmt).ReturnPos)
+	case *LabeledStmt:
+		equalExpr(t, expected.Label,
+			actual.(*LabeledStmt).Label)
+		require.Equal(t, expected.Colon,
+			actual.(*LabeledStmt).Colon)
+		equalStmt(t, expected.Stmt,
+			actual.(*LabeledStmt).Stmt)
 	case *BranchStmt:
 		equalExpr(t, expected.Label,
 			actual.(*BranchStmt).Label)
@@ -1909,6 +2245,11 @@ func equalExpr(t *testing.T, expected, actual Expr) {
 			actual.(*StringLit).Value)
 		require.Equal(t, int(expected.ValuePos),
 			int(actual.(*StringLit).ValuePos))
+	case *InterpStringLit:
+		require.Equal(t, int(expected.ValuePos),
+			int(actual.(*InterpStringLit).ValuePos))
+		equalExprs(t, expected.Parts,
+			actual.(*InterpStringLit).Parts)
 	case *ArrayLit:
 		require.Equal(t, expected.LBrack,
 			actual.(*ArrayLit).LBrack)
@@ -1923,6 +2264,38 @@ func equalExpr(t *testing.T, expected, actual Expr) {
 			actual.(*MapLit).RBrace)
 		equalMapElements(t, expected.Elements,
 			actual.(*MapLit).Elements)
+	case *ArrayComprehension:
+		require.Equal(t, expected.LBrack,
+			actual.(*ArrayComprehension).LBrack)
+		require.Equal(t, expected.RBrack,
+			actual.(*ArrayComprehension).RBrack)
+		equalExpr(t, expected.Value,
+			actual.(*ArrayComprehension).Value)
+		equalComprehensionClauses(t, expected.Clauses,
+			actual.(*ArrayComprehension).Clauses)
+	case *MapComprehension:
+		require.Equal(t, expected.LBrace,
+			actual.(*MapComprehension).LBrace)
+		require.Equal(t, expected.RBrace,
+			actual.(*MapComprehension).RBrace)
+		equalExpr(t, expected.Key,
+			actual.(*MapComprehension).Key)
+		equalExpr(t, expected.Value,
+			actual.(*MapComprehension).Value)
+		equalComprehensionClauses(t, expected.Clauses,
+			actual.(*MapComprehension).Clauses)
+	case *RecordLit:
+		require.Equal(t, expected.RecordPos,
+			actual.(*RecordLit).RecordPos)
+		require.Equal(t, expected.LBrace,
+			actual.(*RecordLit).LBrace)
+		require.Equal(t, expected.RBrace,
+			actual.(*RecordLit).RBrace)
+		equalMapElements(t, expected.Elements,
+			actual.(*RecordLit).Elements)
+	case *UndefinedLit:
+		require.Equal(t, expected.TokenPos,
+			actual.(*UndefinedLit).TokenPos)
 	case *BinaryExpr:
 		equalExpr(t, expected.LHS,
 			actual.(*BinaryExpr).LHS)
@@ -1969,6 +2342,8 @@ func equalExpr(t *testing.T, expected, actual Expr) {
 			actual.(*IndexExpr).LBrack)
 		require.Equal(t, expected.RBrack,
 			actual.(*IndexExpr).RBrack)
+		require.Equal(t, expected.Optional,
+			actual.(*IndexExpr).Optional)
 	case *SliceExpr:
 		equalExpr(t, expected.Expr,
 			actual.(*SliceExpr).Expr)
@@ -1980,11 +2355,15 @@ func equalExpr(t *testing.T, expected, actual Expr) {
 			actual.(*SliceExpr).LBrack)
 		require.Equal(t, expected.RBrack,
 			actual.(*SliceExpr).RBrack)
+		require.Equal(t, expected.Optional,
+			actual.(*SliceExpr).Optional)
 	case *SelectorExpr:
 		equalExpr(t, expected.Expr,
 			actual.(*SelectorExpr).Expr)
 		equalExpr(t, expected.Sel,
 			actual.(*SelectorExpr).Sel)
+		require.Equal(t, expected.Optional,
+			actual.(*SelectorExpr).Optional)
 	case *ImportExpr:
 		require.Equal(t, expected.ModuleName,
 			actual.(*ImportExpr).ModuleName)
@@ -2001,6 +2380,11 @@ func equalExpr(t *testing.T, expected, actual Expr) {
 			int(actual.(*ErrorExpr).LParen))
 		require.Equal(t, int(expected.RParen),
 			int(actual.(*ErrorExpr).RParen))
+	case *SpreadExpr:
+		equalExpr(t, expected.Expr,
+			actual.(*SpreadExpr).Expr)
+		require.Equal(t, int(expected.EllipsisPos),
+			int(actual.(*SpreadExpr).EllipsisPos))
 	case *CondExpr:
 		equalExpr(t, expected.Cond,
 			actual.(*CondExpr).Cond)
@@ -2017,6 +2401,20 @@ func equalExpr(t *testing.T, expected, actual Expr) {
 	}
 }
 
+func equalComprehensionClauses(
+	t *testing.T,
+	expected, actual []*ComprehensionClause,
+) {
+	require.Equal(t, len(expected), len(actual))
+	for i := 0; i < len(expected); i++ {
+		require.Equal(t, expected[i].ForPos, actual[i].ForPos)
+		equalExpr(t, expected[i].Key, actual[i].Key)
+		equalExpr(t, expected[i].Value, actual[i].Value)
+		equalExpr(t, expected[i].Iterable, actual[i].Iterable)
+		equalExpr(t, expected[i].Cond, actual[i].Cond)
+	}
+}
+
 func equalFuncType(t *testing.T, expected, actual *FuncType) {
 	require.Equal(t, expected.Params.LParen, actual.Params.LParen)
 	require.Equal(t, expected.Params.RParen, actual.Params.RParen)
@@ -66,6 +66,26 @@ func NewScanner(
 	return s
 }
 
+// newSubScanner creates a Scanner that reads the source of s in [from, to)
+// offsets range. It is used to scan the expressions embedded in interpolated
+// strings.
+func newSubScanner(
+	s *Scanner,
+	from, to int,
+	errorHandler ScannerErrorHandler,
+) *Scanner {
+	sub := &Scanner{
+		file:         s.file,
+		src:          s.src[:to],
+		errorHandler: errorHandler,
+		ch:           ' ',
+		readOffset:   from,
+		mode:         DontInsertSemis,
+	}
+	sub.next()
+	return sub
+}
+
 // ErrorCount returns the number of errors.
 func (s *Scanner) ErrorCount() int {
 	return s.errorCount
@@ -87,6 +107,13 @@ func (s *Scanner) Scan() (
 	switch ch := s.ch; {
 	case isLetter(ch):
 		literal = s.scanIdentifier()
+		if literal == "f" && s.ch == '"' {
+			s.next()
+			insertSemi = true
+			tok = token.String
+			literal = s.scanInterpString()
+			break
+		}
 		tok = token.Lookup(literal)
 		switch tok {
 		case token.Ident, token.Break, token.Continue, token.Return,
@@ -139,7 +166,17 @@ func (s *Scanner) Scan() (
 		case ',':
 			tok = token.Comma
 		case '?':
-			tok = token.Question
+			switch {
+			case s.ch == '?':
+				s.next()
+				tok = token.Coalesce
+			case s.ch == '.' && !isDigit(rune(s.peek())):
+				// "?.5" is a conditional expression followed by a float
+				s.next()
+				tok = token.QuestionPeriod
+			default:
+				tok = token.Question
+			}
 		case ';':
 			tok = token.Semicolon
 			literal = ";"
@@ -169,7 +206,8 @@ func (s *Scanner) Scan() (
 				insertSemi = true
 			}
 		case '*':
-			tok = s.switch2(token.Mul, token.MulAssign)
+			tok = s.switch4(token.Mul, token.MulAssign, '*', token.Pow,
+				token.PowAssign)
 		case '/':
 			if s.ch == '/' || s.ch == '*' {
 				// comment
@@ -568,6 +606,44 @@ func (s *Scanner) scanString() string {
 	return string(s.src[offs:s.offset])
 }
 
+func (s *Scanner) scanInterpString() string {
+	offs := s.offset - 2 // 'f"' opening already consumed
+
+	for {
+		ch := s.ch
+		if ch == '\n' || ch < 0 {
+			s.error(offs, "string literal not terminated")
+			break
+		}
+		s.next()
+		if ch == '"' {
+			break
+		}
+		if ch == '\\' {
+			if s.ch == '$' {
+				s.next()
+			} else {
+				s.scanEscape('"')
+			}
+			continue
+		}
+		if ch == '$' && s.ch == '{' {
+			end := interpExprEnd(s.src, s.readOffset)
+			if end < 0 {
+				s.error(s.offset, "interpolation not terminated")
+				for s.ch != '\n' && s.ch >= 0 {
+					s.next()
+				}
+				break
+			}
+			for s.offset <= end {
+				s.next()
+			}
+		}
+	}
+	return string(s.src[offs:s.offset])
+}
+
 func (s *Scanner) scanRawString() string {
 	offs := s.offset - 1 // '`' opening already consumed
 
@@ -666,6 +742,94 @@ func (s *Scanner) switch4(
 	return tok0
 }
 
+// interpExprEnd returns the offset of the closing brace of an interpolation
+// ("${expr}") whose expression starts at offs, or -1 if the interpolation is
+// not terminated in the same line.
+func interpExprEnd(src []byte, offs int) int {
+	depth := 0
+	for i := offs; i < len(src); i++ {
+		var end int
+		switch ch := src[i]; ch {
+		case '\n':
+			return -1
+		case '{':
+			depth++
+			continue
+		case '}':
+			if depth == 0 {
+				return i
+			}
+			depth--
+			continue
+		case '"':
+			if i > offs && src[i-1] == 'f' &&
+				(i-1 == offs || !isIdentByte(src[i-2])) {
+				end = interpStringEnd(src, i+1)
+			} else {
+				end = quotedEnd(src, i+1, ch)
+			}
+		case '\'', '`':
+			end = quotedEnd(src, i+1, ch)
+		default:
+			continue
+		}
+		if end < 0 {
+			return -1
+		}
+		i = end - 1
+	}
+	return -1
+}
+
+// interpStringEnd returns the offset immediately after the closing quote of
+// an interpolated string whose content starts at offs, or -1 if the string is
+// not terminated in the same line.
+func interpStringEnd(src []byte, offs int) int {
+	for i := offs; i < len(src); i++ {
+		switch src[i] {
+		case '\n':
+			return -1
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		case '$':
+			if i+1 < len(src) && src[i+1] == '{' {
+				end := interpExprEnd(src, i+2)
+				if end < 0 {
+					return -1
+				}
+				i = end
+			}
+		}
+	}
+	return -1
+}
+
+// quotedEnd returns the offset immediately after the closing quote of a
+// string or a char literal whose content starts at offs, or -1 if the
+// literal is not terminated in the same line.
+func quotedEnd(src []byte, offs int, quote byte) int {
+	for i := offs; i < len(src); i++ {
+		switch src[i] {
+		case '\n':
+			return -1
+		case '\\':
+			if quote != '`' {
+				i++
+			}
+		case quote:
+			return i + 1
+		}
+	}
+	return -1
+}
+
+func isIdentByte(b byte) bool {
+	return b == '_' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' ||
+		'0' <= b && b <= '9' || b >= utf8.RuneSelf
+}
+
 func isLetter(ch rune) bool {
 	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_' ||
 		ch >= utf8.RuneSelf && unicode.IsLetter(ch)
@@ -62,6 +62,9 @@ func TestScanner_Scan(t *testing.T) {
 		},
 		{token.String, "`\r`"},
 		{token.String, "`foo\r\nbar`"},
+		{token.String, `f"foo"`},
+		{token.String, `f"foo ${bar} \${baz}"`},
+		{token.String, `f"${a["}"] + f"${b}" + '"'}"`},
 		{token.Add, "+"},
 		{token.Sub, "-"},
 		{token.Mul, "*"},
@@ -108,6 +111,11 @@ func TestScanner_Scan(t *testing.T) {
 		{token.RBrace, "}"},
 		{token.Semicolon, ";"},
 		{token.Colon, ":"},
+		{token.Question, "?"},
+		{token.QuestionPeriod, "?."},
+		{token.Coalesce, "??"},
+		{token.Pow, "**"},
+		{token.PowAssign, "**="},
 		{token.Break, "break"},
 		{token.Continue, "continue"},
 		{token.Else, "else"},
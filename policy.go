@@ -0,0 +1,48 @@
+package tengo
+
+// Policy restricts the capabilities of the scripts. It is enforced at compile
+// time, and, the compilation fails with CompilerError pointing at the first
+// code that violates the policy. The policy applies to the modules imported
+// by the script as well.
+type Policy struct {
+	// AllowedBuiltins is the names of the builtin functions the script can
+	// use. All builtin functions are allowed if nil.
+	AllowedBuiltins []string
+
+	// AllowedModules is the names of the modules the script can import,
+	// including the module files. All modules are allowed if nil.
+	AllowedModules []string
+
+	// DisallowFileImport disallows importing the module files even if the
+	// file import is enabled.
+	DisallowFileImport bool
+
+	// DisallowInfiniteLoops disallows 'for' statements without condition,
+	// or, with a constant condition that is always true, e.g. 'for true {}'.
+	// Loops that never end for other reasons are not detected: use a context
+	// or the allocation limit to stop them.
+	DisallowInfiniteLoops bool
+
+	// DisallowClosures disallows function literals that capture variables of
+	// the enclosing functions.
+	DisallowClosures bool
+}
+
+func (p *Policy) allowsBuiltin(name string) bool {
+	return p == nil || p.AllowedBuiltins == nil ||
+		containsName(p.AllowedBuiltins, name)
+}
+
+func (p *Policy) allowsModule(name string) bool {
+	return p == nil || p.AllowedModules == nil ||
+		containsName(p.AllowedModules, name)
+}
+
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
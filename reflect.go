@@ -0,0 +1,834 @@
+package tengo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	callableType = reflect.TypeOf(CallableFunc(nil))
+	errorType    = reflect.TypeOf((*error)(nil)).Elem()
+	timeType     = reflect.TypeOf(time.Time{})
+	bigIntType   = reflect.TypeOf(big.Int{})
+	bigRatType   = reflect.TypeOf(big.Rat{})
+	bigFloatType = reflect.TypeOf(big.Float{})
+)
+
+// Decode stores the Go value of the object o in the value pointed to by v,
+// like json.Unmarshal does. Maps and records are decoded into Go maps and
+// structs, arrays and sets into Go slices and arrays. Struct fields are
+// matched by their names or by their `tengo:"name"` tags. It returns an
+// error if v is not a non-nil pointer or if o cannot be stored in it.
+func Decode(o Object, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("decode target must be a non-nil pointer")
+	}
+	return decodeValue(o, rv.Elem())
+}
+
+// WrapFunc turns a Go function fn into a CallableFunc. The arguments are
+// converted to the parameter types of fn as Decode does, and, the function
+// returns ErrWrongNumArguments or ErrInvalidArgumentType if they cannot be
+// converted. Variadic functions are supported. A non-nil error returned as
+// the last result of fn is returned as an error object, and, a nil error as
+// true if fn has no other results. The other results are converted as
+// FromInterface does: multiple results are returned in an array.
+func WrapFunc(fn interface{}) (CallableFunc, error) {
+	rv := reflect.ValueOf(fn)
+	if rv.Kind() != reflect.Func || rv.IsNil() {
+		return nil, fmt.Errorf("not a function: %T", fn)
+	}
+	t := rv.Type()
+	if t.ConvertibleTo(callableType) {
+		return rv.Convert(callableType).Interface().(CallableFunc), nil
+	}
+
+	numIn := t.NumIn()
+	if t.IsVariadic() {
+		numIn--
+	}
+	numOut := t.NumOut()
+	hasErr := numOut > 0 && t.Out(numOut-1) == errorType
+	if hasErr {
+		numOut--
+	}
+
+	return func(args ...Object) (Object, error) {
+		if len(args) < numIn || (!t.IsVariadic() && len(args) > numIn) {
+			return nil, ErrWrongNumArguments
+		}
+		in := make([]reflect.Value, len(args))
+		for i, arg := range args {
+			var at reflect.Type
+			if i < numIn {
+				at = t.In(i)
+			} else {
+				at = t.In(numIn).Elem()
+			}
+			in[i] = reflect.New(at).Elem()
+			if err := decodeValue(arg, in[i]); err != nil {
+				return nil, ErrInvalidArgumentType{
+					Name:     argumentName(i),
+					Expected: expectedTypeName(at),
+					Found:    arg.TypeName(),
+				}
+			}
+		}
+
+		out := rv.Call(in)
+		if hasErr {
+			if err, _ := out[numOut].Interface().(error); err != nil {
+				return &Error{Value: &String{Value: err.Error()}}, nil
+			}
+			if numOut == 0 {
+				return TrueValue, nil
+			}
+		}
+		switch numOut {
+		case 0:
+			return UndefinedValue, nil
+		case 1:
+			return FromInterface(out[0].Interface())
+		}
+		res := make([]Object, numOut)
+		for i := range res {
+			o, err := FromInterface(out[i].Interface())
+			if err != nil {
+				return nil, err
+			}
+			res[i] = o
+		}
+		return &Array{Value: res}, nil
+	}, nil
+}
+
+var argumentNames = []string{"first", "second", "third", "fourth", "fifth",
+	"sixth", "seventh", "eighth", "ninth", "tenth"}
+
+func argumentName(i int) string {
+	if i < len(argumentNames) {
+		return argumentNames[i]
+	}
+	return fmt.Sprintf("#%d", i+1)
+}
+
+// expectedTypeName returns the name of the object type that can be converted
+// to the Go type t.
+func expectedTypeName(t reflect.Type) string {
+	switch t {
+	case timeType:
+		return "time"
+	case bigIntType:
+		return "bigint(compatible)"
+	case bigRatType, bigFloatType:
+		return "decimal(compatible)"
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "bool"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16,
+		reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return "int(compatible)"
+	case reflect.Float32, reflect.Float64:
+		return "float(compatible)"
+	case reflect.String:
+		return "string"
+	case reflect.Ptr:
+		return expectedTypeName(t.Elem())
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "bytes/array"
+		}
+		return "array"
+	case reflect.Array:
+		return "array"
+	case reflect.Map:
+		return "map/set"
+	case reflect.Struct:
+		return "map"
+	}
+	return t.String()
+}
+
+// fromValue converts a Go value, that FromInterface does not convert
+// directly, to an object using reflection.
+func fromValue(
+	rv reflect.Value,
+	visited map[visitKey]bool,
+) (Object, error) {
+	switch rv.Kind() {
+	case reflect.Invalid:
+		return UndefinedValue, nil
+	case reflect.Bool:
+		if rv.Bool() {
+			return TrueValue, nil
+		}
+		return FalseValue, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		return &Int{Value: rv.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		u := rv.Uint()
+		if u > math.MaxInt64 {
+			return &BigInt{Value: new(big.Int).SetUint64(u)}, nil
+		}
+		return &Int{Value: int64(u)}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Float{Value: rv.Float()}, nil
+	case reflect.String:
+		return FromInterface(rv.String())
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return UndefinedValue, nil
+		}
+		visited, err := enterValue(visited, rv)
+		if err != nil {
+			return nil, err
+		}
+		defer leaveValue(visited, rv)
+		return fromInterface(rv.Elem().Interface(), visited)
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return FromInterface(rv.Bytes())
+		}
+		visited, err := enterValue(visited, rv)
+		if err != nil {
+			return nil, err
+		}
+		defer leaveValue(visited, rv)
+		return arrayFromValue(rv, visited)
+	case reflect.Array:
+		return arrayFromValue(rv, visited)
+	case reflect.Map:
+		visited, err := enterValue(visited, rv)
+		if err != nil {
+			return nil, err
+		}
+		defer leaveValue(visited, rv)
+		return mapFromValue(rv, visited)
+	case reflect.Struct:
+		return structFromValue(rv, visited)
+	case reflect.Func:
+		if rv.IsNil() {
+			return UndefinedValue, nil
+		}
+		fn, err := WrapFunc(rv.Interface())
+		if err != nil {
+			return nil, err
+		}
+		return &UserFunction{Value: fn}, nil
+	}
+	return nil, fmt.Errorf("cannot convert to object: %s", rv.Type())
+}
+
+func arrayFromValue(
+	rv reflect.Value,
+	visited map[visitKey]bool,
+) (Object, error) {
+	arr := make([]Object, rv.Len())
+	for i := range arr {
+		eo, err := fromInterface(rv.Index(i).Interface(), visited)
+		if err != nil {
+			return nil, err
+		}
+		arr[i] = eo
+	}
+	return &Array{Value: arr}, nil
+}
+
+func mapFromValue(
+	rv reflect.Value,
+	visited map[visitKey]bool,
+) (Object, error) {
+	if rv.Type().Elem() == reflect.TypeOf(struct{}{}) {
+		s := &Set{}
+		for _, k := range rv.MapKeys() {
+			ko, err := keyFromInterface(k.Interface())
+			if err != nil {
+				return nil, err
+			}
+			if !s.add(ko) {
+				return nil, ErrNotHashable
+			}
+		}
+		return s, nil
+	}
+
+	m := &Map{Value: make(map[string]Object)}
+	for _, k := range rv.MapKeys() {
+		vo, err := fromInterface(rv.MapIndex(k).Interface(), visited)
+		if err != nil {
+			return nil, err
+		}
+		if k.Kind() == reflect.String {
+			// string keys are ordered by the keys
+			m.Value[k.String()] = vo
+			continue
+		}
+		ko, err := keyFromInterface(k.Interface())
+		if err != nil {
+			return nil, err
+		}
+		if err := m.IndexSet(ko, vo); err != nil {
+			return nil, ErrNotHashable
+		}
+	}
+	return m, nil
+}
+
+func structFromValue(
+	rv reflect.Value,
+	visited map[visitKey]bool,
+) (Object, error) {
+	m := &Map{Value: make(map[string]Object)}
+	for _, f := range structFields(rv.Type()) {
+		fv, ok := fieldByIndex(rv, f.index)
+		if !ok || !fv.CanInterface() || (f.omitEmpty && isEmptyValue(fv)) {
+			continue
+		}
+		vo, err := fromInterface(fv.Interface(), visited)
+		if err != nil {
+			return nil, err
+		}
+		// keeps the fields in their declaration order
+		if err := m.IndexSet(&String{Value: f.name}, vo); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// visitKey identifies a Go value that refers to other values: the address
+// of the value and its type, as a struct and its first field have the same
+// address.
+type visitKey struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+// visitKeyOf returns the visitKey of a pointer, a map or a slice value.
+// Other values, and, nil or empty values cannot refer to themselves.
+func visitKeyOf(rv reflect.Value) (visitKey, bool) {
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return visitKey{}, false
+		}
+	case reflect.Map, reflect.Slice:
+		if rv.Len() == 0 {
+			return visitKey{}, false
+		}
+	default:
+		return visitKey{}, false
+	}
+	return visitKey{ptr: rv.Pointer(), typ: rv.Type()}, true
+}
+
+// enterValue marks the Go value rv as being converted to an object, and,
+// returns the marked values. It returns an error if rv is already being
+// converted, i.e. if rv refers to itself.
+func enterValue(
+	visited map[visitKey]bool,
+	rv reflect.Value,
+) (map[visitKey]bool, error) {
+	key, ok := visitKeyOf(rv)
+	if !ok {
+		return visited, nil
+	}
+	if visited[key] {
+		return nil, fmt.Errorf("cannot convert to object: cyclic value: %s",
+			rv.Type())
+	}
+	if visited == nil {
+		visited = make(map[visitKey]bool)
+	}
+	visited[key] = true
+	return visited, nil
+}
+
+// leaveValue unmarks the Go value rv marked by enterValue. The same value can
+// be converted again if it is not a part of itself.
+func leaveValue(visited map[visitKey]bool, rv reflect.Value) {
+	if key, ok := visitKeyOf(rv); ok {
+		delete(visited, key)
+	}
+}
+
+// structField is an exported field of a Go struct type, including the fields
+// of the embedded structs.
+type structField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+// fieldTable is the fields of a Go struct type in their order and by their
+// names.
+type fieldTable struct {
+	fields []structField
+	byName map[string]*structField
+}
+
+// fieldTables caches the fieldTable of the struct types.
+var fieldTables sync.Map // reflect.Type to *fieldTable
+
+// structFields returns the fields of a Go struct type. Fields are named by
+// their `tengo:"name[,omitempty]"` tags or by their names. Fields with the
+// tag `tengo:"-"` are ignored. The fields are shared by all the callers and
+// must not be modified.
+func structFields(t reflect.Type) []structField {
+	return typeFields(t).fields
+}
+
+// typeFields returns the fieldTable of the struct type t. It is computed
+// once for each type.
+func typeFields(t reflect.Type) *fieldTable {
+	if ft, ok := fieldTables.Load(t); ok {
+		return ft.(*fieldTable)
+	}
+	fields := collectStructFields(t)
+	ft := &fieldTable{
+		fields: fields,
+		byName: make(map[string]*structField, len(fields)),
+	}
+	for i := range fields {
+		ft.byName[fields[i].name] = &fields[i]
+	}
+	actual, _ := fieldTables.LoadOrStore(t, ft)
+	return actual.(*fieldTable)
+}
+
+// collectStructFields returns the fields of a Go struct type for
+// structFields.
+func collectStructFields(t reflect.Type) []structField {
+	var fields []structField
+	names := make(map[string]int) // name to the depth of the field
+	var collect func(t reflect.Type, index []int)
+	collect = func(t reflect.Type, index []int) {
+		var embedded [][]int
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			tag := sf.Tag.Get("tengo")
+			if tag == "-" {
+				continue
+			}
+			name, opts := tag, ""
+			if idx := strings.Index(tag, ","); idx >= 0 {
+				name, opts = tag[:idx], tag[idx+1:]
+			}
+			fieldIndex := append(index[:len(index):len(index)], i)
+			if sf.Anonymous && name == "" {
+				ft := sf.Type
+				if ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+				if ft.Kind() == reflect.Struct {
+					embedded = append(embedded, fieldIndex)
+					continue
+				}
+			}
+			if sf.PkgPath != "" { // unexported
+				continue
+			}
+			if name == "" {
+				name = sf.Name
+			}
+			if depth, ok := names[name]; ok && depth <= len(index) {
+				continue
+			}
+			names[name] = len(index)
+			fields = append(fields, structField{
+				name:      name,
+				index:     fieldIndex,
+				omitEmpty: opts == "omitempty",
+			})
+		}
+		// fields of the embedded structs after the fields of the outer
+		// struct so the outer fields take precedence
+		for _, idx := range embedded {
+			ft := t.Field(idx[len(idx)-1]).Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			collect(ft, idx)
+		}
+	}
+	collect(t, nil)
+	return fields
+}
+
+// fieldByIndex returns the field of the struct value v. It returns false if
+// the field is in a nil embedded struct pointer.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// decodeValue stores the Go value of the object o in the settable value rv.
+func decodeValue(o Object, rv reflect.Value) error {
+	if o == nil {
+		o = UndefinedValue
+	}
+	if p, ok := o.(*Proxy); ok && p.v.Type().AssignableTo(rv.Type()) {
+		rv.Set(p.v)
+		return nil
+	}
+	if rv.Kind() != reflect.Interface || rv.NumMethod() != 0 {
+		// objects are stored as they are in object typed values
+		if reflect.TypeOf(o).AssignableTo(rv.Type()) {
+			rv.Set(reflect.ValueOf(o))
+			return nil
+		}
+	}
+
+	switch rv.Type() {
+	case timeType:
+		t, ok := ToTime(o)
+		if !ok {
+			return decodeError(o, rv)
+		}
+		rv.Set(reflect.ValueOf(t))
+		return nil
+	case bigIntType:
+		i, ok := ToBigInt(o)
+		if !ok {
+			return decodeError(o, rv)
+		}
+		rv.Addr().Interface().(*big.Int).Set(i)
+		return nil
+	case bigRatType:
+		d, ok := ToDecimal(o)
+		if !ok {
+			return decodeError(o, rv)
+		}
+		rv.Addr().Interface().(*big.Rat).Set(d.rat())
+		return nil
+	case bigFloatType:
+		d, ok := ToDecimal(o)
+		if !ok {
+			return decodeError(o, rv)
+		}
+		rv.Addr().Interface().(*big.Float).SetPrec(256).SetRat(d.rat())
+		return nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Interface:
+		if rv.NumMethod() != 0 {
+			return decodeError(o, rv)
+		}
+		if v := ToInterface(o); v != nil {
+			rv.Set(reflect.ValueOf(v))
+		} else {
+			rv.Set(reflect.Zero(rv.Type()))
+		}
+		return nil
+	case reflect.Ptr:
+		if o == UndefinedValue {
+			rv.Set(reflect.Zero(rv.Type()))
+			return nil
+		}
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		return decodeValue(o, rv.Elem())
+	case reflect.Bool:
+		b, ok := o.(*Bool)
+		if !ok {
+			return decodeError(o, rv)
+		}
+		rv.SetBool(b == TrueValue)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		i, ok := decodeInt(o)
+		if !ok || rv.OverflowInt(i) {
+			return decodeError(o, rv)
+		}
+		rv.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		if bi, ok := o.(*BigInt); ok {
+			if !bi.Value.IsUint64() || rv.OverflowUint(bi.Value.Uint64()) {
+				return decodeError(o, rv)
+			}
+			rv.SetUint(bi.Value.Uint64())
+			return nil
+		}
+		i, ok := decodeInt(o)
+		if !ok || i < 0 || rv.OverflowUint(uint64(i)) {
+			return decodeError(o, rv)
+		}
+		rv.SetUint(uint64(i))
+		return nil
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		switch o := o.(type) {
+		case *Float:
+			f = o.Value
+		case *Int:
+			f = float64(o.Value)
+		case *BigInt, *Decimal:
+			f, _ = ToFloat64(o)
+		default:
+			return decodeError(o, rv)
+		}
+		rv.SetFloat(f)
+		return nil
+	case reflect.String:
+		switch o := o.(type) {
+		case *String:
+			rv.SetString(o.Value)
+		case *Char:
+			rv.SetString(string(o.Value))
+		default:
+			return decodeError(o, rv)
+		}
+		return nil
+	case reflect.Slice:
+		if o == UndefinedValue {
+			rv.Set(reflect.Zero(rv.Type()))
+			return nil
+		}
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			if b, ok := o.(*Bytes); ok {
+				rv.SetBytes(append([]byte{}, b.Value...))
+				return nil
+			}
+		}
+		elems, ok := sequenceElements(o)
+		if !ok {
+			return decodeError(o, rv)
+		}
+		s := reflect.MakeSlice(rv.Type(), len(elems), len(elems))
+		for i, e := range elems {
+			if err := decodeValue(e, s.Index(i)); err != nil {
+				return err
+			}
+		}
+		rv.Set(s)
+		return nil
+	case reflect.Array:
+		elems, ok := sequenceElements(o)
+		if !ok {
+			return decodeError(o, rv)
+		}
+		for i := 0; i < rv.Len(); i++ {
+			if i >= len(elems) {
+				rv.Index(i).Set(reflect.Zero(rv.Type().Elem()))
+				continue
+			}
+			if err := decodeValue(elems[i], rv.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		return decodeMap(o, rv)
+	case reflect.Struct:
+		return decodeStruct(o, rv)
+	}
+	return decodeError(o, rv)
+}
+
+func decodeMap(o Object, rv reflect.Value) error {
+	if o == UndefinedValue {
+		rv.Set(reflect.Zero(rv.Type()))
+		return nil
+	}
+	t := rv.Type()
+	m := reflect.MakeMap(t)
+	if elems, ok := setElements(o); ok {
+		// sets into map[T]struct{} or map[T]bool
+		var ev reflect.Value
+		switch {
+		case t.Elem() == reflect.TypeOf(struct{}{}):
+			ev = reflect.ValueOf(struct{}{})
+		case t.Elem().Kind() == reflect.Bool:
+			ev = reflect.ValueOf(true).Convert(t.Elem())
+		default:
+			return decodeError(o, rv)
+		}
+		for _, e := range elems {
+			kv := reflect.New(t.Key()).Elem()
+			if err := decodeValue(e, kv); err != nil {
+				return err
+			}
+			m.SetMapIndex(kv, ev)
+		}
+		rv.Set(m)
+		return nil
+	}
+
+	entries, ok := mapEntries(o)
+	if !ok {
+		return decodeError(o, rv)
+	}
+	for _, e := range entries {
+		kv := reflect.New(t.Key()).Elem()
+		if err := decodeValue(e.Key, kv); err != nil {
+			return err
+		}
+		ev := reflect.New(t.Elem()).Elem()
+		if err := decodeValue(e.Value, ev); err != nil {
+			return err
+		}
+		m.SetMapIndex(kv, ev)
+	}
+	rv.Set(m)
+	return nil
+}
+
+func decodeStruct(o Object, rv reflect.Value) error {
+	entries, ok := mapEntries(o)
+	if !ok {
+		return decodeError(o, rv)
+	}
+	fields := structFields(rv.Type())
+	for _, e := range entries {
+		key, ok := e.Key.(*String)
+		if !ok {
+			continue
+		}
+		f := findField(fields, key.Value)
+		if f == nil {
+			continue
+		}
+		fv := rv
+		for i, x := range f.index {
+			if i > 0 && fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					if !fv.CanSet() {
+						break
+					}
+					fv.Set(reflect.New(fv.Type().Elem()))
+				}
+				fv = fv.Elem()
+			}
+			fv = fv.Field(x)
+		}
+		if !fv.CanSet() {
+			continue
+		}
+		if err := decodeValue(e.Value, fv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findField returns the struct field of the name, preferring an exact match
+// to a case-insensitive match.
+func findField(fields []structField, name string) *structField {
+	var found *structField
+	for i := range fields {
+		if fields[i].name == name {
+			return &fields[i]
+		}
+		if found == nil && strings.EqualFold(fields[i].name, name) {
+			found = &fields[i]
+		}
+	}
+	return found
+}
+
+func decodeInt(o Object) (int64, bool) {
+	switch o := o.(type) {
+	case *Int:
+		return o.Value, true
+	case *Char:
+		return int64(o.Value), true
+	case *BigInt:
+		if o.Value.IsInt64() {
+			return o.Value.Int64(), true
+		}
+	case *Float:
+		if o.Value == math.Trunc(o.Value) &&
+			o.Value >= math.MinInt64 && o.Value < math.MaxInt64 {
+			return int64(o.Value), true
+		}
+	}
+	return 0, false
+}
+
+// sequenceElements returns the elements of an array or a set.
+func sequenceElements(o Object) ([]Object, bool) {
+	switch o := o.(type) {
+	case *Array:
+		return o.Value, true
+	case *ImmutableArray:
+		return o.Value, true
+	}
+	return setElements(o)
+}
+
+func setElements(o Object) ([]Object, bool) {
+	switch o := o.(type) {
+	case *Set:
+		return o.elems, true
+	case *ImmutableSet:
+		return o.elems, true
+	}
+	return nil, false
+}
+
+// mapEntries returns the elements of a map or a record in their order.
+func mapEntries(o Object) ([]MapEntry, bool) {
+	switch o := o.(type) {
+	case *Map, *ImmutableMap:
+		var entries []MapEntry
+		for it := o.Iterate(); it.Next(); {
+			entries = append(entries, MapEntry{Key: it.Key(), Value: it.Value()})
+		}
+		return entries, true
+	case *Record:
+		entries := make([]MapEntry, len(o.Type.Fields))
+		for i, name := range o.Type.Fields {
+			entries[i] = MapEntry{Key: &String{Value: name}, Value: o.Values[i]}
+		}
+		return entries, true
+	}
+	return nil, false
+}
+
+func decodeError(o Object, rv reflect.Value) error {
+	return fmt.Errorf("cannot decode %s into %s", o.TypeName(), rv.Type())
+}
@@ -142,6 +142,8 @@ func Equal(
 		Equal(t, expected.Value, actual.(*tengo.String).Value, msg...)
 	case *tengo.Char:
 		Equal(t, expected.Value, actual.(*tengo.Char).Value, msg...)
+	case *tengo.Decimal:
+		Equal(t, expected.String(), actual.(*tengo.Decimal).String(), msg...)
 	case *tengo.Bool:
 		if expected != actual {
 			failExpectedActual(t, expected, actual, msg...)
@@ -3,6 +3,7 @@ package tengo
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
 
 	"github.com/d5/tengo/v2/parser"
@@ -12,16 +13,20 @@ import (
 type Script struct {
 	variables        map[string]*Variable
 	modules          *ModuleMap
+	policy           *Policy
+	builtins         map[string]*BuiltinFunction // nil to remove
 	input            []byte
 	maxAllocs        int64
 	maxConstObjects  int
 	enableFileImport bool
+	overflowCheck    bool
 }
 
 // NewScript creates a Script instance with an input script.
 func NewScript(input []byte) *Script {
 	return &Script{
 		variables:       make(map[string]*Variable),
+		builtins:        make(map[string]*BuiltinFunction),
 		input:           input,
 		maxAllocs:       -1,
 		maxConstObjects: -1,
@@ -51,11 +56,42 @@ func (s *Script) Remove(name string) bool {
 	return true
 }
 
+// SetBuiltin adds a new builtin function or replaces an existing builtin
+// function of the script. Unlike variables, builtin functions do not use the
+// global variable slots.
+func (s *Script) SetBuiltin(name string, fn CallableFunc) {
+	s.builtins[name] = &BuiltinFunction{
+		Name:  name,
+		Value: fn,
+	}
+}
+
+// RemoveBuiltin removes an existing builtin function from the script. It
+// returns false if the builtin function name is not defined.
+func (s *Script) RemoveBuiltin(name string) bool {
+	fn, ok := s.builtins[name]
+	if (ok && fn == nil) || (!ok && !isDefaultBuiltin(name)) {
+		return false
+	}
+	if isDefaultBuiltin(name) {
+		s.builtins[name] = nil // hides the default builtin function
+	} else {
+		delete(s.builtins, name)
+	}
+	return true
+}
+
 // SetImports sets import modules.
 func (s *Script) SetImports(modules *ModuleMap) {
 	s.modules = modules
 }
 
+// SetPolicy sets the policy that restricts the capabilities of the script.
+// The script will fail to compile if it violates the policy.
+func (s *Script) SetPolicy(policy *Policy) {
+	s.policy = policy
+}
+
 // SetMaxAllocs sets the maximum number of objects allocations during the run
 // time. Compiled script will return ErrObjectAllocLimit error if it
 // exceeds this limit.
@@ -75,6 +111,14 @@ func (s *Script) EnableFileImport(enable bool) {
 	s.enableFileImport = enable
 }
 
+// EnableOverflowCheck enables or disables overflow checking of integer
+// arithmetic. If enabled, int operations whose result does not fit in int64
+// fail with ErrIntegerOverflow instead of wrapping around. It is disabled by
+// default.
+func (s *Script) EnableOverflowCheck(enable bool) {
+	s.overflowCheck = enable
+}
+
 // Compile compiles the script with all the defined variables, and, returns
 // Compiled object.
 func (s *Script) Compile() (*Compiled, error) {
@@ -93,6 +137,8 @@ func (s *Script) Compile() (*Compiled, error) {
 
 	c := NewCompiler(srcFile, symbolTable, nil, s.modules, nil)
 	c.EnableFileImport(s.enableFileImport)
+	c.SetPolicy(s.policy)
+	s.prepBuiltins(c)
 	if err := c.Compile(file); err != nil {
 		return nil, err
 	}
@@ -125,6 +171,8 @@ func (s *Script) Compile() (*Compiled, error) {
 		bytecode:      bytecode,
 		globals:       globals,
 		maxAllocs:     s.maxAllocs,
+		overflowCheck: s.overflowCheck,
+		pool:          newVMPool(bytecode, s.maxAllocs, s.overflowCheck),
 	}, nil
 }
 
@@ -179,6 +227,22 @@ func (s *Script) prepCompile() (
 	return
 }
 
+func (s *Script) prepBuiltins(c *Compiler) {
+	var names []string
+	for name := range s.builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if fn := s.builtins[name]; fn != nil {
+			c.SetBuiltin(fn)
+		} else {
+			c.RemoveBuiltin(name)
+		}
+	}
+}
+
 // Compiled is a compiled instance of the user script. Use Script.Compile() to
 // create Compiled object.
 type Compiled struct {
@@ -186,6 +250,8 @@ type Compiled struct {
 	bytecode      *Bytecode
 	globals       []Object
 	maxAllocs     int64
+	overflowCheck bool
+	pool          *vmPool // shared by the clones
 	lock          sync.RWMutex
 }
 
@@ -194,27 +260,93 @@ func (c *Compiled) Run() error {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
-	v := NewVM(c.bytecode, c.globals, c.maxAllocs)
+	v := c.pool.get(c.globals)
+	defer c.pool.put(v)
 	return v.Run()
 }
 
-// RunContext is like Run but includes a context.
-func (c *Compiled) RunContext(ctx context.Context) (err error) {
+// RunContext is like Run but includes a context. The script runs on the
+// caller's goroutine, and, it is stopped when the context is done.
+func (c *Compiled) RunContext(ctx context.Context) error {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
-	v := NewVM(c.bytecode, c.globals, c.maxAllocs)
-	ch := make(chan error, 1)
-	go func() {
-		ch <- v.Run()
-	}()
+	v := c.pool.get(c.globals)
+	defer c.pool.put(v)
+	return v.RunContext(ctx)
+}
 
-	select {
-	case <-ctx.Done():
-		v.Abort()
-		<-ch
-		err = ctx.Err()
-	case err = <-ch:
+// Call calls the function of the global variable name with the arguments,
+// and, returns the result. The arguments are converted using FromInterface
+// and the result using ToInterface. The script must be run before so the
+// global variable has the function value, e.g. a function, a bound method
+// of a record or a builtin function. Like Run, the function runs with
+// the global variables of the Compiled; use Clone for concurrent calls.
+func (c *Compiled) Call(
+	name string,
+	args ...interface{},
+) (interface{}, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	fn, objArgs, err := c.prepCall(name, args)
+	if err != nil {
+		return nil, err
+	}
+	v := c.pool.get(c.globals)
+	defer c.pool.put(v)
+	res, err := v.runFunc(fn, objArgs...)
+	if err != nil {
+		return nil, err
+	}
+	return ToInterface(res), nil
+}
+
+// CallContext is like Call but includes a context.
+func (c *Compiled) CallContext(
+	ctx context.Context,
+	name string,
+	args ...interface{},
+) (interface{}, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	fn, objArgs, err := c.prepCall(name, args)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	v := c.pool.get(c.globals)
+	defer c.pool.put(v)
+	v.setContext(ctx)
+	res, err := v.runFunc(fn, objArgs...)
+	if err != nil {
+		return nil, v.contextError(err)
+	}
+	return ToInterface(res), nil
+}
+
+func (c *Compiled) prepCall(
+	name string,
+	args []interface{},
+) (fn Object, objArgs []Object, err error) {
+	idx, ok := c.globalIndexes[name]
+	if !ok {
+		err = fmt.Errorf("'%s' is not defined", name)
+		return
+	}
+	fn = c.globals[idx]
+	if fn == nil || !fn.CanCall() {
+		err = fmt.Errorf("'%s' is not callable", name)
+		return
+	}
+	objArgs = make([]Object, len(args))
+	for i, arg := range args {
+		if objArgs[i], err = FromInterface(arg); err != nil {
+			return
+		}
 	}
 	return
 }
@@ -230,6 +362,8 @@ func (c *Compiled) Clone() *Compiled {
 		bytecode:      c.bytecode,
 		globals:       make([]Object, len(c.globals)),
 		maxAllocs:     c.maxAllocs,
+		overflowCheck: c.overflowCheck,
+		pool:          c.pool,
 	}
 	// copy global objects
 	for idx, g := range c.globals {
@@ -246,15 +380,7 @@ func (c *Compiled) IsDefined(name string) bool {
 	c.lock.RLock()
 	defer c.lock.RUnlock()
 
-	idx, ok := c.globalIndexes[name]
-	if !ok {
-		return false
-	}
-	v := c.globals[idx]
-	if v == nil {
-		return false
-	}
-	return v != UndefinedValue
+	return globalValue(c.globalIndexes, c.globals, name) != UndefinedValue
 }
 
 // Get returns a variable identified by the name.
@@ -262,16 +388,9 @@ func (c *Compiled) Get(name string) *Variable {
 	c.lock.RLock()
 	defer c.lock.RUnlock()
 
-	value := UndefinedValue
-	if idx, ok := c.globalIndexes[name]; ok {
-		value = c.globals[idx]
-		if value == nil {
-			value = UndefinedValue
-		}
-	}
 	return &Variable{
 		name:  name,
-		value: value,
+		value: globalValue(c.globalIndexes, c.globals, name),
 	}
 }
 
@@ -300,14 +419,245 @@ func (c *Compiled) Set(name string, value interface{}) error {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
+	return setGlobal(c.globalIndexes, c.globals, name, value)
+}
+
+// globalValue returns the value of the global variable name, or, undefined
+// if the variable is not defined.
+func globalValue(
+	globalIndexes map[string]int,
+	globals []Object,
+	name string,
+) Object {
+	if idx, ok := globalIndexes[name]; ok {
+		if value := globals[idx]; value != nil {
+			return value
+		}
+	}
+	return UndefinedValue
+}
+
+func setGlobal(
+	globalIndexes map[string]int,
+	globals []Object,
+	name string,
+	value interface{},
+) error {
 	obj, err := FromInterface(value)
 	if err != nil {
 		return err
 	}
-	idx, ok := c.globalIndexes[name]
+	idx, ok := globalIndexes[name]
 	if !ok {
 		return fmt.Errorf("'%s' is not defined", name)
 	}
-	c.globals[idx] = obj
+	globals[idx] = obj
 	return nil
 }
+
+// Program is a compiled script that can be run many times, concurrently,
+// with different inputs. The compiled bytecode is shared by the runs, and,
+// each run has its own Env of the global variables. Use
+// Script.CompileProgram to create a Program.
+type Program struct {
+	globalIndexes map[string]int // global symbol name to index
+	globals       []Object       // initial values of the global variables
+	mutable       []int          // indexes of the values copied for each run
+	pool          *vmPool
+}
+
+// CompileProgram compiles the script like Compile, and, returns a Program.
+// The variables added to the script are the inputs of the program: their
+// values are the default values for each run.
+func (s *Script) CompileProgram() (*Program, error) {
+	c, err := s.Compile()
+	if err != nil {
+		return nil, err
+	}
+	var mutable []int
+	for i, v := range c.globals {
+		if hasMutable(v) {
+			mutable = append(mutable, i)
+		}
+	}
+	return &Program{
+		globalIndexes: c.globalIndexes,
+		globals:       c.globals,
+		mutable:       mutable,
+		pool:          c.pool,
+	}, nil
+}
+
+// NewEnv creates an Env for a run of the program. Its global variables are
+// initialized with the values of the script variables. Arrays, maps, sets
+// and records, including the ones in immutable arrays and maps, are copied
+// for each Env so that the runs do not modify the default values. Other
+// values, e.g. the values of the user types, are shared by the runs.
+func (p *Program) NewEnv() *Env {
+	globals := make([]Object, len(p.globals))
+	copy(globals, p.globals)
+	for _, idx := range p.mutable {
+		globals[idx] = copyMutable(globals[idx])
+	}
+	return &Env{program: p, globals: globals}
+}
+
+// hasMutable returns true if the value is, or, holds an array, a map, a set
+// or a record that a run of a program can modify.
+func hasMutable(o Object) bool {
+	switch o := o.(type) {
+	case *Array, *Map, *Set, *Record:
+		return true
+	case *ImmutableArray:
+		for _, v := range o.Value {
+			if hasMutable(v) {
+				return true
+			}
+		}
+	case *ImmutableMap:
+		for _, v := range o.Value {
+			if hasMutable(v) {
+				return true
+			}
+		}
+		for _, e := range o.Hashed {
+			if hasMutable(e.Value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// copyMutable copies a value for a run of a program. Unlike Copy, immutable
+// arrays and maps are copied as immutable values, so only the mutable values
+// they hold are copied.
+func copyMutable(o Object) Object {
+	switch o := o.(type) {
+	case *Array:
+		return &Array{Value: copyMutableValues(o.Value)}
+	case *ImmutableArray:
+		if !hasMutable(o) {
+			return o
+		}
+		return &ImmutableArray{Value: copyMutableValues(o.Value)}
+	case *Map:
+		v, h := copyMutableMap(o.Value, o.Hashed)
+		return &Map{Value: v, Hashed: h, order: append([]mapKey{}, o.keys()...)}
+	case *ImmutableMap:
+		if !hasMutable(o) {
+			return o
+		}
+		v, h := copyMutableMap(o.Value, o.Hashed)
+		return &ImmutableMap{Value: v, Hashed: h,
+			order: append([]mapKey{}, o.keys()...)}
+	case *Record:
+		return &Record{Type: o.Type, Values: copyMutableValues(o.Values)}
+	}
+	return o.Copy()
+}
+
+func copyMutableValues(values []Object) []Object {
+	c := make([]Object, len(values))
+	for i, v := range values {
+		c[i] = copyMutable(v)
+	}
+	return c
+}
+
+func copyMutableMap(
+	v map[string]Object,
+	h map[string]MapEntry,
+) (map[string]Object, map[string]MapEntry) {
+	cv := make(map[string]Object, len(v))
+	for k, v := range v {
+		cv[k] = copyMutable(v)
+	}
+	var ch map[string]MapEntry
+	if len(h) > 0 {
+		ch = make(map[string]MapEntry, len(h))
+		for k, e := range h {
+			ch[k] = MapEntry{Key: e.Key, Value: copyMutable(e.Value)}
+		}
+	}
+	return cv, ch
+}
+
+// Run runs the program in a new Env with the input variables, and, returns
+// the Env to read the results from. It is safe to call Run concurrently.
+func (p *Program) Run(inputs map[string]interface{}) (*Env, error) {
+	env, err := p.inputEnv(inputs)
+	if err != nil {
+		return nil, err
+	}
+	if err := env.Run(); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+// RunContext is like Run but includes a context.
+func (p *Program) RunContext(
+	ctx context.Context,
+	inputs map[string]interface{},
+) (*Env, error) {
+	env, err := p.inputEnv(inputs)
+	if err != nil {
+		return nil, err
+	}
+	if err := env.RunContext(ctx); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+func (p *Program) inputEnv(inputs map[string]interface{}) (*Env, error) {
+	env := p.NewEnv()
+	for name, value := range inputs {
+		if err := env.Set(name, value); err != nil {
+			return nil, err
+		}
+	}
+	return env, nil
+}
+
+// Env is the global variables of a run of a Program. Unlike Compiled, Env is
+// not safe for concurrent use: use one Env for each goroutine.
+type Env struct {
+	program *Program
+	globals []Object
+}
+
+// Run executes the program with the global variables of the Env.
+func (e *Env) Run() error {
+	v := e.program.pool.get(e.globals)
+	defer e.program.pool.put(v)
+	return v.Run()
+}
+
+// RunContext is like Run but includes a context.
+func (e *Env) RunContext(ctx context.Context) error {
+	v := e.program.pool.get(e.globals)
+	defer e.program.pool.put(v)
+	return v.RunContext(ctx)
+}
+
+// Get returns a variable identified by the name.
+func (e *Env) Get(name string) *Variable {
+	return &Variable{
+		name:  name,
+		value: globalValue(e.program.globalIndexes, e.globals, name),
+	}
+}
+
+// IsDefined returns true if the variable name is defined (has value).
+func (e *Env) IsDefined(name string) bool {
+	return globalValue(e.program.globalIndexes, e.globals, name) !=
+		UndefinedValue
+}
+
+// Set replaces the value of a global variable identified by the name. An
+// error will be returned if the name was not defined during compilation.
+func (e *Env) Set(name string, value interface{}) error {
+	return setGlobal(e.program.globalIndexes, e.globals, name, value)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"math/rand"
 	"strings"
 	"sync"
@@ -11,6 +12,7 @@ import (
 	"time"
 
 	"github.com/d5/tengo/v2"
+	"github.com/d5/tengo/v2/parser"
 	"github.com/d5/tengo/v2/require"
 	"github.com/d5/tengo/v2/stdlib"
 	"github.com/d5/tengo/v2/token"
@@ -49,6 +51,226 @@ func TestScript_Remove(t *testing.T) {
 	require.Error(t, err)
 }
 
+func TestScript_SetBuiltin(t *testing.T) {
+	double := func(args ...tengo.Object) (tengo.Object, error) {
+		if len(args) != 1 {
+			return nil, tengo.ErrWrongNumArguments
+		}
+		i, ok := tengo.ToInt64(args[0])
+		if !ok {
+			return nil, tengo.ErrInvalidArgumentType{
+				Name:     "first",
+				Expected: "int",
+				Found:    args[0].TypeName(),
+			}
+		}
+		return &tengo.Int{Value: i * 2}, nil
+	}
+
+	// new builtin function
+	s := tengo.NewScript([]byte(`a := double(4); b := len([1, 2])`))
+	s.SetBuiltin("double", double)
+	c, err := s.Run()
+	require.NoError(t, err)
+	compiledGet(t, c, "a", int64(8))
+	compiledGet(t, c, "b", int64(2))
+	require.False(t, c.IsDefined("double")) // not a global variable
+
+	// replaced builtin function
+	s = tengo.NewScript([]byte(`a := len(4); b := [x for x in [1, 2]][1]`))
+	s.SetBuiltin("len", double)
+	c, err = s.Run()
+	require.NoError(t, err)
+	compiledGet(t, c, "a", int64(8))
+	compiledGet(t, c, "b", int64(2))
+
+	// builtin functions can be shadowed by the script
+	s = tengo.NewScript([]byte(`
+f := func() {
+	double := func(x) { return x }
+	return double(4)
+}
+a := f()`))
+	s.SetBuiltin("double", double)
+	c, err = s.Run()
+	require.NoError(t, err)
+	compiledGet(t, c, "a", int64(4))
+
+	// builtin functions are visible in source modules
+	s = tengo.NewScript([]byte(`a := import("mod")`))
+	s.SetBuiltin("double", double)
+	mods := tengo.NewModuleMap()
+	mods.AddSourceModule("mod", []byte(`export double(5)`))
+	s.SetImports(mods)
+	c, err = s.Run()
+	require.NoError(t, err)
+	compiledGet(t, c, "a", int64(10))
+
+	// runtime error
+	s = tengo.NewScript([]byte(`a := double("foo")`))
+	s.SetBuiltin("double", double)
+	_, err = s.Run()
+	require.Error(t, err)
+	require.True(t, strings.Contains(err.Error(),
+		"invalid type for argument 'first' in call to 'builtin-function:double'"),
+		err.Error())
+}
+
+func TestScript_SetBuiltin_Max(t *testing.T) {
+	s := tengo.NewScript([]byte(`a := f255()`))
+	for i := len(tengo.GetAllBuiltinFunctions()); i < tengo.MaxBuiltins; i++ {
+		v := &tengo.Int{Value: int64(i)}
+		s.SetBuiltin(fmt.Sprintf("f%d", i),
+			func(args ...tengo.Object) (tengo.Object, error) {
+				return v, nil
+			})
+	}
+	c, err := s.Run()
+	require.NoError(t, err)
+	compiledGet(t, c, "a", int64(255))
+
+	s.SetBuiltin("f256", func(args ...tengo.Object) (tengo.Object, error) {
+		return tengo.UndefinedValue, nil
+	})
+	_, err = s.Compile()
+	require.Error(t, err)
+	require.True(t, strings.Contains(err.Error(),
+		"too many builtin functions"), err.Error())
+}
+
+func TestScript_RemoveBuiltin(t *testing.T) {
+	s := tengo.NewScript([]byte(`a := len([1, 2])`))
+	require.True(t, s.RemoveBuiltin("len"))
+	require.False(t, s.RemoveBuiltin("len"))
+	require.False(t, s.RemoveBuiltin("foo"))
+	_, err := s.Compile()
+	require.Error(t, err)
+	require.True(t, strings.Contains(err.Error(), "unresolved reference 'len'"),
+		err.Error())
+
+	// removed builtin functions are not visible in source modules
+	s = tengo.NewScript([]byte(`a := import("mod")`))
+	require.True(t, s.RemoveBuiltin("len"))
+	mods := tengo.NewModuleMap()
+	mods.AddSourceModule("mod", []byte(`export len([1])`))
+	s.SetImports(mods)
+	_, err = s.Compile()
+	require.Error(t, err)
+
+	// comprehensions are compiled to OpAppend, not to calls of 'append'
+	s = tengo.NewScript([]byte(`a := [x * 2 for x in [1, 2]][1]`))
+	require.True(t, s.RemoveBuiltin("append"))
+	c, err := s.Run()
+	require.NoError(t, err)
+	compiledGet(t, c, "a", int64(4))
+
+	// custom builtin function
+	s = tengo.NewScript([]byte(`a := foo()`))
+	s.SetBuiltin("foo", func(args ...tengo.Object) (tengo.Object, error) {
+		return tengo.TrueValue, nil
+	})
+	require.True(t, s.RemoveBuiltin("foo"))
+	require.False(t, s.RemoveBuiltin("foo"))
+	_, err = s.Compile()
+	require.Error(t, err)
+
+	// set after remove
+	s = tengo.NewScript([]byte(`a := len([1, 2])`))
+	require.True(t, s.RemoveBuiltin("len"))
+	s.SetBuiltin("len", func(args ...tengo.Object) (tengo.Object, error) {
+		return &tengo.Int{Value: 42}, nil
+	})
+	c, err = s.Run()
+	require.NoError(t, err)
+	compiledGet(t, c, "a", int64(42))
+}
+
+func TestScript_SetPolicy(t *testing.T) {
+	expectPolicyError := func(src string, policy *tengo.Policy, expected string) {
+		s := tengo.NewScript([]byte(src))
+		mods := stdlib.GetModuleMap("math", "enum")
+		mods.AddSourceModule("mod", []byte(`export func() { for {} }`))
+		s.SetImports(mods)
+		s.SetPolicy(policy)
+		_, err := s.Compile()
+		require.Error(t, err)
+		require.Equal(t, expected, err.Error())
+	}
+	expectPolicyOK := func(src string, policy *tengo.Policy) {
+		s := tengo.NewScript([]byte(src))
+		s.SetImports(stdlib.GetModuleMap("math", "enum"))
+		s.SetPolicy(policy)
+		_, err := s.Compile()
+		require.NoError(t, err)
+	}
+
+	// builtin functions
+	builtins := &tengo.Policy{AllowedBuiltins: []string{"len"}}
+	expectPolicyOK(`a := len([1])`, builtins)
+	// comprehensions are a language construct, not calls of 'append'
+	expectPolicyOK(`a := [x for x in [1, 2]]`, builtins)
+	expectPolicyError(`a := append([], 1)`, builtins,
+		"Compile Error: builtin function 'append' not allowed\n\tat (main):1:6")
+	expectPolicyError(`a := len([1])
+b := format("%d", a)`, builtins,
+		"Compile Error: builtin function 'format' not allowed\n\tat (main):2:6")
+	expectPolicyError(`a := len`, &tengo.Policy{AllowedBuiltins: []string{}},
+		"Compile Error: builtin function 'len' not allowed\n\tat (main):1:6")
+	expectPolicyOK(`a := format("%d", 1)`, nil)
+
+	// modules
+	modules := &tengo.Policy{AllowedModules: []string{"math"}}
+	expectPolicyOK(`math := import("math")`, modules)
+	expectPolicyError(`math := import("math"); enum := import("enum")`,
+		modules,
+		"Compile Error: module 'enum' not allowed\n\tat (main):1:33")
+
+	// policy applies to the imported modules
+	expectPolicyError(`enum := import("enum")`,
+		&tengo.Policy{AllowedBuiltins: []string{"len"}},
+		"Compile Error: builtin function 'is_array' not allowed\n\tat enum:2:10")
+	expectPolicyError(`mod := import("mod")`,
+		&tengo.Policy{DisallowInfiniteLoops: true},
+		"Compile Error: for loop without condition not allowed\n\tat mod:1:17")
+
+	// loops
+	loops := &tengo.Policy{DisallowInfiniteLoops: true}
+	expectPolicyOK(`for i := 0; i < 10; i++ {}; for x in [1, 2] {}`, loops)
+	expectPolicyError(`f := func() {
+	for {
+		break
+	}
+}`, loops,
+		"Compile Error: for loop without condition not allowed\n\tat (main):2:2")
+	expectPolicyError(`outer: for i := 0; ; i++ {}`, loops,
+		"Compile Error: for loop without condition not allowed\n\tat (main):1:8")
+	for _, cond := range []string{"true", "(true)", "!false", "1", `"a"`} {
+		expectPolicyError(`for `+cond+` {}`, loops,
+			"Compile Error: for loop with constant condition not allowed\n\tat (main):1:1")
+	}
+	expectPolicyOK(`a := true; for a { a = false }; for false {}; for 0 {}`,
+		loops)
+
+	// closures
+	closures := &tengo.Policy{DisallowClosures: true}
+	expectPolicyOK(`a := 1; f := func(x) { return x + a }`, closures)
+	expectPolicyError(`f := func(x) {
+	return func(y) { return x + y }
+}`, closures,
+		"Compile Error: closure not allowed\n\tat (main):2:9")
+
+	// file imports
+	s := tengo.NewScript([]byte(`a := import("./stdlib/srcmod_enum")`))
+	s.EnableFileImport(true)
+	_, err := s.Compile()
+	require.NoError(t, err)
+	s.SetPolicy(&tengo.Policy{DisallowFileImport: true})
+	_, err = s.Compile()
+	require.Error(t, err)
+	require.Equal(t, "Compile Error: file import is not allowed by the "+
+		"policy\n\tat (main):1:6", err.Error())
+}
+
 func TestScript_Run(t *testing.T) {
 	s := tengo.NewScript([]byte(`a := b`))
 	err := s.Add("b", 5)
@@ -136,6 +358,66 @@ func TestScript_SetMaxConstObjects(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestScript_EnableOverflowCheck(t *testing.T) {
+	for _, tc := range []struct {
+		src      string
+		expected int64
+	}{
+		{`a := b + 1`, math.MinInt64},
+		{`a := -b - 2`, math.MaxInt64},
+		{`a := b * 2`, -2},
+		{`a := -b; a--; a = -a`, math.MinInt64},
+		{`a := (-b - 1) / -1`, math.MinInt64},
+		{`a := b << 1`, -2},
+		{`a := 3 ** 40`, -6289078614652622815},
+	} {
+		s := tengo.NewScript([]byte(tc.src))
+		require.NoError(t, s.Add("b", math.MaxInt64))
+		c, err := s.Run()
+		require.NoError(t, err)
+		compiledGet(t, c, "a", tc.expected)
+
+		s.EnableOverflowCheck(true)
+		_, err = s.Run()
+		require.Error(t, err)
+		require.True(t, strings.Contains(err.Error(), "integer overflow"),
+			"unexpected error: %s", err.Error())
+	}
+
+	s := tengo.NewScript([]byte(`
+a := b + 1 - 2 * 3
+c := -(b - 1) - 1
+d := [2 ** 62, -2 ** 63, 1 << 62, b / -1, 7 % -2, 2 ** -1, 1.5 + b]
+b++`))
+	require.NoError(t, s.Add("b", math.MaxInt64-1))
+	s.EnableOverflowCheck(true)
+	c, err := s.Run()
+	require.NoError(t, err)
+	compiledGet(t, c, "a", int64(math.MaxInt64-6))
+	compiledGet(t, c, "b", int64(math.MaxInt64))
+	compiledGet(t, c, "c", int64(math.MinInt64+2))
+	d := c.Get("d").Array()
+	require.Equal(t, 7, len(d))
+	for i, v := range []interface{}{int64(1) << 62, int64(math.MinInt64),
+		int64(1) << 62, int64(-math.MaxInt64 + 1), int64(1), 0.5,
+		1.5 + float64(math.MaxInt64-1)} {
+		require.Equal(t, v, d[i])
+	}
+
+	// clones keep the setting
+	err = c.Clone().RunContext(context.Background())
+	require.Error(t, err)
+	require.True(t, strings.Contains(err.Error(), "integer overflow"))
+
+	// division by zero is always an error
+	s = tengo.NewScript([]byte(`a := 1 / b`))
+	require.NoError(t, s.Add("b", 0))
+	s.EnableOverflowCheck(true)
+	_, err = s.Run()
+	require.Error(t, err)
+	require.True(t, strings.Contains(err.Error(), "division by zero"))
+}
+
 func TestScriptConcurrency(t *testing.T) {
 	solve := func(a, b, c int) (d, e int) {
 		a += 2
@@ -381,6 +663,154 @@ func BenchmarkArrayIndexCompare(b *testing.B) {
     `)
 }
 
+const benchProgramInput = `out := a * 2 + len(name)`
+
+// BenchmarkProgram_RunParallel runs a short script concurrently with a
+// Program and a new Env for each run.
+func BenchmarkProgram_RunParallel(b *testing.B) {
+	s := tengo.NewScript([]byte(benchProgramInput))
+	_ = s.Add("a", 0)
+	_ = s.Add("name", "")
+	p, err := s.CompileProgram()
+	if err != nil {
+		panic(err)
+	}
+
+	b.ReportAllocs()
+	b.SetParallelism(64)
+	b.RunParallel(func(pb *testing.PB) {
+		inputs := map[string]interface{}{"a": 5, "name": "foo"}
+		for pb.Next() {
+			env, err := p.Run(inputs)
+			if err != nil {
+				panic(err)
+			}
+			if env.Get("out").Int() != 13 {
+				panic("wrong result")
+			}
+		}
+	})
+}
+
+// BenchmarkCompiled_CloneRunParallel runs the same script as
+// BenchmarkProgram_RunParallel with a clone of Compiled for each run.
+func BenchmarkCompiled_CloneRunParallel(b *testing.B) {
+	s := tengo.NewScript([]byte(benchProgramInput))
+	_ = s.Add("a", 0)
+	_ = s.Add("name", "")
+	c, err := s.Compile()
+	if err != nil {
+		panic(err)
+	}
+
+	b.ReportAllocs()
+	b.SetParallelism(64)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			clone := c.Clone()
+			_ = clone.Set("a", 5)
+			_ = clone.Set("name", "foo")
+			if err := clone.Run(); err != nil {
+				panic(err)
+			}
+			if clone.Get("out").Int() != 13 {
+				panic("wrong result")
+			}
+		}
+	})
+}
+
+// BenchmarkVM_New runs the same script as BenchmarkProgram_RunParallel with
+// a new VM for each run, to compare with the VMs reused by Program.
+func BenchmarkVM_New(b *testing.B) {
+	fileSet := parser.NewFileSet()
+	input := []byte(benchProgramInput)
+	srcFile := fileSet.AddFile("bench", -1, len(input))
+	file, err := parser.NewParser(srcFile, input, nil).ParseFile()
+	if err != nil {
+		b.Fatal(err)
+	}
+	symTable := tengo.NewSymbolTable()
+	a := symTable.Define("a")
+	name := symTable.Define("name")
+	c := tengo.NewCompiler(srcFile, symTable, nil, nil, nil)
+	if err := c.Compile(file); err != nil {
+		b.Fatal(err)
+	}
+	bytecode := c.Bytecode()
+
+	b.ReportAllocs()
+	b.SetParallelism(64)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			globals := make([]tengo.Object, tengo.GlobalsSize)
+			globals[a.Index] = &tengo.Int{Value: 5}
+			globals[name.Index] = &tengo.String{Value: "foo"}
+			if err := tengo.NewVM(bytecode, globals, -1).Run(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+const benchRunContextInput = `
+s := 0
+for i := 0; i < 100; i++ { s += i }`
+
+func BenchmarkCompiled_Run(b *testing.B) {
+	c, err := tengo.NewScript([]byte(benchRunContextInput)).Compile()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := c.Run(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCompiled_RunContext(b *testing.B) {
+	c, err := tengo.NewScript([]byte(benchRunContextInput)).Compile()
+	if err != nil {
+		b.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := c.RunContext(ctx); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCompiled_RunContextCancel(b *testing.B) {
+	c, err := tengo.NewScript([]byte(`for true {}`)).Compile()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	var latency time.Duration
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		var canceled time.Time
+		go func() {
+			canceled = time.Now()
+			cancel()
+		}()
+		if err := c.RunContext(ctx); err != context.Canceled {
+			b.Fatal(err)
+		}
+		latency += time.Since(canceled)
+	}
+	b.ReportMetric(float64(latency.Nanoseconds())/float64(b.N),
+		"ns-latency/op")
+}
+
 func bench(n int, input string) {
 	s := tengo.NewScript([]byte(input))
 	c, err := s.Compile()
@@ -397,6 +827,147 @@ func bench(n int, input string) {
 
 type M map[string]interface{}
 
+func TestProgram(t *testing.T) {
+	s := tengo.NewScript([]byte(`
+out := greeting + ", " + name
+count++
+`))
+	require.NoError(t, s.Add("greeting", "hello"))
+	require.NoError(t, s.Add("name", ""))
+	require.NoError(t, s.Add("count", 0))
+	p, err := s.CompileProgram()
+	require.NoError(t, err)
+
+	env, err := p.Run(M{"name": "alice"})
+	require.NoError(t, err)
+	require.Equal(t, "hello, alice", env.Get("out").String())
+	require.Equal(t, int64(1), env.Get("count").Int64())
+	require.True(t, env.IsDefined("out"))
+	require.False(t, env.IsDefined("foo"))
+
+	// runs do not share the global variables
+	env, err = p.Run(M{"greeting": "hi", "name": "bob"})
+	require.NoError(t, err)
+	require.Equal(t, "hi, bob", env.Get("out").String())
+	require.Equal(t, int64(1), env.Get("count").Int64())
+
+	// env can be run again
+	require.NoError(t, env.Set("name", "carol"))
+	require.NoError(t, env.Run())
+	require.Equal(t, "hi, carol", env.Get("out").String())
+	require.Equal(t, int64(2), env.Get("count").Int64())
+
+	_, err = p.Run(M{"foo": 1})
+	require.Error(t, err)
+	require.Equal(t, "'foo' is not defined", err.Error())
+	_, err = p.Run(M{"count": true})
+	require.Error(t, err) // runtime error: bool++
+
+	// context
+	env, err = p.RunContext(context.Background(), M{"name": "dave"})
+	require.NoError(t, err)
+	require.Equal(t, "hello, dave", env.Get("out").String())
+	s = tengo.NewScript([]byte(`for true {}`))
+	p, err = s.CompileProgram()
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(),
+		1*time.Millisecond)
+	defer cancel()
+	_, err = p.RunContext(ctx, nil)
+	require.Equal(t, context.DeadlineExceeded, err)
+
+	// concurrent runs
+	s = tengo.NewScript([]byte(`out := a * 2`))
+	require.NoError(t, s.Add("a", 0))
+	p, err = s.CompileProgram()
+	require.NoError(t, err)
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			env, err := p.Run(M{"a": i})
+			require.NoError(t, err)
+			require.Equal(t, int64(i*2), env.Get("out").Int64())
+		}(i)
+	}
+	wg.Wait()
+
+	// default values are not modified by the runs
+	s = tengo.NewScript([]byte(`m.n += 1; out := m.n`))
+	require.NoError(t, s.Add("m", M{"n": 0}))
+	p, err = s.CompileProgram()
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			env, err := p.Run(nil)
+			require.NoError(t, err)
+			require.Equal(t, int64(1), env.Get("out").Int64())
+		}()
+	}
+	wg.Wait()
+	env = p.NewEnv()
+	require.NoError(t, env.Run())
+	require.NoError(t, env.Run())
+	require.Equal(t, int64(2), env.Get("out").Int64())
+
+	// values in immutable containers are copied as well
+	s = tengo.NewScript([]byte(`
+cfg.list[0] = cfg.list[0] + 1
+out := cfg.list[0]
+ok := is_immutable_map(cfg) && is_immutable_array(cfg.arr)`))
+	require.NoError(t, s.Add("cfg", &tengo.ImmutableMap{
+		Value: map[string]tengo.Object{
+			"list": &tengo.Array{Value: []tengo.Object{&tengo.Int{}}},
+			"arr": &tengo.ImmutableArray{Value: []tengo.Object{
+				&tengo.Map{Value: map[string]tengo.Object{}},
+			}},
+		},
+	}))
+	p, err = s.CompileProgram()
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			env, err := p.Run(nil)
+			require.NoError(t, err)
+			require.Equal(t, int64(1), env.Get("out").Int64())
+			require.True(t, env.Get("ok").Bool())
+		}()
+	}
+	wg.Wait()
+
+	// a map shared by concurrent runs is only read
+	m := &tengo.Map{Value: map[string]tengo.Object{
+		"b": &tengo.Int{Value: 2},
+		"a": &tengo.Int{Value: 1},
+	}}
+	s = tengo.NewScript([]byte(`
+m := shared.m
+out := ""
+for k, v in m { out += k + string(v) }
+out += string(m) + string(m == {a: 1, b: 2})`))
+	require.NoError(t, s.Add("shared", &tengo.ImmutableMap{
+		Value: map[string]tengo.Object{"m": m},
+	}))
+	p, err = s.CompileProgram()
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			env, err := p.Run(nil)
+			require.NoError(t, err)
+			require.Equal(t, `a1b2{a: 1, b: 2}true`,
+				env.Get("out").String())
+		}()
+	}
+	wg.Wait()
+}
+
 func TestCompiled_Get(t *testing.T) {
 	// simple script
 	c := compile(t, `a := 5`, nil)
@@ -477,6 +1048,325 @@ func TestCompiled_RunContext(t *testing.T) {
 	defer cancel()
 	err = c.RunContext(ctx)
 	require.Equal(t, context.DeadlineExceeded, err)
+
+	// cancellation latency
+	runCanceled := func(input string) {
+		c := compile(t, input, nil)
+		ctx, cancel := context.WithCancel(context.Background())
+		var canceled time.Time
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			canceled = time.Now()
+			cancel()
+		}()
+		err := c.RunContext(ctx)
+		latency := time.Since(canceled)
+		require.Equal(t, context.Canceled, err)
+		require.True(t, latency < 100*time.Millisecond, latency.String())
+	}
+	runCanceled(`for true {}`)
+	runCanceled(`for i := 0; true; i++ { a := [i, i + 1] }`)
+	runCanceled(`f := func(n) { return f(n + 1) }; f(0)`) // tail call
+	runCanceled(`sort_by([1, 2], func(a, b) { for true {} })`)
+
+	// canceled before the run
+	c = compile(t, `a := 5`, nil)
+	cctx, ccancel := context.WithCancel(context.Background())
+	ccancel()
+	require.Equal(t, context.Canceled, c.RunContext(cctx))
+	compiledGet(t, c, "a", nil)
+
+	// runtime errors are not affected
+	c = compile(t, `for i := 0; i < 1000; i++ {}; a := 1 / 0`, nil)
+	err = c.RunContext(context.Background())
+	require.Error(t, err)
+	require.True(t, strings.Contains(err.Error(), "division by zero"))
+
+	// function call
+	c = compile(t, `f := func() { for true {} }`, nil)
+	compiledRun(t, c)
+	ctx, cancel = context.WithTimeout(context.Background(),
+		1*time.Millisecond)
+	defer cancel()
+	_, err = c.CallContext(ctx, "f")
+	require.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestCompiled_RunReuse(t *testing.T) {
+	// the VMs are reused by the runs and the clones
+	c := compile(t, `
+f := func(n) { return n == 0 ? 0 : 1 + f(n-1) }
+out := f(a) + 10 / b`, M{"a": 100, "b": 0})
+	err := c.Run()
+	require.Error(t, err)
+	require.NoError(t, c.Set("b", 2))
+	compiledRun(t, c)
+	compiledGet(t, c, "out", int64(105))
+	require.NoError(t, c.Set("a", 500))
+	compiledRun(t, c)
+	compiledGet(t, c, "out", int64(505))
+	require.NoError(t, c.Set("a", 100))
+	compiledRun(t, c)
+	compiledGet(t, c, "out", int64(105))
+
+	clone := c.Clone()
+	require.NoError(t, clone.Set("a", 10))
+	compiledRun(t, clone)
+	compiledGet(t, clone, "out", int64(15))
+	compiledGet(t, c, "out", int64(105))
+
+	// aborted run
+	c = compile(t, `
+out := 0
+for a { out++ }`, M{"a": true})
+	ctx, cancel := context.WithTimeout(context.Background(),
+		1*time.Millisecond)
+	defer cancel()
+	err = c.RunContext(ctx)
+	require.Equal(t, context.DeadlineExceeded, err)
+	require.NoError(t, c.Set("a", false))
+	compiledRun(t, c)
+	compiledGet(t, c, "out", int64(0))
+}
+
+func TestCompiled_Call(t *testing.T) {
+	script := tengo.NewScript([]byte(`
+count := 0
+add := func(a, b) {
+	count++
+	return a + b
+}
+sum := func(...args) {
+	s := 0
+	for a in args { s += a }
+	return s
+}
+fail := func(x) {
+	return x.foo
+}
+nested := func(x) {
+	return fail(x)
+}
+prefix := "x"
+greet := func(name) { return prefix + name }
+loop := func() { for true {} }
+upper := import("text").to_upper
+P := record { x: 5, len2: func(self) { return self.x * self.x }, scale: func(self, n) { self.x *= n } }
+p := P()
+len2 := p.len2
+scale := p.scale
+`))
+	script.SetImports(stdlib.GetModuleMap("text"))
+	c, err := script.Compile()
+	require.NoError(t, err)
+	_, err = c.Call("add", 1, 2)
+	require.Error(t, err) // not run yet
+	require.Equal(t, "'add' is not callable", err.Error())
+	require.NoError(t, c.Run())
+
+	res, err := c.Call("add", 1, 2)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), res)
+	res, err = c.Call("add", "a", "b")
+	require.NoError(t, err)
+	require.Equal(t, "ab", res)
+	compiledGet(t, c, "count", int64(2))
+
+	res, err = c.Call("sum", 1, 2, 3)
+	require.NoError(t, err)
+	require.Equal(t, int64(6), res)
+	res, err = c.Call("sum")
+	require.NoError(t, err)
+	require.Equal(t, int64(0), res)
+
+	// globals are shared with the script
+	require.NoError(t, c.Set("prefix", "hi "))
+	res, err = c.Call("greet", "bob")
+	require.NoError(t, err)
+	require.Equal(t, "hi bob", res)
+
+	res, err = c.Call("upper", "abc")
+	require.NoError(t, err)
+	require.Equal(t, "ABC", res)
+
+	// bound methods
+	res, err = c.Call("len2")
+	require.NoError(t, err)
+	require.Equal(t, int64(25), res)
+	_, err = c.Call("scale", 2)
+	require.NoError(t, err)
+	res, err = c.Call("len2")
+	require.NoError(t, err)
+	require.Equal(t, int64(100), res)
+	_, err = c.Call("scale")
+	require.Error(t, err)
+	require.Equal(t,
+		"Runtime Error: wrong number of arguments: want=2, got=1",
+		err.Error())
+
+	_, err = c.Call("add", 1)
+	require.Error(t, err)
+	require.Equal(t,
+		"Runtime Error: wrong number of arguments: want=2, got=1",
+		err.Error())
+	_, err = c.Call("nested", 1)
+	require.Error(t, err)
+	require.Equal(t, "Runtime Error: not indexable: string\n"+
+		"\tat (main):13:11\n\tat (main):16:9", err.Error())
+	_, err = c.Call("foo")
+	require.Error(t, err)
+	require.Equal(t, "'foo' is not defined", err.Error())
+	_, err = c.Call("prefix")
+	require.Error(t, err)
+	require.Equal(t, "'prefix' is not callable", err.Error())
+	_, err = c.Call("add", make(chan int), 1)
+	require.Error(t, err)
+
+	// context
+	res, err = c.CallContext(context.Background(), "add", 2, 3)
+	require.NoError(t, err)
+	require.Equal(t, int64(5), res)
+	ctx, cancel := context.WithTimeout(context.Background(),
+		1*time.Millisecond)
+	defer cancel()
+	_, err = c.CallContext(ctx, "loop")
+	require.Equal(t, context.DeadlineExceeded, err)
+
+	// the script still runs after the calls
+	require.NoError(t, c.Run())
+	compiledGet(t, c, "count", int64(0))
+
+	// clones can be called concurrently
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(c *tengo.Compiled, i int) {
+			defer wg.Done()
+			res, err := c.Call("add", i, 1)
+			require.NoError(t, err)
+			require.Equal(t, int64(i+1), res)
+		}(c.Clone(), i)
+	}
+	wg.Wait()
+}
+
+type ctxKey struct{}
+
+// ctxValue is a callable object that returns the value of ctxKey in the
+// context of the run.
+type ctxValue struct {
+	tengo.ObjectImpl
+}
+
+func (o *ctxValue) TypeName() string {
+	return "ctx-value"
+}
+
+func (o *ctxValue) String() string {
+	return "<ctx-value>"
+}
+
+func (o *ctxValue) CanCall() bool {
+	return true
+}
+
+func (o *ctxValue) Call(args ...tengo.Object) (tengo.Object, error) {
+	return o.CallContext(context.Background(), args...)
+}
+
+func (o *ctxValue) CallContext(
+	ctx context.Context,
+	args ...tengo.Object,
+) (tengo.Object, error) {
+	v, _ := ctx.Value(ctxKey{}).(string)
+	return &tengo.String{Value: v}, nil
+}
+
+func TestUserContextFunction(t *testing.T) {
+	value := &tengo.UserContextFunction{
+		Name: "value",
+		Value: func(
+			ctx context.Context,
+			args ...tengo.Object,
+		) (tengo.Object, error) {
+			v, _ := ctx.Value(ctxKey{}).(string)
+			return &tengo.String{Value: v}, nil
+		},
+	}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "foo")
+
+	// directly, in a function and in a builtin function
+	s := tengo.NewScript([]byte(`
+out1 := value()
+out2 := func() { return value() + "!" }()
+out3 := sort_by([3, 1, 2], func(a, b) { return value() == "foo" ? a < b : a > b })
+f := func() { return value() }`))
+	require.NoError(t, s.Add("value", value))
+	c, err := s.Compile()
+	require.NoError(t, err)
+	require.NoError(t, c.RunContext(ctx))
+	compiledGet(t, c, "out1", "foo")
+	compiledGet(t, c, "out2", "foo!")
+	require.Equal(t, "[1, 2, 3]", c.Get("out3").Object().String())
+	res, err := c.CallContext(ctx, "f")
+	require.NoError(t, err)
+	require.Equal(t, "foo", res)
+
+	// background context
+	require.NoError(t, c.Run())
+	compiledGet(t, c, "out1", "")
+	require.Equal(t, "[3, 2, 1]", c.Get("out3").Object().String())
+	res, err = c.Call("f")
+	require.NoError(t, err)
+	require.Equal(t, "", res)
+
+	// function value
+	var fn tengo.CallableContextFunc = func(
+		ctx context.Context,
+		args ...tengo.Object,
+	) (tengo.Object, error) {
+		return nil, ctx.Err()
+	}
+	cctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c = compile(t, `out := fn()`, M{"fn": fn})
+	compiledRun(t, c)
+	err = c.RunContext(cctx)
+	require.Equal(t, context.Canceled, err)
+
+	// sort_by directly with the function
+	sortBy := &tengo.UserContextFunction{
+		Value: func(
+			ctx context.Context,
+			args ...tengo.Object,
+		) (tengo.Object, error) {
+			desc := ctx.Value(ctxKey{}) != nil
+			less := args[0].(*tengo.Int).Value < args[1].(*tengo.Int).Value
+			return tengo.FromInterface(less != desc)
+		},
+	}
+	c = compile(t, `out := sort_by([1, 3, 2], less)`, M{"less": sortBy})
+	require.NoError(t, c.RunContext(ctx))
+	require.Equal(t, "[3, 2, 1]", c.Get("out").Object().String())
+
+	// user types implementing CallableContext
+	c = compile(t, `
+out1 := value()
+out2 := sort_by([1, 2], func(a, b) { return value() == "foo" ? a > b : a < b })`,
+		M{"value": &ctxValue{}})
+	require.NoError(t, c.RunContext(ctx))
+	compiledGet(t, c, "out1", "foo")
+	require.Equal(t, "[2, 1]", c.Get("out2").Object().String())
+	compiledRun(t, c)
+	compiledGet(t, c, "out1", "")
+	require.Equal(t, "[1, 2]", c.Get("out2").Object().String())
+
+	// expression
+	e, err := tengo.CompileExpression(`value() + "bar"`, "value")
+	require.NoError(t, err)
+	res, err = e.Eval(ctx, M{"value": value})
+	require.NoError(t, err)
+	require.Equal(t, "foobar", res)
 }
 
 func compile(t *testing.T, input string, vars M) *tengo.Compiled {
@@ -13,6 +13,10 @@ var jsonModule = map[string]tengo.Object{
 		Name:  "decode",
 		Value: jsonDecode,
 	},
+	"decode_decimal": &tengo.UserFunction{
+		Name:  "decode_decimal",
+		Value: jsonDecodeDecimal,
+	},
 	"encode": &tengo.UserFunction{
 		Name:  "encode",
 		Value: jsonEncode,
@@ -28,13 +32,24 @@ var jsonModule = map[string]tengo.Object{
 }
 
 func jsonDecode(args ...tengo.Object) (ret tengo.Object, err error) {
+	return decodeJSON(json.Decode, args...)
+}
+
+func jsonDecodeDecimal(args ...tengo.Object) (ret tengo.Object, err error) {
+	return decodeJSON(json.DecodeDecimal, args...)
+}
+
+func decodeJSON(
+	decode func([]byte) (tengo.Object, error),
+	args ...tengo.Object,
+) (ret tengo.Object, err error) {
 	if len(args) != 1 {
 		return nil, tengo.ErrWrongNumArguments
 	}
 
 	switch o := args[0].(type) {
 	case *tengo.Bytes:
-		v, err := json.Decode(o.Value)
+		v, err := decode(o.Value)
 		if err != nil {
 			return &tengo.Error{
 				Value: &tengo.String{Value: err.Error()},
@@ -42,7 +57,7 @@ func jsonDecode(args ...tengo.Object) (ret tengo.Object, err error) {
 		}
 		return v, nil
 	case *tengo.String:
-		v, err := json.Decode([]byte(o.Value))
+		v, err := decode([]byte(o.Value))
 		if err != nil {
 			return &tengo.Error{
 				Value: &tengo.String{Value: err.Error()},
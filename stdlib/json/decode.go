@@ -7,6 +7,7 @@
 package json
 
 import (
+	"errors"
 	"strconv"
 	"unicode"
 	"unicode/utf16"
@@ -17,7 +18,17 @@ import (
 
 // Decode parses the JSON-encoded data and returns the result object.
 func Decode(data []byte) (tengo.Object, error) {
-	var d decodeState
+	return decode(data, false)
+}
+
+// DecodeDecimal is like Decode but decodes JSON numbers into Decimal values,
+// so that they keep their exact decimal representation.
+func DecodeDecimal(data []byte) (tengo.Object, error) {
+	return decode(data, true)
+}
+
+func decode(data []byte, useDecimal bool) (tengo.Object, error) {
+	d := decodeState{useDecimal: useDecimal}
 	err := checkValid(data, &d.scan)
 	if err != nil {
 		return nil, err
@@ -34,6 +45,8 @@ type decodeState struct {
 	off    int // next read offset in data
 	opcode int // last read result
 	scan   scanner
+
+	useDecimal bool // decode numbers into Decimal values
 }
 
 // readIndex returns the position of the last byte read.
@@ -130,7 +143,7 @@ func (d *decodeState) array() (tengo.Object, error) {
 }
 
 func (d *decodeState) object() (tengo.Object, error) {
-	m := make(map[string]tengo.Object)
+	m := &tengo.Map{Value: make(map[string]tengo.Object)}
 	for {
 		// Read opening " of string key or closing }.
 		d.scanWhile(scanSkipSpace)
@@ -166,7 +179,9 @@ func (d *decodeState) object() (tengo.Object, error) {
 			return nil, err
 		}
 
-		m[key] = o
+		if err := m.IndexSet(&tengo.String{Value: key}, o); err != nil {
+			return nil, err
+		}
 
 		// Next token must be , or }.
 		if d.opcode == scanSkipSpace {
@@ -179,7 +194,7 @@ func (d *decodeState) object() (tengo.Object, error) {
 			panic(phasePanicMsg)
 		}
 	}
-	return &tengo.Map{Value: m}, nil
+	return m, nil
 }
 
 func (d *decodeState) literal() (tengo.Object, error) {
@@ -210,6 +225,14 @@ func (d *decodeState) literal() (tengo.Object, error) {
 		if c != '-' && (c < '0' || c > '9') {
 			panic(phasePanicMsg)
 		}
+		if d.useDecimal {
+			n, ok := tengo.ParseDecimal(string(item))
+			if !ok {
+				return nil, errors.New("json: number out of range: " +
+					string(item))
+			}
+			return n, nil
+		}
 		n, _ := strconv.ParseFloat(string(item), 10)
 		return &tengo.Float{Value: n}, nil
 	}
@@ -11,6 +11,7 @@ import (
 	"errors"
 	"math"
 	"strconv"
+	"time"
 
 	"github.com/d5/tengo/v2"
 )
@@ -49,41 +50,13 @@ func Encode(o tengo.Object) ([]byte, error) {
 		}
 		b = append(b, ']')
 	case *tengo.Map:
-		b = append(b, '{')
-		len1 := len(o.Value) - 1
-		idx := 0
-		for key, value := range o.Value {
-			b = strconv.AppendQuote(b, key)
-			b = append(b, ':')
-			eb, err := Encode(value)
-			if err != nil {
-				return nil, err
-			}
-			b = append(b, eb...)
-			if idx < len1 {
-				b = append(b, ',')
-			}
-			idx++
-		}
-		b = append(b, '}')
+		return encodeMap(o.Iterate(), len(o.Hashed) > 0)
 	case *tengo.ImmutableMap:
-		b = append(b, '{')
-		len1 := len(o.Value) - 1
-		idx := 0
-		for key, value := range o.Value {
-			b = strconv.AppendQuote(b, key)
-			b = append(b, ':')
-			eb, err := Encode(value)
-			if err != nil {
-				return nil, err
-			}
-			b = append(b, eb...)
-			if idx < len1 {
-				b = append(b, ',')
-			}
-			idx++
-		}
-		b = append(b, '}')
+		return encodeMap(o.Iterate(), len(o.Hashed) > 0)
+	case *tengo.Set:
+		return Encode(&tengo.Array{Value: o.Elements()})
+	case *tengo.ImmutableSet:
+		return Encode(&tengo.Array{Value: o.Elements()})
 	case *tengo.Bool:
 		if o.IsFalsy() {
 			b = strconv.AppendBool(b, false)
@@ -129,6 +102,10 @@ func Encode(o tengo.Object) ([]byte, error) {
 		b = append(b, y...)
 	case *tengo.Int:
 		b = strconv.AppendInt(b, o.Value, 10)
+	case *tengo.BigInt:
+		b = o.Value.Append(b, 10)
+	case *tengo.Decimal:
+		b = append(b, o.String()...)
 	case *tengo.String:
 		b = strconv.AppendQuote(b, o.Value)
 	case *tengo.Time:
@@ -144,3 +121,59 @@ func Encode(o tengo.Object) ([]byte, error) {
 	}
 	return b, nil
 }
+
+// encodeMap returns the JSON encoding of a map in the order of its elements.
+// Keys that are not strings are encoded as strings of their JSON encodings
+// (e.g. "1" for 1), and, it returns an error for other keys or if two keys
+// have the same encoding.
+func encodeMap(it tengo.Iterator, hashed bool) ([]byte, error) {
+	b := []byte{'{'}
+	var keys map[string]bool
+	if hashed {
+		keys = make(map[string]bool)
+	}
+	for it.Next() {
+		var key string
+		if str, ok := it.Key().(*tengo.String); ok {
+			key = str.Value
+		} else {
+			var err error
+			if key, err = encodeKey(it.Key()); err != nil {
+				return nil, err
+			}
+		}
+		if hashed {
+			if keys[key] {
+				return nil, errors.New("duplicate map key: " + key)
+			}
+			keys[key] = true
+		}
+		if len(b) > 1 {
+			b = append(b, ',')
+		}
+		b = strconv.AppendQuote(b, key)
+		b = append(b, ':')
+		eb, err := Encode(it.Value())
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, eb...)
+	}
+	b = append(b, '}')
+	return b, nil
+}
+
+func encodeKey(o tengo.Object) (string, error) {
+	switch o := o.(type) {
+	case *tengo.Int, *tengo.BigInt, *tengo.Decimal, *tengo.Float,
+		*tengo.Char, *tengo.Bool:
+		b, err := Encode(o)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	case *tengo.Time:
+		return o.Value.Format(time.RFC3339Nano), nil
+	}
+	return "", errors.New("unsupported map key type: " + o.TypeName())
+}
@@ -2,6 +2,7 @@ package json_test
 
 import (
 	gojson "encoding/json"
+	"math/big"
 	"testing"
 
 	"github.com/d5/tengo/v2"
@@ -78,6 +79,73 @@ func TestDecode(t *testing.T) {
 	testDecodeError(t, `{"a":"b":"c"}`)
 }
 
+func TestBigNumbers(t *testing.T) {
+	huge, _ := new(big.Int).SetString("-123456789012345678901234567890", 10)
+	price, _ := tengo.ParseDecimal("19.90")
+
+	b, err := json.Encode(&tengo.Array{Value: []tengo.Object{
+		&tengo.BigInt{Value: huge}, price}})
+	require.NoError(t, err)
+	require.Equal(t, `[-123456789012345678901234567890,19.90]`, string(b))
+
+	o, err := json.Decode(b)
+	require.NoError(t, err)
+	require.Equal(t, &tengo.Float{Value: 19.9}, o.(*tengo.Array).Value[1])
+
+	o, err = json.DecodeDecimal(b)
+	require.NoError(t, err)
+	require.Equal(t, `[-123456789012345678901234567890, 19.90]`, o.String())
+	require.Equal(t, price, o.(*tengo.Array).Value[1])
+
+	o, err = json.DecodeDecimal([]byte(`{"a": 1.5e-2, "b": "0.1"}`))
+	require.NoError(t, err)
+	d, _ := tengo.ParseDecimal("0.015")
+	require.Equal(t, d, o.(*tengo.Map).Value["a"])
+	require.Equal(t, &tengo.String{Value: "0.1"}, o.(*tengo.Map).Value["b"])
+
+	_, err = json.DecodeDecimal([]byte(`1e100000`))
+	require.Error(t, err)
+}
+
+func TestSet(t *testing.T) {
+	s, err := tengo.NewSet(&tengo.Int{Value: 3}, &tengo.String{Value: "a"})
+	require.NoError(t, err)
+	b, err := json.Encode(s)
+	require.NoError(t, err)
+	require.Equal(t, `[3,"a"]`, string(b))
+
+	is, err := tengo.NewImmutableSet(&tengo.Int{Value: 1})
+	require.NoError(t, err)
+	b, err = json.Encode(is)
+	require.NoError(t, err)
+	require.Equal(t, `[1]`, string(b))
+}
+
+func TestHashedMapKeys(t *testing.T) {
+	m := &tengo.Map{Value: map[string]tengo.Object{}}
+	require.NoError(t, m.IndexSet(&tengo.Int{Value: 1}, tengo.TrueValue))
+	b, err := json.Encode(m)
+	require.NoError(t, err)
+	require.Equal(t, `{"1":true}`, string(b))
+
+	require.NoError(t, m.IndexSet(&tengo.String{Value: "1"}, tengo.TrueValue))
+	_, err = json.Encode(m)
+	require.Error(t, err) // duplicate key
+
+	m = &tengo.Map{Value: map[string]tengo.Object{}}
+	require.NoError(t, m.IndexSet(&tengo.ImmutableArray{}, tengo.TrueValue))
+	_, err = json.Encode(m)
+	require.Error(t, err)
+}
+
+func TestOrder(t *testing.T) {
+	o, err := json.Decode([]byte(`{"c": 1, "a": {"z": 2, "y": 3}, "b": 4}`))
+	require.NoError(t, err)
+	b, err := json.Encode(o)
+	require.NoError(t, err)
+	require.Equal(t, `{"c":1,"a":{"z":2,"y":3},"b":4}`, string(b))
+}
+
 func testDecodeError(t *testing.T, input string) {
 	_, err := json.Decode([]byte(input))
 	require.Error(t, err)
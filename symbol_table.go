@@ -64,10 +64,36 @@ func (t *SymbolTable) DefineBuiltin(index int, name string) *Symbol {
 		Scope: ScopeBuiltin,
 	}
 	t.store[name] = symbol
+	for i, s := range t.builtinSymbols {
+		if s.Name == name {
+			t.builtinSymbols[i] = symbol
+			return symbol
+		}
+	}
 	t.builtinSymbols = append(t.builtinSymbols, symbol)
 	return symbol
 }
 
+// RemoveBuiltin removes the symbol for builtin function. It returns false if
+// the builtin function name is not defined.
+func (t *SymbolTable) RemoveBuiltin(name string) bool {
+	if t.parent != nil {
+		return t.parent.RemoveBuiltin(name)
+	}
+
+	for i, s := range t.builtinSymbols {
+		if s.Name == name {
+			if sym, ok := t.store[name]; ok && sym == s {
+				delete(t.store, name)
+			}
+			t.builtinSymbols = append(t.builtinSymbols[:i:i],
+				t.builtinSymbols[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
 // Resolve resolves a symbol with a given name.
 func (t *SymbolTable) Resolve(
 	name string,
@@ -91,6 +91,31 @@ func TestSymbolTable(t *testing.T) {
 	resolveExpect(t, local2Block2, "b", globalSymbol("b", 1), 3)
 }
 
+func TestSymbolTable_Builtins(t *testing.T) {
+	global := symbolTable()
+	local := global.Fork(false)
+	require.Equal(t, symbol("foo", tengo.ScopeBuiltin, 0),
+		local.DefineBuiltin(0, "foo"))
+	require.Equal(t, symbol("bar", tengo.ScopeBuiltin, 1),
+		global.DefineBuiltin(1, "bar"))
+	require.Equal(t, symbol("foo", tengo.ScopeBuiltin, 2),
+		global.DefineBuiltin(2, "foo")) // replaces
+	require.Equal(t, 2, len(global.BuiltinSymbols()))
+	resolveExpect(t, local, "foo", symbol("foo", tengo.ScopeBuiltin, 2), 1)
+
+	require.True(t, local.RemoveBuiltin("foo"))
+	require.False(t, global.RemoveBuiltin("foo"))
+	require.Equal(t, 1, len(global.BuiltinSymbols()))
+	_, _, ok := local.Resolve("foo")
+	require.False(t, ok)
+
+	// global variable of the same name is kept
+	global.DefineBuiltin(3, "baz")
+	global.Define("baz")
+	require.True(t, global.RemoveBuiltin("baz"))
+	resolveExpect(t, global, "baz", globalSymbol("baz", 0), 0)
+}
+
 func symbol(
 	name string,
 	scope tengo.SymbolScope,
@@ -1,8 +1,12 @@
 package tengo
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"math"
+	"math/big"
+	"reflect"
 	"strconv"
 	"time"
 )
@@ -15,6 +19,18 @@ var (
 	// MaxBytesLen is the maximum length for bytes value. Note this limit
 	// applies to all compiler/VM instances in the process.
 	MaxBytesLen = 2147483647
+
+	// MaxBigIntBits is the maximum bit length of the results of the
+	// multiplication, the power and the left shift operations on bigint and
+	// decimal values. Note this limit applies to all compiler/VM instances in
+	// the process.
+	MaxBigIntBits = 1 << 20
+
+	// DecimalDivisionPrecision is the number of digits after the decimal
+	// point that are kept when the quotient of a decimal division cannot be
+	// represented exactly. Note this applies to all compiler/VM instances in
+	// the process.
+	DecimalDivisionPrecision = 16
 )
 
 const (
@@ -26,11 +42,22 @@ const (
 
 	// MaxFrames is the maximum number of function frames for a VM.
 	MaxFrames = 1024
+
+	// MaxBuiltins is the maximum number of builtin functions, including the
+	// default ones, for a compiler.
+	MaxBuiltins = 256
 )
 
 // CallableFunc is a function signature for the callable functions.
 type CallableFunc = func(args ...Object) (ret Object, err error)
 
+// CallableContextFunc is a function signature for the callable functions that
+// receive the context of the run.
+type CallableContextFunc = func(
+	ctx context.Context,
+	args ...Object,
+) (ret Object, err error)
+
 // CountObjects returns the number of objects that a given object o contains.
 // For scalar value types, it will always be 1. For compound value types,
 // this will include its elements and all of their elements recursively.
@@ -49,10 +76,20 @@ func CountObjects(o Object) (c int) {
 		for _, v := range o.Value {
 			c += CountObjects(v)
 		}
+		for _, e := range o.Hashed {
+			c += CountObjects(e.Value)
+		}
 	case *ImmutableMap:
 		for _, v := range o.Value {
 			c += CountObjects(v)
 		}
+		for _, e := range o.Hashed {
+			c += CountObjects(e.Value)
+		}
+	case *Set:
+		c += o.Len()
+	case *ImmutableSet:
+		c += o.Len()
 	case *Error:
 		c += CountObjects(o.Value)
 	}
@@ -82,6 +119,16 @@ func ToInt(o Object) (v int, ok bool) {
 	case *Float:
 		v = int(o.Value)
 		ok = true
+	case *BigInt:
+		if o.Value.IsInt64() {
+			v = int(o.Value.Int64())
+			ok = true
+		}
+	case *Decimal:
+		if t := o.truncate(); t.IsInt64() {
+			v = int(t.Int64())
+			ok = true
+		}
 	case *Char:
 		v = int(o.Value)
 		ok = true
@@ -109,6 +156,16 @@ func ToInt64(o Object) (v int64, ok bool) {
 	case *Float:
 		v = int64(o.Value)
 		ok = true
+	case *BigInt:
+		if o.Value.IsInt64() {
+			v = o.Value.Int64()
+			ok = true
+		}
+	case *Decimal:
+		if t := o.truncate(); t.IsInt64() {
+			v = t.Int64()
+			ok = true
+		}
 	case *Char:
 		v = int64(o.Value)
 		ok = true
@@ -136,6 +193,12 @@ func ToFloat64(o Object) (v float64, ok bool) {
 	case *Float:
 		v = o.Value
 		ok = true
+	case *BigInt:
+		v = o.float64()
+		ok = true
+	case *Decimal:
+		v = o.float64()
+		ok = true
 	case *String:
 		c, err := strconv.ParseFloat(o.Value, 64)
 		if err == nil {
@@ -146,6 +209,49 @@ func ToFloat64(o Object) (v float64, ok bool) {
 	return
 }
 
+// ToBigInt will try to convert object o to *big.Int value. Floats and decimals
+// are truncated toward zero.
+func ToBigInt(o Object) (v *big.Int, ok bool) {
+	switch o := o.(type) {
+	case *BigInt:
+		v = o.Value
+		ok = true
+	case *Int:
+		v = big.NewInt(o.Value)
+		ok = true
+	case *Float:
+		if !math.IsNaN(o.Value) && !math.IsInf(o.Value, 0) {
+			v, _ = big.NewFloat(o.Value).Int(nil)
+			ok = true
+		}
+	case *Decimal:
+		v = o.truncate()
+		ok = true
+	case *Char:
+		v = big.NewInt(int64(o.Value))
+		ok = true
+	case *Bool:
+		v = new(big.Int)
+		if o == TrueValue {
+			v.SetInt64(1)
+		}
+		ok = true
+	case *String:
+		v, ok = new(big.Int).SetString(o.Value, 10)
+	}
+	return
+}
+
+// ToDecimal will try to convert object o to Decimal value. Floats are
+// converted using their shortest decimal representation.
+func ToDecimal(o Object) (v *Decimal, ok bool) {
+	switch o := o.(type) {
+	case *String:
+		return ParseDecimal(o.Value)
+	}
+	return toDecimal(o)
+}
+
 // ToBool will try to convert object o to bool value.
 func ToBool(o Object) (v bool, ok bool) {
 	ok = true
@@ -218,29 +324,45 @@ func ToInterface(o Object) (res interface{}) {
 			res.([]interface{})[i] = ToInterface(val)
 		}
 	case *Map:
-		res = make(map[string]interface{})
-		for key, v := range o.Value {
-			res.(map[string]interface{})[key] = ToInterface(v)
-		}
+		res = mapToInterface(o.Value, o.Hashed)
 	case *ImmutableMap:
+		res = mapToInterface(o.Value, o.Hashed)
+	case *Record:
 		res = make(map[string]interface{})
-		for key, v := range o.Value {
-			res.(map[string]interface{})[key] = ToInterface(v)
+		for i, name := range o.Type.Fields {
+			res.(map[string]interface{})[name] = ToInterface(o.Values[i])
 		}
+	case *Set:
+		res = setToInterface(o.elems)
+	case *ImmutableSet:
+		res = setToInterface(o.elems)
+	case *BigInt:
+		res = new(big.Int).Set(o.Value)
+	case *Decimal:
+		res = o.rat()
 	case *Time:
 		res = o.Value
 	case *Error:
 		res = errors.New(o.String())
 	case *Undefined:
 		res = nil
+	case *Proxy:
+		res = o.Value()
 	case Object:
 		return o
 	}
 	return
 }
 
-// FromInterface will attempt to convert an interface{} v to a Tengo Object
+// FromInterface will attempt to convert an interface{} v to a Tengo Object.
+// It returns an error if v refers to itself, e.g. a struct pointing to itself.
 func FromInterface(v interface{}) (Object, error) {
+	return fromInterface(v, nil)
+}
+
+// fromInterface converts v to an Object. visited holds the Go values being
+// converted by the callers, see enterValue.
+func fromInterface(v interface{}, visited map[visitKey]bool) (Object, error) {
 	switch v := v.(type) {
 	case nil:
 		return UndefinedValue, nil
@@ -274,21 +396,52 @@ func FromInterface(v interface{}) (Object, error) {
 	case map[string]Object:
 		return &Map{Value: v}, nil
 	case map[string]interface{}:
+		visited, err := enterValue(visited, reflect.ValueOf(v))
+		if err != nil {
+			return nil, err
+		}
+		defer leaveValue(visited, reflect.ValueOf(v))
 		kv := make(map[string]Object)
 		for vk, vv := range v {
-			vo, err := FromInterface(vv)
+			vo, err := fromInterface(vv, visited)
 			if err != nil {
 				return nil, err
 			}
 			kv[vk] = vo
 		}
 		return &Map{Value: kv}, nil
+	case map[interface{}]interface{}:
+		visited, err := enterValue(visited, reflect.ValueOf(v))
+		if err != nil {
+			return nil, err
+		}
+		defer leaveValue(visited, reflect.ValueOf(v))
+		m := &Map{Value: make(map[string]Object)}
+		for vk, vv := range v {
+			ko, err := keyFromInterface(vk)
+			if err != nil {
+				return nil, err
+			}
+			vo, err := fromInterface(vv, visited)
+			if err != nil {
+				return nil, err
+			}
+			if err := m.IndexSet(ko, vo); err != nil {
+				return nil, ErrNotHashable
+			}
+		}
+		return m, nil
 	case []Object:
 		return &Array{Value: v}, nil
 	case []interface{}:
+		visited, err := enterValue(visited, reflect.ValueOf(v))
+		if err != nil {
+			return nil, err
+		}
+		defer leaveValue(visited, reflect.ValueOf(v))
 		arr := make([]Object, len(v))
 		for i, e := range v {
-			vo, err := FromInterface(e)
+			vo, err := fromInterface(e, visited)
 			if err != nil {
 				return nil, err
 			}
@@ -297,10 +450,116 @@ func FromInterface(v interface{}) (Object, error) {
 		return &Array{Value: arr}, nil
 	case time.Time:
 		return &Time{Value: v}, nil
+	case map[string]struct{}:
+		s := &Set{}
+		for e := range v {
+			s.add(&String{Value: e})
+		}
+		return s, nil
+	case map[int]struct{}:
+		s := &Set{}
+		for e := range v {
+			s.add(&Int{Value: int64(e)})
+		}
+		return s, nil
+	case map[int64]struct{}:
+		s := &Set{}
+		for e := range v {
+			s.add(&Int{Value: e})
+		}
+		return s, nil
+	case map[interface{}]struct{}:
+		s := &Set{}
+		for e := range v {
+			eo, err := fromInterface(e, visited)
+			if err != nil {
+				return nil, err
+			}
+			if !s.add(eo) {
+				return nil, ErrNotHashable
+			}
+		}
+		return s, nil
+	case *big.Int:
+		return &BigInt{Value: new(big.Int).Set(v)}, nil
+	case *big.Rat:
+		return decimalFromRat(v), nil
+	case *big.Float:
+		if d, ok := ParseDecimal(v.Text('e', -1)); ok {
+			return d, nil
+		}
+		return nil, fmt.Errorf("cannot convert to object: %v", v)
 	case Object:
 		return v, nil
 	case CallableFunc:
 		return &UserFunction{Value: v}, nil
+	case CallableContextFunc:
+		return &UserContextFunction{Value: v}, nil
+	}
+	return fromValue(reflect.ValueOf(v), visited)
+}
+
+// setToInterface converts set elements to a []interface{} value.
+func setToInterface(elems []Object) []interface{} {
+	res := make([]interface{}, len(elems))
+	for i, e := range elems {
+		res[i] = ToInterface(e)
+	}
+	return res
+}
+
+// mapToInterface returns a map[string]interface{} for a map with only string
+// keys, or, a map[interface{}]interface{} otherwise.
+func mapToInterface(
+	v map[string]Object,
+	h map[string]MapEntry,
+) interface{} {
+	if len(h) == 0 {
+		res := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			res[key] = ToInterface(val)
+		}
+		return res
+	}
+	res := make(map[interface{}]interface{}, len(v)+len(h))
+	for key, val := range v {
+		res[key] = ToInterface(val)
+	}
+	for _, e := range h {
+		res[keyToInterface(e.Key)] = ToInterface(e.Value)
+	}
+	return res
+}
+
+// keyToInterface converts a map key to a comparable Go value. Immutable
+// arrays are converted to Go arrays ([N]interface{}) of their elements.
+func keyToInterface(o Object) interface{} {
+	arr, ok := o.(*ImmutableArray)
+	if !ok {
+		return ToInterface(o)
+	}
+	res := reflect.New(reflect.ArrayOf(len(arr.Value),
+		reflect.TypeOf((*interface{})(nil)).Elem())).Elem()
+	for i, e := range arr.Value {
+		res.Index(i).Set(reflect.ValueOf(keyToInterface(e)))
+	}
+	return res.Interface()
+}
+
+// keyFromInterface converts a Go map key to an object. Go arrays are
+// converted to immutable arrays of their elements.
+func keyFromInterface(v interface{}) (Object, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Array {
+		return FromInterface(v)
+	}
+	arr := make([]Object, rv.Len())
+	for i := range arr {
+		eo, err := keyFromInterface(rv.Index(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		arr[i] = eo
 	}
-	return nil, fmt.Errorf("cannot convert to object: %T", v)
+	return &ImmutableArray{Value: arr}, nil
 }
@@ -1,6 +1,10 @@
 package tengo_test
 
 import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -104,11 +108,376 @@ func TestNumObjects(t *testing.T) {
 			&tengo.Int{Value: 5},
 		}},
 	}}, 7)
+	is, err := tengo.NewImmutableSet(
+		&tengo.Int{Value: 1}, &tengo.Int{Value: 2})
+	require.NoError(t, err)
+	testCountObjects(t, is, 3)
 	testCountObjects(t, &tengo.String{Value: "foo bar"}, 1)
 	testCountObjects(t, &tengo.Time{Value: time.Now()}, 1)
 	testCountObjects(t, tengo.UndefinedValue, 1)
 }
 
+func TestInterface_BigNumbers(t *testing.T) {
+	huge, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+
+	o, err := tengo.FromInterface(huge)
+	require.NoError(t, err)
+	require.Equal(t, &tengo.BigInt{Value: huge}, o)
+	v := tengo.ToInterface(o).(*big.Int)
+	require.True(t, v.Cmp(huge) == 0)
+	require.False(t, v == huge) // copied
+
+	o, err = tengo.FromInterface(big.NewRat(5, 4))
+	require.NoError(t, err)
+	require.Equal(t, "1.25", o.String())
+	require.True(t, tengo.ToInterface(o).(*big.Rat).Cmp(big.NewRat(5, 4)) == 0)
+
+	o, err = tengo.FromInterface(big.NewRat(1, 3))
+	require.NoError(t, err)
+	require.Equal(t, "0.3333333333333333", o.String())
+
+	o, err = tengo.FromInterface(big.NewFloat(0.5))
+	require.NoError(t, err)
+	require.Equal(t, "0.5", o.String())
+
+	_, err = tengo.FromInterface(new(big.Float).SetInf(false))
+	require.Error(t, err)
+}
+
+func TestInterface_Set(t *testing.T) {
+	o, err := tengo.FromInterface(map[string]struct{}{"a": {}})
+	require.NoError(t, err)
+	require.Equal(t, "set(\"a\")", o.String())
+	v := tengo.ToInterface(o).([]interface{})
+	require.Equal(t, 1, len(v))
+	require.Equal(t, "a", v[0])
+
+	o, err = tengo.FromInterface(map[interface{}]struct{}{int64(1): {}})
+	require.NoError(t, err)
+	require.Equal(t, "set(1)", o.String())
+
+	_, err = tengo.FromInterface(map[interface{}]struct{}{
+		[1]int{1}: {}})
+	require.Error(t, err)
+}
+
+func TestInterface_HashedMapKeys(t *testing.T) {
+	m := &tengo.Map{Value: map[string]tengo.Object{"a": &tengo.Int{Value: 1}}}
+	require.NoError(t, m.IndexSet(&tengo.Int{Value: 2}, &tengo.Int{Value: 3}))
+	require.NoError(t, m.IndexSet(&tengo.ImmutableArray{Value: []tengo.Object{
+		&tengo.Int{Value: 4}, &tengo.String{Value: "b"}}},
+		&tengo.Int{Value: 5}))
+	testCountObjects(t, m, 4)
+
+	v := tengo.ToInterface(m).(map[interface{}]interface{})
+	require.Equal(t, 3, len(v))
+	require.Equal(t, int64(1), v["a"])
+	require.Equal(t, int64(3), v[int64(2)])
+	require.Equal(t, int64(5), v[[2]interface{}{int64(4), "b"}])
+
+	o, err := tengo.FromInterface(v)
+	require.NoError(t, err)
+	require.True(t, m.Equals(o))
+
+	// maps with only string keys are unchanged
+	_, ok := tengo.ToInterface(&tengo.Map{
+		Value: map[string]tengo.Object{}}).(map[string]interface{})
+	require.True(t, ok)
+
+	_, err = tengo.FromInterface(map[interface{}]interface{}{nil: 1})
+	require.Error(t, err)
+}
+
+func TestInterface_MapOrder(t *testing.T) {
+	o, err := tengo.FromInterface(map[string]interface{}{
+		"c": 1, "a": 2, "b": 3})
+	require.NoError(t, err)
+	require.Equal(t, "{a: 2, b: 3, c: 1}", o.String())
+}
+
+type reflectInner struct {
+	Z    int16
+	Tags []string
+}
+
+type reflectEmbedded struct {
+	E string
+	A string // shadowed by the outer field
+}
+
+type reflectStruct struct {
+	reflectEmbedded
+	A       int8
+	B       float32           `tengo:"b"`
+	C       *reflectInner     `tengo:"c,omitempty"`
+	D       map[string]uint16 `tengo:"d"`
+	Ignored string            `tengo:"-"`
+	S       map[int]struct{}  `tengo:"s"`
+	T       time.Time         `tengo:"t"`
+	O       tengo.Object      `tengo:"o"`
+	I       interface{}       `tengo:"i"`
+	unexp   int
+	Arr     [2]uint            `tengo:"arr"`
+	Bytes   []byte             `tengo:"bytes"`
+	M       map[int64][]string `tengo:"m"`
+}
+
+type reflectNode struct {
+	Name string
+	Next *reflectNode
+}
+
+func TestInterface_Reflection(t *testing.T) {
+	expectFromInterface := func(v interface{}, expected string) {
+		o, err := tengo.FromInterface(v)
+		require.NoError(t, err)
+		require.Equal(t, expected, o.String())
+	}
+	expectFromInterface(int8(-1), "-1")
+	expectFromInterface(int16(1), "1")
+	expectFromInterface(int32('a'), "a") // rune
+	expectFromInterface(uint16(2), "2")
+	expectFromInterface(uint64(1<<63), "9223372036854775808")
+	expectFromInterface(float32(1.5), "1.5")
+	expectFromInterface([]string{"a", "b"}, `["a", "b"]`)
+	expectFromInterface([2]int{1, 2}, "[1, 2]")
+	expectFromInterface(map[string]int{"b": 1, "a": 2}, "{a: 2, b: 1}")
+	expectFromInterface(map[int]string{1: "a"}, `{1: "a"}`)
+	expectFromInterface(map[string]struct{}{"a": {}}, `set("a")`)
+	expectFromInterface(map[int8]struct{}{1: {}}, "set(1)")
+	expectFromInterface((*int)(nil), "<undefined>")
+	n := 3
+	expectFromInterface(&n, "3")
+	expectFromInterface(&reflectInner{Z: 1, Tags: []string{"x"}},
+		`{Z: 1, Tags: ["x"]}`)
+
+	type named string
+	expectFromInterface(named("foo"), `"foo"`)
+	expectFromInterface(struct{}{}, "{}")
+
+	o, err := tengo.FromInterface(reflectStruct{
+		reflectEmbedded: reflectEmbedded{E: "e", A: "shadowed"},
+		A:               1,
+		B:               2.5,
+		D:               map[string]uint16{"x": 1},
+		Ignored:         "ignored",
+		O:               &tengo.String{Value: "obj"},
+	})
+	require.NoError(t, err)
+	m := o.(*tengo.Map)
+	require.Equal(t, int64(1), m.Value["A"].(*tengo.Int).Value)
+	require.Equal(t, 2.5, m.Value["b"].(*tengo.Float).Value)
+	require.Equal(t, "e", m.Value["E"].(*tengo.String).Value)
+	require.Equal(t, "{x: 1}", m.Value["d"].String())
+	require.Equal(t, `"obj"`, m.Value["o"].String())
+	require.Equal(t, tengo.UndefinedValue, m.Value["i"])
+	_, ok := m.Value["c"] // omitempty
+	require.False(t, ok)
+	_, ok = m.Value["Ignored"]
+	require.False(t, ok)
+	_, ok = m.Value["unexp"]
+	require.False(t, ok)
+
+	_, err = tengo.FromInterface(make(chan int))
+	require.Error(t, err)
+	_, err = tengo.FromInterface([]interface{}{complex(1, 2)})
+	require.Error(t, err)
+
+	// values referring to themselves
+	node := &reflectNode{Name: "a"}
+	node.Next = node
+	_, err = tengo.FromInterface(node)
+	require.Error(t, err)
+	require.Equal(t,
+		"cannot convert to object: cyclic value: *tengo_test.reflectNode",
+		err.Error())
+	node.Next = &reflectNode{Name: "b", Next: &reflectNode{Name: "c"}}
+	node.Next.Next.Next = node
+	_, err = tengo.FromInterface(node)
+	require.Error(t, err)
+	cyclicMap := map[string]interface{}{}
+	cyclicMap["m"] = cyclicMap
+	_, err = tengo.FromInterface(cyclicMap)
+	require.Error(t, err)
+	cyclicArr := []interface{}{1}
+	cyclicArr[0] = cyclicArr
+	_, err = tengo.FromInterface(cyclicArr)
+	require.Error(t, err)
+
+	// shared values are not cycles
+	shared := &reflectNode{Name: "s"}
+	expectFromInterface([]*reflectNode{shared, shared},
+		`[{Name: "s", Next: <undefined>}, {Name: "s", Next: <undefined>}]`)
+}
+
+func TestDecode(t *testing.T) {
+	var i int32
+	require.NoError(t, tengo.Decode(&tengo.Int{Value: 5}, &i))
+	require.Equal(t, int64(5), int64(i))
+	require.Error(t, tengo.Decode(&tengo.Int{Value: 1 << 40}, &i))
+	require.Error(t, tengo.Decode(&tengo.String{Value: "5"}, &i))
+	require.Error(t, tengo.Decode(&tengo.Int{Value: 5}, i))
+	require.Error(t, tengo.Decode(&tengo.Int{Value: 5}, nil))
+
+	var u uint8
+	require.NoError(t, tengo.Decode(&tengo.Float{Value: 255}, &u))
+	require.Equal(t, int64(255), int64(u))
+	require.Error(t, tengo.Decode(&tengo.Int{Value: -1}, &u))
+
+	var strs []string
+	require.NoError(t, tengo.Decode(&tengo.Array{Value: []tengo.Object{
+		&tengo.String{Value: "a"}, &tengo.Char{Value: 'b'}}}, &strs))
+	require.Equal(t, []string{"a", "b"}, strs)
+	require.Error(t, tengo.Decode(&tengo.Array{Value: []tengo.Object{
+		&tengo.Int{Value: 1}}}, &strs))
+
+	var any interface{}
+	require.NoError(t, tengo.Decode(&tengo.Array{Value: []tengo.Object{
+		&tengo.Int{Value: 1}}}, &any))
+	require.Equal(t, int64(1), any.([]interface{})[0])
+
+	var set map[string]bool
+	s, err := tengo.NewImmutableSet(&tengo.String{Value: "a"})
+	require.NoError(t, err)
+	require.NoError(t, tengo.Decode(s, &set))
+	require.True(t, set["a"])
+	require.Equal(t, 1, len(set))
+
+	src := []byte(`
+m := {}
+m[1] = ["a"]
+out := {
+	A: 1,
+	E: "e",
+	b: 1.5,
+	c: {z: 3, tags: ["x", "y"]},
+	d: {x: 7},
+	s: set(1, 2),
+	o: "obj",
+	i: [1, "two"],
+	arr: [1, 2, 3],
+	bytes: bytes("hi"),
+	m: m,
+	unknown: true
+}`)
+	c, err := tengo.NewScript(src).Run()
+	require.NoError(t, err)
+	var res reflectStruct
+	require.NoError(t, c.Get("out").Decode(&res))
+	require.Equal(t, int64(1), int64(res.A))
+	require.Equal(t, "e", res.E)
+	require.Equal(t, 1.5, float64(res.B))
+	require.Equal(t, int64(3), int64(res.C.Z))
+	require.Equal(t, []string{"x", "y"}, res.C.Tags)
+	require.Equal(t, int64(7), int64(res.D["x"]))
+	require.Equal(t, 2, len(res.S))
+	_, ok := res.S[2]
+	require.True(t, ok)
+	require.Equal(t, `"obj"`, res.O.String())
+	require.Equal(t, "two", res.I.([]interface{})[1])
+	require.Equal(t, int64(2), int64(res.Arr[1]))
+	require.Equal(t, []byte("hi"), res.Bytes)
+	require.Equal(t, []string{"a"}, res.M[1])
+
+	err = tengo.Decode(&tengo.Map{Value: map[string]tengo.Object{
+		"A": &tengo.String{Value: "x"}}}, &res)
+	require.Error(t, err)
+	require.Equal(t, "cannot decode string into int8", err.Error())
+	require.Error(t, tengo.Decode(&tengo.Int{Value: 1}, &res))
+
+	// round trip
+	in := reflectStruct{A: 3, C: &reflectInner{Z: 4}, M: map[int64][]string{
+		5: {"a"}}}
+	o, err := tengo.FromInterface(in)
+	require.NoError(t, err)
+	var out reflectStruct
+	require.NoError(t, tengo.Decode(o, &out))
+	require.Equal(t, int64(3), int64(out.A))
+	require.Equal(t, int64(4), int64(out.C.Z))
+	require.Equal(t, []string{"a"}, out.M[5])
+}
+
+func TestWrapFunc(t *testing.T) {
+	expectCall := func(fn interface{}, args []tengo.Object, expected string) {
+		f, err := tengo.WrapFunc(fn)
+		require.NoError(t, err)
+		res, err := f(args...)
+		require.NoError(t, err)
+		require.Equal(t, expected, res.String())
+	}
+	expectCallError := func(fn interface{}, args []tengo.Object, expected string) {
+		f, err := tengo.WrapFunc(fn)
+		require.NoError(t, err)
+		_, err = f(args...)
+		require.Error(t, err)
+		require.Equal(t, expected, err.Error())
+	}
+	i := func(v int64) tengo.Object { return &tengo.Int{Value: v} }
+	str := func(v string) tengo.Object { return &tengo.String{Value: v} }
+
+	expectCall(func() {}, nil, "<undefined>")
+	expectCall(func(a, b int16) int16 { return a + b },
+		[]tengo.Object{i(1), i(2)}, "3")
+	expectCall(strings.Repeat, []tengo.Object{str("ab"), i(2)}, `"abab"`)
+	expectCall(func(sep string, elems ...string) string {
+		return strings.Join(elems, sep)
+	}, []tengo.Object{str("-"), str("a"), str("b")}, `"a-b"`)
+	expectCall(func(sep string, elems ...string) string {
+		return strings.Join(elems, sep)
+	}, []tengo.Object{str("-")}, `""`)
+	expectCall(func(x float64) (float64, bool) { return x * 2, x > 0 },
+		[]tengo.Object{i(2)}, "[4, true]")
+	expectCall(func(m map[string]int) int { return len(m) },
+		[]tengo.Object{&tengo.Map{Value: map[string]tengo.Object{
+			"a": i(1)}}}, "1")
+	expectCall(func(o tengo.Object, v interface{}) string {
+		return o.TypeName() + fmt.Sprint(v)
+	}, []tengo.Object{i(1), i(2)}, `"int2"`)
+
+	// error results
+	expectCall(func() error { return nil }, nil, "true")
+	expectCall(func() error { return errors.New("oops") }, nil,
+		`error: "oops"`)
+	expectCall(strconv.Atoi, []tengo.Object{str("12")}, "12")
+	expectCall(strconv.Atoi, []tengo.Object{str("x")},
+		`error: "strconv.Atoi: parsing \"x\": invalid syntax"`)
+
+	// argument errors
+	expectCallError(strings.Repeat, []tengo.Object{str("a")},
+		"wrong number of arguments")
+	expectCallError(strings.Repeat, []tengo.Object{str("a"), i(1), i(2)},
+		"wrong number of arguments")
+	expectCallError(strings.Repeat, []tengo.Object{i(1), i(2)},
+		"invalid type for argument 'first': expected string, found int")
+	expectCallError(func(s string, n ...int) {},
+		[]tengo.Object{str("a"), i(1), str("b")},
+		"invalid type for argument 'third': expected int(compatible), found string")
+	expectCallError(func(n int8) {}, []tengo.Object{i(1000)},
+		"invalid type for argument 'first': expected int(compatible), found int")
+
+	// callable functions are not wrapped
+	f, err := tengo.WrapFunc(func(args ...tengo.Object) (tengo.Object, error) {
+		return i(int64(len(args))), nil
+	})
+	require.NoError(t, err)
+	res, err := f(i(1), i(2))
+	require.NoError(t, err)
+	require.Equal(t, "2", res.String())
+
+	_, err = tengo.WrapFunc(1)
+	require.Error(t, err)
+	_, err = tengo.WrapFunc((func())(nil))
+	require.Error(t, err)
+
+	// Go functions are wrapped by FromInterface
+	s := tengo.NewScript([]byte(`a := repeat("ab", 2); b := upper(a)`))
+	require.NoError(t, s.Add("repeat", strings.Repeat))
+	require.NoError(t, s.Add("upper", strings.ToUpper))
+	c, err := s.Run()
+	require.NoError(t, err)
+	require.Equal(t, "ABAB", c.Get("b").String())
+}
+
 func testCountObjects(t *testing.T, o tengo.Object, expected int) {
 	require.Equal(t, expected, tengo.CountObjects(o))
 }
@@ -87,7 +87,8 @@ func (v *Variable) Array() []interface{} {
 }
 
 // Map returns map[string]interface{} value of the variable value. It returns
-// 0 if the value is not convertible to map[string]interface{}.
+// 0 if the value is not convertible to map[string]interface{}. Elements whose
+// keys are not strings are omitted.
 func (v *Variable) Map() map[string]interface{} {
 	switch val := v.value.(type) {
 	case *Map:
@@ -130,6 +131,12 @@ func (v *Variable) Object() Object {
 	return v.value
 }
 
+// Decode stores the variable value in the Go value pointed to by out. See
+// tengo.Decode for the conversion rules.
+func (v *Variable) Decode(out interface{}) error {
+	return Decode(v.value, out)
+}
+
 // IsUndefined returns true if the underlying value is undefined.
 func (v *Variable) IsUndefined() bool {
 	return v.value == UndefinedValue
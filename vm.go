@@ -1,7 +1,12 @@
 package tengo
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math"
+	"math/big"
+	"sync"
 	"sync/atomic"
 
 	"github.com/d5/tengo/v2/parser"
@@ -14,11 +19,13 @@ type frame struct {
 	freeVars    []*ObjectPtr
 	ip          int
 	basePointer int
+	nested      bool // returns to the Go caller, see VM.call
 }
 
 // VM is a virtual machine that executes the bytecode compiled by Compiler.
 type VM struct {
 	constants   []Object
+	builtins    []*BuiltinFunction
 	stack       [StackSize]Object
 	sp          int
 	globals     []Object
@@ -32,6 +39,13 @@ type VM struct {
 	maxAllocs   int64
 	allocs      int64
 	err         error
+	ctx         context.Context // passed to CallableContext objects
+	ctxDone     <-chan struct{} // nil if the context cannot be done
+	ctxTicks    int
+	maxSP       int // high-water marks of sp and framesIndex for reset
+	maxFrames   int
+
+	overflowCheck bool
 }
 
 // NewVM creates a VM.
@@ -43,14 +57,20 @@ func NewVM(
 	if globals == nil {
 		globals = make([]Object, GlobalsSize)
 	}
+	builtins := bytecode.Builtins
+	if builtins == nil {
+		builtins = builtinFuncs
+	}
 	v := &VM{
 		constants:   bytecode.Constants,
+		builtins:    builtins,
 		sp:          0,
 		globals:     globals,
 		fileSet:     bytecode.FileSet,
 		framesIndex: 1,
 		ip:          -1,
 		maxAllocs:   maxAllocs,
+		maxFrames:   1,
 	}
 	v.frames[0].fn = bytecode.MainFunction
 	v.frames[0].ip = -1
@@ -59,6 +79,83 @@ func NewVM(
 	return v
 }
 
+// reset clears the states of the VM so it can be reused for another run
+// without keeping the objects of the previous run alive.
+func (v *VM) reset() {
+	main := v.frames[0].fn
+	// only the used part of the stack and the frames is cleared
+	if v.sp > v.maxSP {
+		v.maxSP = v.sp
+	}
+	stack := v.stack[:v.maxSP]
+	for i := range stack {
+		stack[i] = nil
+	}
+	frames := v.frames[:v.maxFrames]
+	for i := range frames {
+		frames[i] = frame{}
+	}
+	v.maxSP = 0
+	v.maxFrames = 1
+	v.frames[0].fn = main
+	v.frames[0].ip = -1
+	v.sp = 0
+	v.globals = nil
+	v.framesIndex = 1
+	v.curFrame = &v.frames[0]
+	v.curInsts = main.Instructions
+	v.ip = -1
+	v.err = nil
+	v.setContext(nil)
+	atomic.StoreInt64(&v.aborting, 0)
+}
+
+// vmPool is a pool of reusable VMs that execute the same bytecode.
+type vmPool struct {
+	bytecode      *Bytecode
+	maxAllocs     int64
+	overflowCheck bool
+	pool          sync.Pool
+}
+
+func newVMPool(
+	bytecode *Bytecode,
+	maxAllocs int64,
+	overflowCheck bool,
+) *vmPool {
+	return &vmPool{
+		bytecode:      bytecode,
+		maxAllocs:     maxAllocs,
+		overflowCheck: overflowCheck,
+	}
+}
+
+// get returns a VM from the pool, or, a new VM if the pool is empty. The VM
+// should be returned to the pool using put after the run.
+func (p *vmPool) get(globals []Object) *VM {
+	if v, ok := p.pool.Get().(*VM); ok {
+		v.globals = globals
+		return v
+	}
+	v := NewVM(p.bytecode, globals, p.maxAllocs)
+	v.EnableOverflowCheck(p.overflowCheck)
+	return v
+}
+
+// put resets the VM and returns it to the pool. The VM must not be used
+// after put.
+func (p *vmPool) put(v *VM) {
+	v.reset()
+	p.pool.Put(v)
+}
+
+// EnableOverflowCheck enables or disables overflow checking of integer
+// arithmetic. If enabled, int operations whose result does not fit in int64
+// fail with ErrIntegerOverflow instead of wrapping around.
+func (v *VM) EnableOverflowCheck(enable bool) {
+	v.overflowCheck = enable
+}
+
 // Abort aborts the execution.
 func (v *VM) Abort() {
 	atomic.StoreInt64(&v.aborting, 1)
@@ -73,29 +170,116 @@ func (v *VM) Run() (err error) {
 	v.framesIndex = 1
 	v.ip = -1
 	v.allocs = v.maxAllocs + 1
+	v.err = nil
 
 	v.run()
 	atomic.StoreInt64(&v.aborting, 0)
-	err = v.err
-	if err != nil {
-		filePos := v.fileSet.Position(
-			v.curFrame.fn.SourcePos(v.ip - 1))
-		err = fmt.Errorf("Runtime Error: %s\n\tat %s",
-			err.Error(), filePos)
-		for v.framesIndex > 1 {
-			v.framesIndex--
-			v.curFrame = &v.frames[v.framesIndex-1]
-			filePos = v.fileSet.Position(
-				v.curFrame.fn.SourcePos(v.curFrame.ip - 1))
-			err = fmt.Errorf("%s\n\tat %s", err.Error(), filePos)
-		}
-		return err
+	if v.err != nil {
+		return v.runtimeError(1)
 	}
 	return nil
 }
 
+// RunContext is like Run but stops the execution when the context is done,
+// and, returns the error of the context. The context is checked by the VM on
+// the caller's goroutine at the jumps and the function calls, and, it is
+// passed to the CallableContext objects.
+func (v *VM) RunContext(ctx context.Context) (err error) {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	v.setContext(ctx)
+	defer v.setContext(nil)
+	return v.contextError(v.Run())
+}
+
+// ctxCheckInterval is the number of the jumps and the calls between the
+// checks of the context. It must be a power of 2.
+const ctxCheckInterval = 128
+
+func (v *VM) setContext(ctx context.Context) {
+	v.ctx = ctx
+	v.ctxDone = nil
+	v.ctxTicks = 0
+	if ctx != nil {
+		v.ctxDone = ctx.Done()
+	}
+}
+
+// contextDone returns true if the context is done, and, sets the error of
+// the context as the runtime error.
+func (v *VM) contextDone() bool {
+	v.ctxTicks++
+	if v.ctxTicks&(ctxCheckInterval-1) != 0 {
+		return false
+	}
+	select {
+	case <-v.ctxDone:
+		v.err = v.ctx.Err()
+		return true
+	default:
+		return false
+	}
+}
+
+// contextError returns the error of the context instead of err if the
+// execution was stopped because the context is done.
+func (v *VM) contextError(err error) error {
+	if err != nil && v.ctx != nil && v.err != nil && v.err == v.ctx.Err() {
+		return v.err
+	}
+	return err
+}
+
+// runFunc calls the function fn with args instead of the main function, and,
+// returns the result.
+func (v *VM) runFunc(fn Object, args ...Object) (Object, error) {
+	// reset VM states
+	v.sp = 0
+	v.curFrame = &(v.frames[0])
+	v.curInsts = v.curFrame.fn.Instructions
+	v.framesIndex = 1
+	v.ip = -1
+	v.allocs = v.maxAllocs + 1
+	v.err = nil
+
+	res, ok := v.call(fn, args...)
+	aborted := atomic.SwapInt64(&v.aborting, 0) != 0
+	if !ok {
+		if v.err == nil && aborted {
+			return nil, errors.New("Runtime Error: aborted")
+		}
+		if v.framesIndex == 1 {
+			// not in the function
+			return nil, fmt.Errorf("Runtime Error: %s", v.err.Error())
+		}
+		return nil, v.runtimeError(2)
+	}
+	return res, nil
+}
+
+// runtimeError returns v.err with the source positions of the frames down to
+// the frame of index base.
+func (v *VM) runtimeError(base int) error {
+	filePos := v.fileSet.Position(
+		v.curFrame.fn.SourcePos(v.ip - 1))
+	err := fmt.Errorf("Runtime Error: %s\n\tat %s",
+		v.err.Error(), filePos)
+	for v.framesIndex > base {
+		v.framesIndex--
+		v.curFrame = &v.frames[v.framesIndex-1]
+		filePos = v.fileSet.Position(
+			v.curFrame.fn.SourcePos(v.curFrame.ip - 1))
+		err = fmt.Errorf("%s\n\tat %s", err.Error(), filePos)
+	}
+	return err
+}
+
 func (v *VM) run() {
 	for atomic.LoadInt64(&v.aborting) == 0 {
+		if v.sp > v.maxSP {
+			v.maxSP = v.sp
+		}
 		v.ip++
 
 		switch v.curInsts[v.ip] {
@@ -113,7 +297,25 @@ func (v *VM) run() {
 			right := v.stack[v.sp-1]
 			left := v.stack[v.sp-2]
 			tok := token.Token(v.curInsts[v.ip])
-			res, e := left.BinaryOp(tok, right)
+			if isOverloadable(left) || isOverloadable(right) {
+				res, ok := v.binaryOp(tok, left, right)
+				if !ok {
+					return
+				}
+				if res != nil {
+					v.stack[v.sp-2] = res
+					v.sp--
+					continue
+				}
+			}
+			var res Object
+			var e error
+			if v.overflowCheck {
+				res, e = checkedIntBinaryOp(tok, left, right)
+			}
+			if res == nil && e == nil {
+				res, e = left.BinaryOp(tok, right)
+			}
 			if e != nil {
 				v.sp -= 2
 				if e == ErrInvalidOperator {
@@ -136,8 +338,12 @@ func (v *VM) run() {
 		case parser.OpEqual:
 			right := v.stack[v.sp-1]
 			left := v.stack[v.sp-2]
+			eq, ok := v.equals(left, right)
+			if !ok {
+				return
+			}
 			v.sp -= 2
-			if left.Equals(right) {
+			if eq {
 				v.stack[v.sp] = TrueValue
 			} else {
 				v.stack[v.sp] = FalseValue
@@ -146,8 +352,12 @@ func (v *VM) run() {
 		case parser.OpNotEqual:
 			right := v.stack[v.sp-1]
 			left := v.stack[v.sp-2]
+			eq, ok := v.equals(left, right)
+			if !ok {
+				return
+			}
 			v.sp -= 2
-			if left.Equals(right) {
+			if eq {
 				v.stack[v.sp] = FalseValue
 			} else {
 				v.stack[v.sp] = TrueValue
@@ -184,6 +394,15 @@ func (v *VM) run() {
 				}
 				v.stack[v.sp] = res
 				v.sp++
+			case *BigInt:
+				var res Object = &BigInt{Value: new(big.Int).Not(x.Value)}
+				v.allocs--
+				if v.allocs == 0 {
+					v.err = ErrObjectAllocLimit
+					return
+				}
+				v.stack[v.sp] = res
+				v.sp++
 			default:
 				v.err = fmt.Errorf("invalid operation: ^%s",
 					operand.TypeName())
@@ -195,6 +414,10 @@ func (v *VM) run() {
 
 			switch x := operand.(type) {
 			case *Int:
+				if v.overflowCheck && x.Value == math.MinInt64 {
+					v.err = ErrIntegerOverflow
+					return
+				}
 				var res Object = &Int{Value: -x.Value}
 				v.allocs--
 				if v.allocs == 0 {
@@ -212,6 +435,27 @@ func (v *VM) run() {
 				}
 				v.stack[v.sp] = res
 				v.sp++
+			case *BigInt:
+				var res Object = &BigInt{Value: new(big.Int).Neg(x.Value)}
+				v.allocs--
+				if v.allocs == 0 {
+					v.err = ErrObjectAllocLimit
+					return
+				}
+				v.stack[v.sp] = res
+				v.sp++
+			case *Decimal:
+				var res Object = &Decimal{
+					Value: new(big.Int).Neg(x.Value),
+					Scale: x.Scale,
+				}
+				v.allocs--
+				if v.allocs == 0 {
+					v.err = ErrObjectAllocLimit
+					return
+				}
+				v.stack[v.sp] = res
+				v.sp++
 			default:
 				v.err = fmt.Errorf("invalid operation: -%s",
 					operand.TypeName())
@@ -240,7 +484,24 @@ func (v *VM) run() {
 				pos := int(v.curInsts[v.ip]) | int(v.curInsts[v.ip-1])<<8
 				v.ip = pos - 1
 			}
+		case parser.OpOptionalJump:
+			v.ip += 2
+			if v.stack[v.sp-1] == UndefinedValue {
+				pos := int(v.curInsts[v.ip]) | int(v.curInsts[v.ip-1])<<8
+				v.ip = pos - 1
+			}
+		case parser.OpCoalesceJump:
+			v.ip += 2
+			if v.stack[v.sp-1] == UndefinedValue {
+				v.sp--
+			} else {
+				pos := int(v.curInsts[v.ip]) | int(v.curInsts[v.ip-1])<<8
+				v.ip = pos - 1
+			}
 		case parser.OpJump:
+			if v.ctxDone != nil && v.contextDone() {
+				return
+			}
 			pos := int(v.curInsts[v.ip+2]) | int(v.curInsts[v.ip+1])<<8
 			v.ip = pos - 1
 		case parser.OpSetGlobal:
@@ -290,18 +551,31 @@ func (v *VM) run() {
 
 			v.stack[v.sp] = arr
 			v.sp++
+		case parser.OpAppend:
+			arr := v.stack[v.sp-2].(*Array)
+			arr.Value = append(arr.Value, v.stack[v.sp-1])
+			v.sp -= 2
+			v.allocs--
+			if v.allocs == 0 {
+				v.err = ErrObjectAllocLimit
+				return
+			}
 		case parser.OpMap:
 			v.ip += 2
 			numElements := int(v.curInsts[v.ip]) | int(v.curInsts[v.ip-1])<<8
 			kv := make(map[string]Object)
+			order := make([]mapKey, 0, numElements/2)
 			for i := v.sp - numElements; i < v.sp; i += 2 {
-				key := v.stack[i]
+				key := v.stack[i].(*String).Value
 				value := v.stack[i+1]
-				kv[key.(*String).Value] = value
+				if _, exists := kv[key]; !exists {
+					order = append(order, mapKey{key: key})
+				}
+				kv[key] = value
 			}
 			v.sp -= numElements
 
-			var m Object = &Map{Value: kv}
+			var m Object = &Map{Value: kv, order: order}
 			v.allocs--
 			if v.allocs == 0 {
 				v.err = ErrObjectAllocLimit
@@ -309,6 +583,35 @@ func (v *VM) run() {
 			}
 			v.stack[v.sp] = m
 			v.sp++
+		case parser.OpRecord:
+			v.ip += 4
+			numFields := int(v.curInsts[v.ip-2]) | int(v.curInsts[v.ip-3])<<8
+			numMethods := int(v.curInsts[v.ip]) | int(v.curInsts[v.ip-1])<<8
+			start := v.sp - 2*(numFields+numMethods)
+			rt := &RecordType{
+				Name:     v.stack[start-1].(*String).Value,
+				Fields:   make([]string, numFields),
+				Defaults: make([]Object, numFields),
+				Methods:  make(map[string]*CompiledFunction, numMethods),
+			}
+			for i := 0; i < numFields; i++ {
+				rt.Fields[i] = v.stack[start+2*i].(*String).Value
+				rt.Defaults[i] = v.stack[start+2*i+1]
+			}
+			for i := start + 2*numFields; i < v.sp; i += 2 {
+				key := v.stack[i].(*String).Value
+				rt.Methods[key] = v.stack[i+1].(*CompiledFunction)
+			}
+			v.sp = start - 1
+
+			var r Object = rt
+			v.allocs--
+			if v.allocs == 0 {
+				v.err = ErrObjectAllocLimit
+				return
+			}
+			v.stack[v.sp] = r
+			v.sp++
 		case parser.OpError:
 			value := v.stack[v.sp-1]
 			var e Object = &Error{
@@ -335,7 +638,9 @@ func (v *VM) run() {
 				v.stack[v.sp-1] = immutableArray
 			case *Map:
 				var immutableMap Object = &ImmutableMap{
-					Value: value.Value,
+					Value:  value.Value,
+					Hashed: value.Hashed,
+					order:  value.keys(),
 				}
 				v.allocs--
 				if v.allocs == 0 {
@@ -343,13 +648,34 @@ func (v *VM) run() {
 					return
 				}
 				v.stack[v.sp-1] = immutableMap
+			case *Set:
+				var immutableSet Object = &ImmutableSet{
+					setElems: newSetElems(value.Elements()),
+				}
+				v.allocs--
+				if v.allocs == 0 {
+					v.err = ErrObjectAllocLimit
+					return
+				}
+				v.stack[v.sp-1] = immutableSet
 			}
 		case parser.OpIndex:
 			index := v.stack[v.sp-1]
 			left := v.stack[v.sp-2]
-			v.sp -= 2
 
 			val, err := left.IndexGet(index)
+			if (err == ErrUnknownField || err == nil &&
+				(val == nil || val == UndefinedValue)) &&
+				isOverloadable(left) {
+				if fn := specialMethod(left, "__index"); fn != nil {
+					var ok bool
+					if val, ok = v.call(fn, left, index); !ok {
+						return
+					}
+					err = nil
+				}
+			}
+			v.sp -= 2
 			if err != nil {
 				if err == ErrNotIndexable {
 					v.err = fmt.Errorf("not indexable: %s", index.TypeName())
@@ -360,6 +686,11 @@ func (v *VM) run() {
 						index.TypeName())
 					return
 				}
+				if err == ErrUnknownField {
+					v.err = fmt.Errorf("unknown field %s in %s",
+						index.String(), left.TypeName())
+					return
+				}
 				v.err = err
 				return
 			}
@@ -534,11 +865,69 @@ func (v *VM) run() {
 				}
 				v.stack[v.sp] = val
 				v.sp++
+			default:
+				v.err = fmt.Errorf("not sliceable: %s", left.TypeName())
+				return
 			}
 		case parser.OpCall:
+			if v.ctxDone != nil && v.contextDone() {
+				return
+			}
 			numArgs := int(v.curInsts[v.ip+1])
 			v.ip++
 			value := v.stack[v.sp-1-numArgs]
+			var receiver Object
+			switch callee := value.(type) {
+			case *BoundMethod:
+				receiver, value = callee.Receiver, callee.Method
+			case *Map, *ImmutableMap, *Record:
+				if fn := specialMethod(callee, "__call"); fn != nil {
+					receiver, value = callee, fn
+				}
+			case *BuiltinFunction:
+				if callee == sortByFunc {
+					args := append([]Object{}, v.stack[v.sp-numArgs:v.sp]...)
+					res, ok := v.callSortBy(callee, args)
+					if !ok {
+						return
+					}
+					v.sp -= numArgs + 1
+					v.allocs--
+					if v.allocs == 0 {
+						v.err = ErrObjectAllocLimit
+						return
+					}
+					v.stack[v.sp] = res
+					v.sp++
+					continue
+				}
+				if callee == stringFunc && numArgs == 1 {
+					arg := v.stack[v.sp-1]
+					if fn := specialMethod(arg, "__string"); fn != nil {
+						res, ok := v.callString(fn, arg)
+						if !ok {
+							return
+						}
+						v.sp -= 2
+						v.stack[v.sp] = res
+						v.sp++
+						continue
+					}
+				}
+			}
+			if receiver != nil {
+				// insert the receiver as the first argument
+				if v.sp >= StackSize {
+					v.err = ErrStackOverflow
+					return
+				}
+				copy(v.stack[v.sp-numArgs+1:v.sp+1],
+					v.stack[v.sp-numArgs:v.sp])
+				v.stack[v.sp-numArgs] = receiver
+				v.stack[v.sp-numArgs-1] = value
+				v.sp++
+				numArgs++
+			}
 			if !value.CanCall() {
 				v.err = fmt.Errorf("not callable: %s", value.TypeName())
 				return
@@ -599,32 +988,23 @@ func (v *VM) run() {
 				v.curFrame.fn = callee
 				v.curFrame.freeVars = callee.Free
 				v.curFrame.basePointer = v.sp - numArgs
+				v.curFrame.nested = false
 				v.curInsts = callee.Instructions
 				v.ip = -1
 				v.framesIndex++
+				if v.framesIndex > v.maxFrames {
+					v.maxFrames = v.framesIndex
+				}
 				v.sp = v.sp - numArgs + callee.NumLocals
 			} else {
 				var args []Object
 				args = append(args, v.stack[v.sp-numArgs:v.sp]...)
-				ret, e := value.Call(args...)
+				ret, e := v.callValue(value, args)
 				v.sp -= numArgs + 1
 
 				// runtime error
 				if e != nil {
-					if e == ErrWrongNumArguments {
-						v.err = fmt.Errorf(
-							"wrong number of arguments in call to '%s'",
-							value.TypeName())
-						return
-					}
-					if e, ok := e.(ErrInvalidArgumentType); ok {
-						v.err = fmt.Errorf(
-							"invalid type for argument '%s' in call to '%s': "+
-								"expected %s, found %s",
-							e.Name, value.TypeName(), e.Expected, e.Found)
-						return
-					}
-					v.err = e
+					v.err = callError(value, e)
 					return
 				}
 
@@ -658,6 +1038,9 @@ func (v *VM) run() {
 			// skip stack overflow check because (newSP) <= (oldSP)
 			v.stack[v.sp-1] = retVal
 			//v.sp++
+			if v.frames[v.framesIndex].nested {
+				return
+			}
 		case parser.OpDefineLocal:
 			v.ip++
 			localIndex := int(v.curInsts[v.ip])
@@ -715,7 +1098,7 @@ func (v *VM) run() {
 		case parser.OpGetBuiltin:
 			v.ip++
 			builtinIndex := int(v.curInsts[v.ip])
-			v.stack[v.sp] = builtinFuncs[builtinIndex]
+			v.stack[v.sp] = v.builtins[builtinIndex]
 			v.sp++
 		case parser.OpClosure:
 			v.ip += 3
@@ -804,6 +1187,14 @@ func (v *VM) run() {
 		case parser.OpIteratorInit:
 			var iterator Object
 			dst := v.stack[v.sp-1]
+			if isOverloadable(dst) {
+				if fn := specialMethod(dst, "__iter"); fn != nil {
+					var ok bool
+					if dst, ok = v.call(fn, dst); !ok {
+						return
+					}
+				}
+			}
 			v.sp--
 			if !dst.CanIterate() {
 				v.err = fmt.Errorf("not iterable: %s", dst.TypeName())
@@ -848,11 +1239,344 @@ func (v *VM) run() {
 	}
 }
 
+// callValue calls the callable object fn other than the compiled functions.
+// CallableContext objects receive the context of the run, or,
+// context.Background if the VM is run without a context.
+func (v *VM) callValue(fn Object, args []Object) (Object, error) {
+	if fn, ok := fn.(CallableContext); ok {
+		ctx := v.ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		return fn.CallContext(ctx, args...)
+	}
+	return fn.Call(args...)
+}
+
 // IsStackEmpty tests if the stack is empty or not.
 func (v *VM) IsStackEmpty() bool {
 	return v.sp == 0
 }
 
+// call calls fn with args and returns the result. Compiled functions and
+// bound methods are executed in a nested run loop that stops when fn
+// returns. It returns false
+// if the execution was stopped by a runtime error or an abort.
+func (v *VM) call(fn Object, args ...Object) (Object, bool) {
+	if bm, ok := fn.(*BoundMethod); ok {
+		// the receiver is the first argument of the method
+		fn = bm.Method
+		args = append([]Object{bm.Receiver}, args...)
+	}
+	callee, ok := fn.(*CompiledFunction)
+	if !ok {
+		ret, err := v.callValue(fn, args)
+		if err != nil {
+			v.err = callError(fn, err)
+			return nil, false
+		}
+		if ret == nil {
+			ret = UndefinedValue
+		}
+		return ret, true
+	}
+
+	numArgs := len(args)
+	if callee.VarArgs && numArgs >= callee.NumParameters-1 {
+		realArgs := callee.NumParameters - 1
+		varArgs := make([]Object, numArgs-realArgs)
+		copy(varArgs, args[realArgs:])
+		args = append(args[:realArgs:realArgs], &Array{Value: varArgs})
+		numArgs = len(args)
+	}
+	if numArgs != callee.NumParameters {
+		if callee.VarArgs {
+			v.err = fmt.Errorf(
+				"wrong number of arguments: want>=%d, got=%d",
+				callee.NumParameters-1, numArgs)
+		} else {
+			v.err = fmt.Errorf(
+				"wrong number of arguments: want=%d, got=%d",
+				callee.NumParameters, numArgs)
+		}
+		return nil, false
+	}
+	if v.framesIndex >= MaxFrames ||
+		v.sp+1+callee.NumLocals >= StackSize {
+		v.err = ErrStackOverflow
+		return nil, false
+	}
+
+	v.stack[v.sp] = callee
+	v.sp++
+	copy(v.stack[v.sp:], args)
+
+	v.curFrame.ip = v.ip
+	v.curFrame = &(v.frames[v.framesIndex])
+	v.curFrame.fn = callee
+	v.curFrame.freeVars = callee.Free
+	v.curFrame.basePointer = v.sp
+	v.curFrame.nested = true
+	v.curInsts = callee.Instructions
+	v.ip = -1
+	v.framesIndex++
+	if v.framesIndex > v.maxFrames {
+		v.maxFrames = v.framesIndex
+	}
+	v.sp += callee.NumLocals
+
+	v.run()
+	if v.err != nil || atomic.LoadInt64(&v.aborting) != 0 {
+		return nil, false
+	}
+	v.sp--
+	return v.stack[v.sp], true
+}
+
+// callString calls the __string method fn of o.
+func (v *VM) callString(fn, o Object) (Object, bool) {
+	res, ok := v.call(fn, o)
+	if !ok {
+		return nil, false
+	}
+	if _, isString := res.(*String); !isString {
+		v.err = fmt.Errorf("invalid return type from __string: "+
+			"expected string, found %s", res.TypeName())
+		return nil, false
+	}
+	return res, true
+}
+
+// errNestedCall is returned by the function that sortBy uses to call the
+// comparison function when the call failed and v.err is already set.
+var errNestedCall = errors.New("nested call failed")
+
+// callSortBy calls the sort_by builtin function fn, calling the comparison
+// function in the VM so that it can be a compiled function.
+func (v *VM) callSortBy(fn *BuiltinFunction, args []Object) (Object, bool) {
+	res, err := sortBy(args, func(cmp Object, args ...Object) (Object, error) {
+		res, ok := v.call(cmp, args...)
+		if !ok {
+			return nil, errNestedCall
+		}
+		return res, nil
+	})
+	if err == errNestedCall {
+		return nil, false
+	}
+	if err != nil {
+		v.err = callError(fn, err)
+		return nil, false
+	}
+	return res, true
+}
+
+// binaryOp dispatches a binary operation to the special method of its
+// operands. It returns a nil result if neither operand overloads tok.
+func (v *VM) binaryOp(
+	tok token.Token,
+	left, right Object,
+) (Object, bool) {
+	name := binaryOpMethods[tok]
+	if fn := specialMethod(left, name); fn != nil {
+		return v.call(fn, left, right)
+	}
+	// comparisons are mirrored: a > b is the same as b < a
+	if mirrored, ok := mirroredComparisons[tok]; ok {
+		if fn := specialMethod(right, binaryOpMethods[mirrored]); fn != nil {
+			return v.call(fn, right, left)
+		}
+	}
+	if _, ok := left.(*String); ok && tok == token.Add {
+		if fn := specialMethod(right, "__string"); fn != nil {
+			str, ok := v.callString(fn, right)
+			if !ok {
+				return nil, false
+			}
+			return &String{Value: left.(*String).Value + str.(*String).Value},
+				true
+		}
+	}
+	return nil, true
+}
+
+// equals compares left and right using the __eq special method if either
+// of them defines it.
+func (v *VM) equals(left, right Object) (bool, bool) {
+	fn, recv, arg := specialMethod(left, "__eq"), left, right
+	if fn == nil {
+		fn, recv, arg = specialMethod(right, "__eq"), right, left
+	}
+	if fn == nil {
+		return left.Equals(right), true
+	}
+	res, ok := v.call(fn, recv, arg)
+	if !ok {
+		return false, false
+	}
+	return !res.IsFalsy(), true
+}
+
+var binaryOpMethods = map[token.Token]string{
+	token.Add:       "__add",
+	token.Sub:       "__sub",
+	token.Mul:       "__mul",
+	token.Quo:       "__div",
+	token.Rem:       "__rem",
+	token.And:       "__and",
+	token.Or:        "__or",
+	token.Xor:       "__xor",
+	token.Shl:       "__shl",
+	token.Shr:       "__shr",
+	token.AndNot:    "__and_not",
+	token.Less:      "__lt",
+	token.Greater:   "__gt",
+	token.LessEq:    "__le",
+	token.GreaterEq: "__ge",
+	token.Pow:       "__pow",
+}
+
+var mirroredComparisons = map[token.Token]token.Token{
+	token.Less:      token.Greater,
+	token.Greater:   token.Less,
+	token.LessEq:    token.GreaterEq,
+	token.GreaterEq: token.LessEq,
+}
+
+// isOverloadable returns true if o can define special methods.
+func isOverloadable(o Object) bool {
+	switch o.(type) {
+	case *Map, *ImmutableMap, *Record:
+		return true
+	}
+	return false
+}
+
+// specialMethod returns the special method called name of a map or a
+// record, or nil if o does not define it. Map methods are callable values
+// stored under the name.
+func specialMethod(o Object, name string) Object {
+	switch o := o.(type) {
+	case *Map:
+		if fn, ok := o.Value[name]; ok && fn.CanCall() {
+			return fn
+		}
+	case *ImmutableMap:
+		if fn, ok := o.Value[name]; ok && fn.CanCall() {
+			return fn
+		}
+	case *Record:
+		if fn, ok := o.Type.Methods[name]; ok {
+			return fn
+		}
+	}
+	return nil
+}
+
+// checkedIntBinaryOp performs an arithmetic operation on two ints, returning
+// ErrIntegerOverflow if the result does not fit in int64. It returns a nil
+// result for other operands and operators.
+func checkedIntBinaryOp(
+	tok token.Token,
+	left, right Object,
+) (Object, error) {
+	l, ok := left.(*Int)
+	if !ok {
+		return nil, nil
+	}
+	r, ok := right.(*Int)
+	if !ok {
+		return nil, nil
+	}
+
+	x, y := l.Value, r.Value
+	var res int64
+	switch tok {
+	case token.Add:
+		res = x + y
+		if (res > x) != (y > 0) {
+			return nil, ErrIntegerOverflow
+		}
+	case token.Sub:
+		res = x - y
+		if (res < x) != (y > 0) {
+			return nil, ErrIntegerOverflow
+		}
+	case token.Mul:
+		if res, ok = checkedMul(x, y); !ok {
+			return nil, ErrIntegerOverflow
+		}
+	case token.Quo:
+		if y == 0 {
+			return nil, ErrDivisionByZero
+		}
+		if x == math.MinInt64 && y == -1 {
+			return nil, ErrIntegerOverflow
+		}
+		res = x / y
+	case token.Shl:
+		if y < 0 {
+			return nil, nil
+		}
+		if y >= 64 {
+			if x != 0 {
+				return nil, ErrIntegerOverflow
+			}
+			break
+		}
+		res = x << uint64(y)
+		if res>>uint64(y) != x {
+			return nil, ErrIntegerOverflow
+		}
+	case token.Pow:
+		if y < 0 {
+			return nil, nil
+		}
+		res = 1
+		for ; y > 0; y >>= 1 {
+			if y&1 == 1 {
+				if res, ok = checkedMul(res, x); !ok {
+					return nil, ErrIntegerOverflow
+				}
+			}
+			if y > 1 {
+				if x, ok = checkedMul(x, x); !ok {
+					return nil, ErrIntegerOverflow
+				}
+			}
+		}
+	default:
+		return nil, nil
+	}
+	return &Int{Value: res}, nil
+}
+
+// checkedMul returns x*y and false if the multiplication overflows.
+func checkedMul(x, y int64) (int64, bool) {
+	if x == 0 || y == 0 {
+		return 0, true
+	}
+	res := x * y
+	if res/y != x || (x == -1 && y == math.MinInt64) ||
+		(y == -1 && x == math.MinInt64) {
+		return res, false
+	}
+	return res, true
+}
+
+func callError(fn Object, err error) error {
+	if err == ErrWrongNumArguments {
+		return fmt.Errorf("wrong number of arguments in call to '%s'",
+			fn.TypeName())
+	}
+	if err, ok := err.(ErrInvalidArgumentType); ok {
+		return fmt.Errorf("invalid type for argument '%s' in call to '%s': "+
+			"expected %s, found %s",
+			err.Name, fn.TypeName(), err.Expected, err.Found)
+	}
+	return err
+}
+
 func indexAssign(dst, src Object, selectors []Object) error {
 	numSel := len(selectors)
 	for sidx := numSel - 1; sidx > 0; sidx-- {
@@ -865,6 +1589,10 @@ func indexAssign(dst, src Object, selectors []Object) error {
 				return fmt.Errorf("invalid index type: %s",
 					selectors[sidx].TypeName())
 			}
+			if err == ErrUnknownField {
+				return fmt.Errorf("unknown field %s in %s",
+					selectors[sidx].String(), dst.TypeName())
+			}
 			return err
 		}
 		dst = next
@@ -874,9 +1602,17 @@ func indexAssign(dst, src Object, selectors []Object) error {
 		if err == ErrNotIndexAssignable {
 			return fmt.Errorf("not index-assignable: %s", dst.TypeName())
 		}
+		if err == ErrInvalidIndexType {
+			return fmt.Errorf("invalid index type: %s",
+				selectors[0].TypeName())
+		}
 		if err == ErrInvalidIndexValueType {
 			return fmt.Errorf("invaid index value type: %s", src.TypeName())
 		}
+		if err == ErrUnknownField {
+			return fmt.Errorf("unknown field %s in %s",
+				selectors[0].String(), dst.TypeName())
+		}
 		return err
 	}
 	return nil
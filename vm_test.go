@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"math/big"
 	"math/rand"
 	"reflect"
 	_runtime "runtime"
@@ -435,6 +436,51 @@ a := {
 a.x.e = "bar"`, nil, "not index-assignable")
 }
 
+func TestBigInt(t *testing.T) {
+	huge, _ := new(big.Int).SetString("1267650600228229401496703205376", 10)
+	bigInt := func(v int64) *tengo.BigInt {
+		return &tengo.BigInt{Value: big.NewInt(v)}
+	}
+
+	expectRun(t, `out = bigint(5)`, nil, bigInt(5))
+	expectRun(t, `out = bigint("-12")`, nil, bigInt(-12))
+	expectRun(t, `out = bigint(3.9)`, nil, bigInt(3))
+	expectRun(t, `out = bigint(decimal("-3.9"))`, nil, bigInt(-3))
+	expectRun(t, `out = bigint(true)`, nil, bigInt(1))
+	expectRun(t, `out = bigint("1.5")`, nil, tengo.UndefinedValue)
+	expectRun(t, `out = bigint("1.5", 0)`, nil, 0)
+	expectRun(t, `out = bigint(2) ** 100`, nil, &tengo.BigInt{Value: huge})
+	expectRun(t, `out = string(bigint(1) << 100)`, nil,
+		"1267650600228229401496703205376")
+	expectRun(t, `out = 1 + bigint(2) * 3`, nil, bigInt(7))
+	expectRun(t, `out = -bigint(2)`, nil, bigInt(-2))
+	expectRun(t, `out = ^bigint(2)`, nil, bigInt(-3))
+	expectRun(t, `out = bigint(2); out += 3; out *= 2`, nil, bigInt(10))
+	expectRun(t, `out = bigint(2) + 0.5`, nil, 2.5)
+	expectRun(t, `out = bigint(3) > 2 && 2 < bigint(3)`, nil, true)
+	expectRun(t, `out = bigint(3) == bigint(3)`, nil, true)
+	expectRun(t, `out = bigint(3) == 3`, nil, false)
+	expectRun(t, `out = int(bigint(42))`, nil, 42)
+	expectRun(t, `out = int(bigint(2) ** 64)`, nil, tengo.UndefinedValue)
+	expectRun(t, `out = float(bigint(2) ** 64)`, nil, 18446744073709551616.0)
+	expectRun(t, `out = is_int(bigint(1))`, nil, false)
+	expectRun(t, `out = type_name(bigint(1))`, nil, "bigint")
+	expectRun(t, `out = bigint(0) ? 1 : 2`, nil, 2)
+
+	expectError(t, `bigint(1) / 0`, nil, "Runtime Error: division by zero")
+	expectError(t, `bigint(2) ** 1000000000`, nil,
+		"Runtime Error: exceeding bigint size limit")
+	expectError(t, `decimal("1.1") ** 1000000000`, nil,
+		"Runtime Error: exceeding bigint size limit")
+	expectRun(t, `out = (bigint(2) ** 1000000) >> 999999`, nil, bigInt(2))
+	expectError(t, `x := bigint(3); for i := 0; i < 30; i++ { x = x * x }`,
+		nil, "Runtime Error: exceeding bigint size limit")
+	expectError(t, `x := decimal("1.3"); for i := 0; i < 30; i++ { x = x * x }`,
+		nil, "Runtime Error: exceeding bigint size limit")
+	expectError(t, `bigint(1) + "1"`, nil,
+		"Runtime Error: invalid operation: bigint + string")
+}
+
 func TestBitwise(t *testing.T) {
 	expectRun(t, `out = 1 & 1`, nil, 1)
 	expectRun(t, `out = 1 & 0`, nil, 0)
@@ -708,6 +754,14 @@ func TestBuiltinFunction(t *testing.T) {
 		nil, `foo {a: {b: {c: [1, 2, 3]}}}`)
 	expectRun(t, `out = format("%v", [1, [2, [3, 4]]])`,
 		nil, `[1, [2, [3, 4]]]`)
+	expectRun(t, `out = format("%d %x %5s", bigint(2) ** 70, bigint(255), bigint(7))`,
+		nil, `1180591620717411303424 ff     7`)
+	expectRun(t, `out = format("%v %s %.2f %8.3f", decimal("1.50"), decimal("-2"), decimal("2.675"), decimal("-1.5"))`,
+		nil, `1.50 -2 2.68   -1.500`)
+	expectRun(t, `out = format("%f %e", decimal("0.1"), decimal("12345.678"))`,
+		nil, `0.100000 1.234568e+04`)
+	expectRun(t, `out = format("%d", decimal("1"))`,
+		nil, `%!d(1=1)`)
 
 	tengo.MaxStringLen = 9
 	expectError(t, `format("%s", "1234567890")`,
@@ -715,6 +769,163 @@ func TestBuiltinFunction(t *testing.T) {
 	tengo.MaxStringLen = 2147483647
 }
 
+func TestBuiltinCollectionFunctions(t *testing.T) {
+	// delete
+	expectRun(t, `out = {a: 1, b: 2}; delete(out, "a")`, nil, MAP{"b": 2})
+	expectRun(t, `out = {a: 1}; delete(out, "b")`, nil, MAP{"a": 1})
+	expectRun(t, `m := {a: 1}; m[1] = 2; delete(m, 1); out = len(m)`, nil, 1)
+	expectRun(t, `m := {a: 1, b: 2}; delete(m, "a"); m.a = 3; out = string(m)`,
+		nil, "{b: 2, a: 3}")
+	expectRun(t, `out = set(1, 2); delete(out, 1); out = string(out)`,
+		nil, "set(2)")
+	expectRun(t, `out = delete({}, "a")`, nil, tengo.UndefinedValue)
+	expectRun(t, `m := {a: 1, b: 2, c: 3}; out = ""; for k, _ in m { delete(m, "b"); out += k }`,
+		nil, "ac")
+	expectError(t, `delete({})`, nil, "wrong number of arguments")
+	expectError(t, `delete({}, [1])`, nil,
+		"invalid type for argument 'second'")
+	expectError(t, `delete(immutable({a: 1}), "a")`, nil,
+		"invalid type for argument 'first'")
+
+	// splice
+	expectRun(t, `v := [1, 2, 3]; out = [splice(v, 0), v]`, nil,
+		ARR{ARR{1, 2, 3}, ARR{}})
+	expectRun(t, `v := [1, 2, 3]; out = [splice(v, 1), v]`, nil,
+		ARR{ARR{2, 3}, ARR{1}})
+	expectRun(t, `v := [1, 2, 3]; out = [splice(v, 0, 1), v]`, nil,
+		ARR{ARR{1}, ARR{2, 3}})
+	expectRun(t, `v := [1, 2, 3]; out = [splice(v, 1, 5), v]`, nil,
+		ARR{ARR{2, 3}, ARR{1}})
+	expectRun(t, `v := ["a", "b", "c"]; out = [splice(v, 1, 0, "d", "e"), v]`,
+		nil, ARR{ARR{}, ARR{"a", "d", "e", "b", "c"}})
+	expectRun(t, `v := ["a", "b", "c"]; out = [splice(v, 1, 1, "d", "e"), v]`,
+		nil, ARR{ARR{"b"}, ARR{"a", "d", "e", "c"}})
+	expectRun(t, `v := [1, 2]; out = [splice(v, 2, 0, 3), v]`, nil,
+		ARR{ARR{}, ARR{1, 2, 3}})
+	expectError(t, `splice([1, 2], 3)`, nil, "index out of bounds")
+	expectError(t, `splice([1, 2], -1)`, nil, "index out of bounds")
+	expectError(t, `splice([1, 2], 0, -1)`, nil, "index out of bounds")
+	expectError(t, `splice(immutable([1, 2]), 0)`, nil,
+		"invalid type for argument 'first'")
+	expectError(t, `splice([1, 2], "0")`, nil,
+		"invalid type for argument 'second'")
+
+	// keys and values
+	expectRun(t, `out = keys({b: 1, a: 2})`, nil, ARR{"b", "a"})
+	expectRun(t, `out = values({b: 1, a: 2})`, nil, ARR{1, 2})
+	expectRun(t, `out = keys(immutable({b: 1, a: 2}))`, nil, ARR{"b", "a"})
+	expectRun(t, `m := {}; m[1] = "a"; out = keys(m)`, nil, ARR{1})
+	expectRun(t, `out = keys({})`, nil, ARR{})
+	expectError(t, `keys([1])`, nil, "invalid type for argument 'first'")
+
+	// contains
+	expectRun(t, `out = contains([1, "a"], "a")`, nil, true)
+	expectRun(t, `out = contains(immutable([1, "a"]), 2)`, nil, false)
+	expectRun(t, `out = contains([[1]], [1])`, nil, true)
+	expectRun(t, `out = contains("hello", "ell")`, nil, true)
+	expectRun(t, `out = contains("hello", 'z')`, nil, false)
+	expectRun(t, `out = contains({a: 1}, "a")`, nil, true)
+	expectRun(t, `out = contains(set(1), 1)`, nil, true)
+	expectRun(t, `out = contains(range(0, 10, 3), 9)`, nil, true)
+	expectRun(t, `out = contains(range(0, 10, 3), 10)`, nil, false)
+	expectRun(t, `out = contains(range(10, 0, -3), 1)`, nil, true)
+	expectRun(t, `out = contains(range(10, 0, -3), 0)`, nil, false)
+	expectError(t, `contains("a", 1)`, nil,
+		"invalid type for argument 'second'")
+	expectError(t, `contains(1, 1)`, nil, "invalid type for argument 'first'")
+
+	// range
+	expectRun(t, `out = [x for x in range(3)]`, nil, ARR{0, 1, 2})
+	expectRun(t, `out = [x for x in range(2, 5)]`, nil, ARR{2, 3, 4})
+	expectRun(t, `out = [x for x in range(0, 10, 4)]`, nil, ARR{0, 4, 8})
+	expectRun(t, `out = [x for x in range(3, 0, -1)]`, nil, ARR{3, 2, 1})
+	expectRun(t, `out = [x for x in range(3, 5, -1)]`, nil, ARR{})
+	expectRun(t, `out = 0; for i, x in range(5, 8) { out += i * x }`, nil, 20)
+	expectRun(t, `out = len(range(0, 10, 3))`, nil, 4)
+	expectRun(t, `out = len(range(-9223372036854775808, 9223372036854775807, 4611686018427387904))`,
+		nil, 4)
+	expectRun(t, `out = range(0, 10, 3)[3]`, nil, 9)
+	expectRun(t, `out = range(0, 10, 3)[4]`, nil, tengo.UndefinedValue)
+	expectRun(t, `out = string(range(1, 3))`, nil, "range(1, 3, 1)")
+	expectRun(t, `out = range(0) ? 1 : 2`, nil, 2)
+	expectRun(t, `out = range(0, 3) == range(0, 3, 1)`, nil, true)
+	expectRun(t, `out = range(1, 2, 5) == range(1, 2, 1)`, nil, true)
+	expectRun(t, `out = range(0, 3) == range(0, 4)`, nil, false)
+	expectRun(t, `out = is_iterable(range(1))`, nil, true)
+	expectError(t, `range(0, 10, 0)`, nil, "range step cannot be zero")
+	expectError(t, `range(1.5)`, nil, "invalid type for argument 'first'")
+	expectError(t, `range()`, nil, "wrong number of arguments")
+
+	// sort and sort_by
+	expectRun(t, `out = sort([3, 1, 2])`, nil, ARR{1, 2, 3})
+	expectRun(t, `a := [3, 1, 2]; b := sort(a); out = a`, nil, ARR{3, 1, 2})
+	expectRun(t, `out = sort(immutable(["b", "c", "a"]))`, nil,
+		ARR{"a", "b", "c"})
+	expectRun(t, `out = sort([2.5, 1, decimal("1.5")])`, nil,
+		ARR{1, tengo.Object(&tengo.Decimal{Value: big.NewInt(15), Scale: 1}), 2.5})
+	expectRun(t, `out = sort([])`, nil, ARR{})
+	expectError(t, `sort([1, "a"])`, nil, "invalid operation: string < int")
+	expectError(t, `sort({})`, nil, "invalid type for argument 'first'")
+	expectRun(t, `out = sort_by([1, 3, 2], func(a, b) { return a > b })`,
+		nil, ARR{3, 2, 1})
+	expectRun(t, `
+people := [{name: "b", age: 2}, {name: "a", age: 1}, {name: "c", age: 2}]
+out = [p.name for p in sort_by(people, func(x, y) { return x.age < y.age })]`,
+		nil, ARR{"a", "b", "c"})
+	expectRun(t, `f := func() { return sort_by([1, 3, 2], func(a, b) { return a > b }) }; out = f()`,
+		nil, ARR{3, 2, 1})
+	expectRun(t, `
+desc := func(a, b) { return a > b }
+out = sort_by([[2, 1], [4, 3]], func(a, b) { return sort_by(a, desc)[0] < sort_by(b, desc)[0] })`,
+		nil, ARR{ARR{2, 1}, ARR{4, 3}})
+	expectRun(t, `out = sort_by(["bb", "a"], func(a, b) { return len(a) < len(b) })`,
+		nil, ARR{"a", "bb"})
+	expectError(t, `sort_by([1, 2], func(a, b) { return a.x })`, nil,
+		"not indexable")
+	expectError(t, `sort_by([1, 2], func(a) { return true })`, nil,
+		"wrong number of arguments: want=1, got=2")
+	expectError(t, `sort_by([1, 2], 1)`, nil,
+		"invalid type for argument 'second'")
+	expectError(t, `sort_by([1, 2])`, nil, "wrong number of arguments")
+	// sort_by called by a Go function cannot call compiled functions
+	callGo := &tengo.UserFunction{
+		Value: func(args ...tengo.Object) (tengo.Object, error) {
+			return args[0].Call(args[1:]...)
+		},
+	}
+	expectError(t, `call_go(sort_by, [1, 2], func(a, b) { return a > b })`,
+		Opts().Symbol("call_go", callGo).Skip2ndPass(),
+		"compiled function cannot be called outside the VM")
+	expectError(t, `
+Cmp := record { less: func(self, a, b) { return a > b } }
+call_go(sort_by, [1, 2], Cmp().less)`,
+		Opts().Symbol("call_go", callGo).Skip2ndPass(),
+		"compiled function cannot be called outside the VM")
+
+	// reverse
+	expectRun(t, `out = reverse([1, 2, 3])`, nil, ARR{3, 2, 1})
+	expectRun(t, `out = reverse(immutable([1, 2]))`, nil, ARR{2, 1})
+	expectRun(t, `out = reverse("héllo")`, nil, "olléh")
+	expectRun(t, `out = reverse(bytes("ab"))`, nil, []byte("ba"))
+	expectError(t, `reverse(1)`, nil, "invalid type for argument 'first'")
+
+	// min and max
+	expectRun(t, `out = min(3, 1, 2)`, nil, 1)
+	expectRun(t, `out = max(3, 1, 2)`, nil, 3)
+	expectRun(t, `out = min([2.5, 1, 3])`, nil, 1)
+	expectRun(t, `out = max(immutable([2.5, 1, 3]))`, nil, 3)
+	expectRun(t, `out = min(["b", "a"])`, nil, "a")
+	expectRun(t, `out = max(set(1, 5, 2))`, nil, 5)
+	expectRun(t, `out = min(range(10, 0, -3))`, nil, 1)
+	expectRun(t, `out = max(range(10, 0, -3))`, nil, 10)
+	expectRun(t, `out = max(range(0, 10, 3))`, nil, 9)
+	expectRun(t, `out = min([])`, nil, tengo.UndefinedValue)
+	expectRun(t, `out = max(range(0))`, nil, tengo.UndefinedValue)
+	expectError(t, `max(1)`, nil, "invalid type for argument 'first'")
+	expectError(t, `min()`, nil, "wrong number of arguments")
+	expectError(t, `min(1, "a")`, nil, "invalid operation: string < int")
+}
+
 func TestBytesN(t *testing.T) {
 	curMaxBytesLen := tengo.MaxBytesLen
 	defer func() { tengo.MaxBytesLen = curMaxBytesLen }()
@@ -780,6 +991,76 @@ func TestChar(t *testing.T) {
 	expectRun(t, `out = '4' >= '4'`, nil, true)
 }
 
+func TestCoalescing(t *testing.T) {
+	expectRun(t, `out = undefined ?? 1`, nil, 1)
+	expectRun(t, `out = 0 ?? 1`, nil, 0)
+	expectRun(t, `out = false ?? 1`, nil, false)
+	expectRun(t, `out = "" ?? 1`, nil, "")
+	expectRun(t, `out = undefined ?? undefined ?? 3`, nil, 3)
+	expectRun(t, `out = {a: 1}.b ?? {a: 2}.a`, nil, 2)
+	expectRun(t, `out = undefined ?? false || true`, nil, true)
+	expectRun(t, `out = 1 + 2 ?? 4`, nil, 3)
+
+	// short-circuit evaluation
+	expectRun(t, `
+out = 0
+f := func() { out++; return 5 }
+a := 1 ?? f()
+b := undefined ?? f()
+out = [out, a, b]`, nil, ARR{1, 1, 5})
+
+	expectRun(t, `
+f := func(x) { return x ?? "default" }
+out = [f(1), f(undefined), f(false)]`,
+		nil, ARR{1, "default", false})
+}
+
+func TestComprehension(t *testing.T) {
+	// arrays
+	expectRun(t, `out = [x * 2 for x in [1, -2, 3] if x > 0]`, nil, ARR{2, 6})
+	expectRun(t, `out = [i for i, _ in [5, 6, 7]]`, nil, ARR{0, 1, 2})
+	expectRun(t, `out = [x for x in []]`, nil, ARR{})
+	expectRun(t, `out = [x for x in immutable([1, 2])]`, nil, ARR{1, 2})
+	expectRun(t, `out = [c for c in "ab"]`, nil, ARR{'a', 'b'})
+	expectRun(t, `out = [k for k, _ in {a: 1}]`, nil, ARR{"a"})
+	expectRun(t, `out = [x * y for x in [1, 2] for y in [10, 100] if y > x * 10]`,
+		nil, ARR{100, 200})
+	expectRun(t, `out = [[y + 1 for y in x] for x in [[1], [2, 3]]]`,
+		nil, ARR{ARR{2}, ARR{3, 4}})
+
+	// maps
+	expectRun(t, `out = {k: v * 10 for k, v in {a: 1, b: 2}}`,
+		nil, MAP{"a": 10, "b": 20})
+	expectRun(t, `out = {k: v for k, v in {a: 1, b: 2} if v > 1}`,
+		nil, MAP{"b": 2})
+	expectRun(t, `out = {(k + "_x"): i for i, k in ["a", "b"]}`,
+		nil, MAP{"a_x": 0, "b_x": 1})
+	expectRun(t, `out = {f"k${v}": v for v in [1, 2]}`,
+		nil, MAP{"k1": 1, "k2": 2})
+	expectRun(t, `out = {"a": v for v in [1, 2]}`, nil, MAP{"a": 2})
+
+	// scopes
+	expectRun(t, `
+f := func(a, n) { return [x + n for x in a if x != n] }
+out = f([1, 2, 3], 2)`, nil, ARR{3, 5})
+	expectRun(t, `x := 5; a := [x for x in [1, 2]]; out = [x, a]`,
+		nil, ARR{5, ARR{1, 2}})
+	expectRun(t, `
+out = 0
+for i := 0; i < 3; i++ { out += len([x for x in [1, 2, 3] if x > i]) }`,
+		nil, 6)
+	expectError(t, `[x for x in [1]]; y := x`, nil, "unresolved reference 'x'")
+	expectError(t, `[y for x in [1]]`, nil, "unresolved reference 'y'")
+
+	// errors
+	expectError(t, `[x for x in 1]`, nil, "not iterable: int")
+	expectError(t, `{k: v for k, v in 1}`, nil, "not iterable: int")
+
+	// allocation limit
+	expectError(t, `[x for x in [1, 2, 3, 4, 5]]`,
+		Opts().MaxAllocs(5).Skip2ndPass(), "allocation limit exceeded")
+}
+
 func TestCondExpr(t *testing.T) {
 	expectRun(t, `out = true ? 5 : 10`, nil, 5)
 	expectRun(t, `out = false ? 5 : 10`, nil, 10)
@@ -822,6 +1103,130 @@ out = 1 > 2 ?
 	10 - 5`, nil, 5)
 }
 
+func TestDecimal(t *testing.T) {
+	dec := func(s string) *tengo.Decimal {
+		d, _ := tengo.ParseDecimal(s)
+		return d
+	}
+
+	expectRun(t, `out = decimal("12.50")`, nil, dec("12.50"))
+	expectRun(t, `out = decimal(5)`, nil, dec("5"))
+	expectRun(t, `out = decimal(0.1)`, nil, dec("0.1"))
+	expectRun(t, `out = decimal(bigint(7))`, nil, dec("7"))
+	expectRun(t, `out = decimal("abc")`, nil, tengo.UndefinedValue)
+	expectRun(t, `out = decimal("abc", 0)`, nil, 0)
+	expectRun(t, `out = decimal("0.1") + decimal("0.2")`, nil, dec("0.3"))
+	expectRun(t, `out = decimal("0.1") + decimal("0.2") == decimal("0.3")`,
+		nil, true)
+	expectRun(t, `out = decimal("19.99") * 3`, nil, dec("59.97"))
+	expectRun(t, `out = decimal("100.00") / 3`, nil, dec("33.3333333333333333"))
+	expectRun(t, `out = decimal("100.00") / 4`, nil, dec("25.00"))
+	expectRun(t, `out = 1 - decimal("0.25")`, nil, dec("0.75"))
+	expectRun(t, `out = 0.5 * decimal("3")`, nil, dec("1.5"))
+	expectRun(t, `out = decimal("1.5") ** 2`, nil, dec("2.25"))
+	expectRun(t, `out = -decimal("1.50")`, nil, dec("-1.50"))
+	expectRun(t, `out = decimal("10"); out /= 4; out -= 0.5`, nil, dec("2.0"))
+	expectRun(t, `out = decimal("1.5") < 2 && 1 < decimal("1.5")`, nil, true)
+	expectRun(t, `out = decimal("1.0") == decimal("1")`, nil, true)
+	expectRun(t, `out = decimal("1") == 1`, nil, false)
+	expectRun(t, `out = string(decimal("-0.05"))`, nil, "-0.05")
+	expectRun(t, `out = int(decimal("-7.9"))`, nil, -7)
+	expectRun(t, `out = float(decimal("0.5"))`, nil, 0.5)
+	expectRun(t, `out = type_name(decimal(1))`, nil, "decimal")
+	expectRun(t, `out = decimal("0.00") ? 1 : 2`, nil, 2)
+
+	expectError(t, `decimal(1) / 0`, nil, "Runtime Error: division by zero")
+	expectError(t, `decimal(1) ** 0.5`, nil,
+		"Runtime Error: invalid operation: decimal ** float")
+	expectError(t, `^decimal(1)`, nil,
+		"Runtime Error: invalid operation: ^decimal")
+}
+
+func TestDestructuring(t *testing.T) {
+	// arrays
+	expectRun(t, `[a, b] := [1, 2]; out = a + b`, nil, 3)
+	expectRun(t, `[a, b] := immutable([1, 2]); out = [b, a]`,
+		nil, ARR{2, 1})
+	expectRun(t, `[a, b, c] := [1, 2]; out = c`, nil, tengo.UndefinedValue)
+	expectRun(t, `[a] := [1, 2, 3]; out = a`, nil, 1)
+	expectRun(t, `[_, b, _] := [1, 2, 3]; out = b`, nil, 2)
+	expectRun(t, `[a, ...b] := [1, 2, 3]; out = b`, nil, ARR{2, 3})
+	expectRun(t, `[a, b, ...c] := [1]; out = c`, nil, ARR{})
+	expectRun(t, `[...a] := immutable([1, 2]); out = a`, nil, ARR{1, 2})
+	expectRun(t, `[a, b] := []; out = [a, b]`,
+		nil, ARR{tengo.UndefinedValue, tengo.UndefinedValue})
+
+	// maps
+	expectRun(t, `{a, b} := {a: 1, b: 2}; out = a + b`, nil, 3)
+	expectRun(t, `{a, b} := immutable({a: 1, b: 2}); out = a + b`, nil, 3)
+	expectRun(t, `{a: x, b: y} := {a: 1, b: 2}; out = [x, y]`,
+		nil, ARR{1, 2})
+	expectRun(t, `{a, c} := {a: 1, b: 2}; out = c`,
+		nil, tengo.UndefinedValue)
+	expectRun(t, `{name, age} := {name: "foo", age: 10}; out = name + age`,
+		nil, "foo10")
+
+	// nested patterns
+	expectRun(t, `[a, [b, c]] := [1, [2, 3]]; out = a + b + c`, nil, 6)
+	expectRun(t, `{a: [x, y], b: {c}} := {a: [1, 2], b: {c: 3}}
+out = x + y + c`, nil, 6)
+	expectRun(t, `[{a}, {a: b}] := [{a: 1}, {a: 2}]; out = [a, b]`,
+		nil, ARR{1, 2})
+
+	// assignment to existing variables
+	expectRun(t, `a := 1; b := 2; [a, b] = [b, a]; out = [a, b]`,
+		nil, ARR{2, 1})
+	expectRun(t, `m := {}; {a: m.x, b: m.y} = {a: 1, b: 2}; out = m`,
+		nil, MAP{"x": 1, "y": 2})
+	expectRun(t, `m := [0, 0]; [m[1], m[0]] = [1, 2]; out = m`,
+		nil, ARR{2, 1})
+	expectRun(t, `
+a := 1
+f := func() {
+	[a, b] := [2, 3]
+	return a + b
+}
+out = [f(), a]`, nil, ARR{5, 1})
+	expectRun(t, `
+a := 1
+b := 0
+f := func() {
+	[a, b] = [2, 3]
+}
+f()
+out = a + b`, nil, 5)
+	expectRun(t, `
+f := func(x) {
+	[a, ...rest] := x
+	return func() { return [a, rest] }
+}
+out = f([1, 2, 3])()`, nil, ARR{1, ARR{2, 3}})
+	expectRun(t, `
+out = 0
+for p in [[1, 2], [3, 4]] {
+	[a, b] := p
+	out += a * b
+}`, nil, 14)
+
+	// errors
+	expectError(t, `[a, b] := 1`, nil, "not indexable: int")
+	expectError(t, `[a, ...b] := {}`, nil, "not sliceable: map")
+	expectError(t, `a := 1; [a, b] := [1, 2]`, nil,
+		"'a' redeclared in this block")
+	expectError(t, `[a, a] := [1, 2]`, nil,
+		"'a' redeclared in this block")
+	expectError(t, `[a, b] = [1, 2]`, nil, "unresolved reference 'a'")
+	expectError(t, `[...a, b] := [1, 2]`, nil,
+		"rest element must be last in destructuring")
+	expectError(t, `m := {}; [m.a] := [1]`, nil,
+		"operator ':=' not allowed with selector")
+	expectError(t, `[1, 2] := [1, 2]`, nil, "invalid destructuring target")
+	expectError(t, `[a, b] += [1, 2]`, nil,
+		"operator '+=' not allowed with destructuring")
+	expectError(t, `a := [...b]`, nil,
+		"'...' not allowed outside destructuring assignment")
+}
+
 func TestEquality(t *testing.T) {
 	testEquality(t, `1`, `1`, true)
 	testEquality(t, `1`, `2`, false)
@@ -936,6 +1341,12 @@ func TestFloat(t *testing.T) {
 	expectRun(t, `out = 2.3 + 4`, nil, 6.3)
 	expectRun(t, `out = +5.0`, nil, 5.0)
 	expectRun(t, `out = -5.0 + +5.0`, nil, 0.0)
+	expectRun(t, `out = 2.0 ** 3`, nil, 8.0)
+	expectRun(t, `out = 4 ** 0.5`, nil, 2.0)
+	expectRun(t, `out = 0.5; out **= 2.0`, nil, 0.25)
+
+	expectError(t, `1.0 / 0`, nil, "Runtime Error: division by zero")
+	expectError(t, `1 / 0.0`, nil, "Runtime Error: division by zero")
 }
 
 func TestForIn(t *testing.T) {
@@ -1203,6 +1614,66 @@ func TestFor(t *testing.T) {
 	}`, nil, 12) // 1 + 2 + 4 + 5
 }
 
+func TestForLabels(t *testing.T) {
+	expectRun(t, `
+out = []
+outer: for i := 0; i < 3; i++ {
+	for j := 0; j < 3; j++ {
+		if j == 1 { continue outer }
+		if i == 2 { break outer }
+		out = append(out, [i, j])
+	}
+}`, nil, ARR{ARR{0, 0}, ARR{1, 0}})
+	expectRun(t, `
+out = []
+rows: for r in [[1, 2], [3, -1, 4], [5, 6]] {
+	cols:
+	for c in r {
+		if c < 0 { continue rows }
+		if c == 5 { break cols }
+		out = append(out, c)
+	}
+	out = append(out, "|")
+}`, nil, ARR{1, 2, "|", 3, "|"})
+	expectRun(t, `
+out = 0
+a: for {
+	b: for {
+		for {
+			out++
+			if out < 3 { continue b }
+			break a
+		}
+	}
+}`, nil, 3)
+	expectRun(t, `
+f := func() {
+	n := 0
+	L: for i in [1, 2, 3] {
+		for { n += i; continue L }
+	}
+	return n
+}
+out = f()`, nil, 6)
+
+	// labels can be reused by sibling loops
+	expectRun(t, `
+out = 0
+L: for i := 0; i < 2; i++ { out++; continue L }
+L: for i := 0; i < 2; i++ { out++; break L }`, nil, 3)
+
+	expectError(t, `L: for { for { break M } }`,
+		nil, "break label 'M' not defined")
+	expectError(t, `L: for {}; for { continue L }`,
+		nil, "continue label 'L' not defined")
+	expectError(t, `L: for { L: for {} }`, nil, "label 'L' already defined")
+	expectError(t, `L: a := 1`, nil, "label 'L' must be followed by a loop")
+	expectError(t, `L: for { f := func() { break L } }`,
+		nil, "break not allowed outside loop")
+	expectError(t, `for { func() { continue }() }`,
+		nil, "continue not allowed outside loop")
+}
+
 func TestFunction(t *testing.T) {
 	// function with no "return" statement returns "invalid" value.
 	expectRun(t, `f1 := func() {}; out = f1();`,
@@ -2052,6 +2523,18 @@ func TestInteger(t *testing.T) {
 
 	expectRun(t, `out = 9 + '0'`, nil, '9')
 	expectRun(t, `out = '9' - 5`, nil, '4')
+
+	expectRun(t, `out = 2 ** 10`, nil, 1024)
+	expectRun(t, `out = 2 ** 3 ** 2`, nil, 512)
+	expectRun(t, `out = 3 * 2 ** 2`, nil, 12)
+	expectRun(t, `out = -2 ** 2`, nil, 4)
+	expectRun(t, `out = 2 ** -2`, nil, 0.25)
+	expectRun(t, `out = 10; out **= 3`, nil, 1000)
+	expectRun(t, `out = 9223372036854775807 + 1`, nil, math.MinInt64)
+
+	expectError(t, `1 / 0`, nil, "Runtime Error: division by zero")
+	expectError(t, `a := 0; 5 % a`, nil, "Runtime Error: division by zero")
+	expectError(t, `a := 1; a /= 0`, nil, "Runtime Error: division by zero")
 }
 
 type StringArrayIterator struct {
@@ -2195,6 +2678,55 @@ out = m["foo"](2) + m["foo"](3)
 		nil, 5)
 	expectRun(t, `func() { m1 := {k1: 1, k2: "foo"}; m2 := m1; m2.k1 = 3; out = m1.k1 }()`,
 		nil, 3)
+
+	// non-string keys
+	expectRun(t, `m := {}; m[1] = "a"; m["1"] = "b"; out = m[1] + m["1"]`,
+		nil, "ab")
+	expectRun(t, `m := {}; m[1] = "a"; out = m[2]`, nil, tengo.UndefinedValue)
+	expectRun(t, `m := {}; m[1] = "a"; m[1] = "b"; out = len(m)`, nil, 1)
+	expectRun(t, `m := {}; m['x'] = 1; m[true] = 2; m[1.5] = 3; out = m['x'] + m[true] + m[1.5]`,
+		nil, 6)
+	expectRun(t, `m := {}; m[0.0] = 1; out = m[-0.0]`, nil, 1)
+	expectRun(t, `m := {}; m[decimal("1.50")] = 1; out = m[decimal("1.5")]`,
+		nil, 1)
+	expectRun(t, `m := {}; m[time(0)] = 1; out = m[time(0)]`, nil, 1)
+	expectRun(t, `m := {}; m[immutable([1, "a"])] = 1; out = m[immutable([1, "a"])]`,
+		nil, 1)
+	expectRun(t, `m := {}; m[immutable([1, "a"])] = 1; out = m[immutable(["a", 1])]`,
+		nil, tengo.UndefinedValue)
+	expectRun(t, `m := {a: 1}; m[2] = 3; out = 0; for k, v in m { out += v }`,
+		nil, 4)
+	expectRun(t, `m := {}; m[2] = 3; out = []; for k, v in m { out = append(out, k, v) }`,
+		nil, ARR{2, 3})
+	expectRun(t, `out = {(k * 2): k for k in [1, 2]}[4]`, nil, 2)
+	expectRun(t, `m := {}; m[1] = {}; m2 := copy(m); m2[1].a = 1; out = m[1].a`,
+		nil, tengo.UndefinedValue)
+	expectRun(t, `a := {}; a[1] = 2; b := {}; b[1] = 2; out = a == b`,
+		nil, true)
+	expectRun(t, `a := {}; a[1] = 2; b := {}; b["1"] = 2; out = a == b`,
+		nil, false)
+	expectRun(t, `m := {}; m[1] = 2; out = has(m, 1) && !has(m, "1")`,
+		nil, true)
+	expectRun(t, `m := {}; m[1] = 2; out = immutable(m)[1]`, nil, 2)
+	expectRun(t, `m := {}; m[1] = 2; out = string(m)`, nil, "{1: 2}")
+	expectError(t, `m := {}; m[[1]] = 2`, nil, "invalid index type: array")
+	expectError(t, `m := {}; m[immutable([[1]])] = 2`, nil,
+		"invalid index type: immutable-array")
+	expectError(t, `m := {}; x := m[{}]`, nil, "invalid index type: map")
+
+	// elements are kept in insertion order
+	expectRun(t, `out = string({c: 1, a: 2, b: 3})`, nil, "{c: 1, a: 2, b: 3}")
+	expectRun(t, `out = ""; for k, _ in {z: 1, y: 2, x: 3} { out += k }`,
+		nil, "zyx")
+	expectRun(t, `m := {b: 1}; m.a = 2; m[3] = 3; m.b = 4; out = string(m)`,
+		nil, "{b: 4, a: 2, 3: 3}")
+	expectRun(t, `m := {b: 1, a: 2}; c := copy(m); c.z = 1; out = string(c)`,
+		nil, "{b: 1, a: 2, z: 1}")
+	expectRun(t, `out = string(immutable({b: 1, a: 2}))`, nil, "{b: 1, a: 2}")
+	expectRun(t, `out = [k for k, _ in {z: 1, y: 2, x: 3}]`, nil,
+		ARR{"z", "y", "x"})
+	expectRun(t, `out = string({(k): 1 for k in ["c", "a", "b"]})`, nil,
+		"{c: 1, a: 1, b: 1}")
 }
 
 func TestBuiltin(t *testing.T) {
@@ -2490,6 +3022,224 @@ func testAllocsLimit(t *testing.T, src string, limit int64) {
 	}
 }
 
+func TestOperatorOverloading(t *testing.T) {
+	vec := `
+Vec := record {
+	x: 0,
+	y: 0,
+	__add: func(a, b) { return Vec(a.x + b.x, a.y + b.y) },
+	__sub: func(a, b) { return Vec(a.x - b.x, a.y - b.y) },
+	__mul: func(a, k) { return Vec(a.x * k, a.y * k) },
+	__eq: func(a, b) { return is_record(b, Vec) && a.x == b.x && a.y == b.y },
+	__lt: func(a, b) { return a.x < b.x },
+	__string: func(a) { return "(" + a.x + ", " + a.y + ")" }
+}
+`
+	// binary operators
+	expectRun(t, vec+`out = string(Vec(1, 2) + Vec(3, 4) * 2)`, nil, "(7, 10)")
+	expectRun(t, vec+`v := Vec(5, 5); v -= Vec(1, 2); out = [v.x, v.y]`,
+		nil, ARR{4, 3})
+	expectRun(t, vec+`out = [Vec(1, 0) < Vec(2, 0), Vec(2, 0) > Vec(1, 0),
+	Vec(3, 0) < Vec(2, 0)]`, nil, ARR{true, true, false})
+	expectError(t, vec+`Vec() / 2`, nil, "invalid operation: Vec / int")
+	expectError(t, vec+`2 * Vec()`, nil, "invalid operation: int * Vec")
+	expectRun(t, `m := {n: 5, __add: func(a, b) { return a.n + b }}; out = m + 1`,
+		nil, 6)
+	expectRun(t, `m := immutable({__or: func(a, b) { return "or" }}); out = m | 1`,
+		nil, "or")
+
+	// equality
+	expectRun(t, vec+`out = [Vec(1, 2) == Vec(1, 2), Vec(1, 2) != Vec(1, 2),
+	Vec(1, 2) == Vec(2, 1), Vec() == 0, 0 == Vec()]`,
+		nil, ARR{true, false, false, false, false})
+	expectRun(t, `m := {__eq: func(a, b) { return true }}; out = [m == 1, 1 == m]`,
+		nil, ARR{true, true})
+
+	// string conversion
+	expectRun(t, vec+`out = "v = " + Vec(1, 2)`, nil, "v = (1, 2)")
+	expectRun(t, vec+`v := Vec(1, 2); out = f"v = ${v}"`, nil, "v = (1, 2)")
+	expectRun(t, `m := {__string: func(m) { return "m" }}; out = string(m)`,
+		nil, "m")
+	expectError(t, `m := {__string: func(m) { return 1 }}; string(m)`,
+		nil, "invalid return type from __string: expected string, found int")
+
+	// indexing
+	expectRun(t, `
+Bag := record {
+	items: {},
+	__index: func(b, k) { return b.items[k] }
+}
+b := Bag(); b.items.a = 1
+out = [b.a, b["a"], b.items.a, b.c]`,
+		nil, ARR{1, 1, 1, tengo.UndefinedValue})
+	expectRun(t, `m := {a: 1, __index: func(m, k) { return k + "?" }}
+out = [m.a, m.b, m?.c]`, nil, ARR{1, "b?", "c?"})
+
+	// calls
+	expectRun(t, `
+Adder := record { n: 0, __call: func(a, x) { return a.n + x } }
+out = Adder(3)(4)`, nil, 7)
+	expectRun(t, `m := {__call: func(m, ...args) { return len(args) }}
+out = [m(), m(1, 2)]`, nil, ARR{0, 2})
+	expectRun(t, `m := {__call: len}; out = m()`, nil, 1)
+	expectError(t, `m := {__call: func(m) {}}; m(1)`,
+		nil, "wrong number of arguments: want=1, got=2")
+
+	// iteration
+	expectRun(t, `
+Range := record { n: 0, __iter: func(r) {
+	res := []
+	for i := 0; i < r.n; i++ { res = append(res, i) }
+	return res
+}}
+out = 0
+for i, x in Range(4) { out += i * x }`, nil, 14)
+	expectRun(t, `m := {a: 1, __iter: func(m) { return {b: 2} }}
+out = []; for k, v in m { out = append(out, k, v) }`, nil, ARR{"b", 2})
+	expectError(t, `m := {__iter: func(m) { return 1 }}; for x in m {}`,
+		nil, "not iterable: int")
+
+	// errors and recursion inside special methods
+	expectError(t, `R := record { __add: func(a, b) { return a.z } }; R() + 1`,
+		nil, `unknown field "z" in R`)
+	expectRun(t, `
+fib := func(n) { return n < 2 ? n : fib(n - 1) + fib(n - 2) }
+R := record { __add: func(a, b) { return fib(b) } }
+out = R() + 15`, nil, 610)
+	expectError(t, `R := record { __add: func(a, b) { return a + b } }; R() + 1`,
+		nil, "stack overflow")
+}
+
+func TestOptionalChaining(t *testing.T) {
+	expectRun(t, `
+cfg := {db: {primary: {host: "localhost", ports: [5432, 5433]}}}
+out = cfg?.db?.primary.host`, nil, "localhost")
+	expectRun(t, `
+cfg := {db: {primary: {host: "localhost", ports: [5432, 5433]}}}
+out = cfg.db.replica?.host`, nil, tengo.UndefinedValue)
+	expectRun(t, `
+cfg := {db: {primary: {host: "localhost", ports: [5432, 5433]}}}
+out = cfg.db.primary.ports?.[1]`, nil, 5433)
+	expectRun(t, `
+cfg := {db: {primary: {host: "localhost", ports: [5432, 5433]}}}
+out = cfg.db.replica?.ports[1]`, nil, tengo.UndefinedValue)
+	expectRun(t, `
+cfg := {db: {primary: {host: "localhost", ports: [5432, 5433]}}}
+out = cfg.db.replica?.host ?? cfg.db.primary.host`, nil, "localhost")
+	expectRun(t, `out = undefined?.[1:]`, nil, tengo.UndefinedValue)
+	expectRun(t, `out = [1, 2, 3]?.[1:]`, nil, ARR{2, 3})
+	expectRun(t, `out = "abc"?.[1]`, nil, 'b')
+
+	// the rest of the chain is skipped
+	expectRun(t, `a := undefined; out = a?.b.c.d()`, nil, tengo.UndefinedValue)
+	expectRun(t, `a := undefined; out = a?.b().c[1:]`,
+		nil, tengo.UndefinedValue)
+	expectRun(t, `m := {f: func() { return 5 }}; out = m?.f()`, nil, 5)
+	expectRun(t, `
+out = 0
+f := func() { out++; return "b" }
+a := undefined
+a?.[f()]
+out = out`, nil, 0)
+	expectRun(t, `
+out = 0
+f := func() { out++; return "b" }
+a := {b: 4}
+out = a?.[f()] + out`, nil, 5)
+
+	// only undefined is short-circuited
+	expectError(t, `a := 1; a?.b`, nil, "not indexable")
+	expectError(t, `a := undefined; a.b()`, nil,
+		"not callable: undefined")
+
+	// not allowed in assignment
+	expectError(t, `a := {}; a?.b = 1`, nil,
+		"optional chaining not allowed in assignment")
+	expectError(t, `a := {b: {}}; a?.b.c = 1`, nil,
+		"optional chaining not allowed in assignment")
+	expectError(t, `a := [1]; a?.[0]++`, nil,
+		"optional chaining not allowed in assignment")
+}
+
+func TestRecord(t *testing.T) {
+	expectRun(t, `Point := record { x: 0, y: 0 }; p := Point(1, 2); out = p.x + p.y`,
+		nil, 3)
+	expectRun(t, `Point := record { x: 0, y: 0 }; p := Point(1); out = [p.x, p.y]`,
+		nil, ARR{1, 0})
+	expectRun(t, `Point := record { x: 0, y: 0 }; out = string(Point(1, 2))`,
+		nil, "Point{x: 1, y: 2}")
+	expectRun(t, `P := record { x }; out = P().x`, nil, tengo.UndefinedValue)
+	expectRun(t, `out = type_name(record { x: 1 }())`, nil, "record")
+	expectRun(t, `record := {x: 1}; if record { out = record.x }`, nil, 1)
+	expectRun(t, `f := func(record) { return record * 2 }; out = f(2)`,
+		nil, 4)
+	expectError(t, `Point := record { x: 0, y: 0 }; Point(1, 2, 3)`,
+		nil, "wrong number of arguments")
+
+	// fields
+	expectRun(t, `P := record { x: 0 }; p := P(); p.x = 5; p["x"] += 1; out = p.x`,
+		nil, 6)
+	expectRun(t, `P := record { a: [] }; p1 := P(); p2 := P()
+p1.a = append(p1.a, 1); out = [len(p1.a), len(p2.a)]`, nil, ARR{1, 0})
+	expectError(t, `Point := record { x: 0 }; Point().z`,
+		nil, `unknown field "z" in Point`)
+	expectError(t, `Point := record { x: 0 }; p := Point(); p.z = 1`,
+		nil, `unknown field "z" in Point`)
+	expectError(t, `P := record { x: 0 }; P()[1]`, nil, "invalid index type")
+
+	// methods
+	expectRun(t, `
+Point := record {
+	x: 0,
+	y: 0,
+	sum: func(self) { return self.x + self.y },
+	scale: func(self, n) { self.x *= n; self.y *= n; return self }
+}
+p := Point(1, 2)
+out = p.scale(3).sum()`, nil, 9)
+	expectRun(t, `P := record { x: 1, get: func(self) { return self.x } }
+f := P(5).get; out = [f(), is_function(f)]`, nil, ARR{5, true})
+	expectRun(t, `
+Counter := record { n: 0, inc: func(self, ...d) { self.n += len(d) + 1 } }
+c := Counter(); c.inc(); c.inc(1, 2); out = c.n`, nil, 4)
+	expectRun(t, `
+Cmp := record { desc: true, less: func(self, a, b) { return self.desc ? a > b : a < b } }
+c := Cmp()
+out = [sort_by([1, 3, 2], c.less), sort_by([1, 3, 2], Cmp(false).less)]`,
+		nil, ARR{ARR{3, 2, 1}, ARR{1, 2, 3}})
+	expectError(t, `
+Cmp := record { less: func(self, a) { return true } }
+sort_by([1, 2], Cmp().less)`, nil, "wrong number of arguments: want=2, got=3")
+	expectError(t, `P := record { x: 1, f: func(self) {} }; p := P(); p.f = 1`,
+		nil, "not index-assignable")
+
+	// type checks, equality and copies
+	expectRun(t, `P := record { x: 0 }; Q := record { x: 0 }; p := P()
+out = [type_name(p), is_record(p), is_record(p, P), is_record(p, Q),
+	is_record({x: 0})]`, nil, ARR{"P", true, true, false, false})
+	expectRun(t, `P := record { x: 0 }; out = [P(1) == P(1), P(1) == P(2)]`,
+		nil, ARR{true, false})
+	expectRun(t, `P := record { x: 0 }; Q := record { x: 0 }; out = P() == Q()`,
+		nil, false)
+	expectRun(t, `P := record { x: 0 }; a := P(); b := copy(a); b.x = 1
+out = [a.x, b.x]`, nil, ARR{0, 1})
+	expectError(t, `is_record(1, 2)`, nil, "invalid type for argument 'second'")
+
+	// local scope
+	expectRun(t, `
+f := func() {
+	V := record { v: 0, double: func(self) { return self.v * 2 } }
+	return V(21).double()
+}
+out = f()`, nil, 42)
+
+	// compile errors
+	expectError(t, `record { x: 1, x: 2 }`,
+		nil, "duplicate record element 'x'")
+	expectError(t, `record { f: func() {} }`,
+		nil, "method 'f' must have a receiver parameter")
+}
+
 func TestReturn(t *testing.T) {
 	expectRun(t, `out = func() { return 10; }()`, nil, 10)
 	expectRun(t, `out = func() { return 10; return 9; }()`, nil, 10)
@@ -2716,6 +3466,73 @@ func() {
 		nil, "not index-assignable")
 }
 
+func TestSet(t *testing.T) {
+	newSet := func(elems ...interface{}) *tengo.Set {
+		s := &tengo.Set{}
+		for _, e := range elems {
+			require.NoError(t, s.Add(toObject(e)))
+		}
+		return s
+	}
+
+	expectRun(t, `out = set()`, nil, newSet())
+	expectRun(t, `out = set(1, "a", 1, 'c')`, nil, newSet(1, "a", 'c'))
+	expectRun(t, `out = len(set(1, 2, 2, 3))`, nil, 3)
+	expectRun(t, `out = set(1, 2) | set(2, 3)`, nil, newSet(1, 2, 3))
+	expectRun(t, `out = set(1, 2) & set(2, 3)`, nil, newSet(2))
+	expectRun(t, `out = set(1, 2) - set(2, 3)`, nil, newSet(1))
+	expectRun(t, `out = set(1); out |= set(2)`, nil, newSet(1, 2))
+	expectRun(t, `out = set(1, 2) == set(2, 1)`, nil, true)
+	expectRun(t, `out = set(1, 2) == set(1)`, nil, false)
+	expectRun(t, `out = set(1) == [1]`, nil, false)
+	expectRun(t, `out = set() ? 1 : 2`, nil, 2)
+
+	// membership
+	expectRun(t, `out = has(set(1, 2), 2)`, nil, true)
+	expectRun(t, `out = has(set(1, 2), 3)`, nil, false)
+	expectRun(t, `out = has(set(1, 2), [1])`, nil, false)
+	expectRun(t, `out = has({a: 1}, "a")`, nil, true)
+	expectRun(t, `out = has(immutable({a: 1}), "b")`, nil, false)
+	expectRun(t, `out = set("a")["a"]`, nil, true)
+	expectRun(t, `out = set("a")["b"]`, nil, false)
+	expectError(t, `has([1], 1)`, nil,
+		"invalid type for argument 'first' in call to 'builtin-function:has': "+
+			"expected set/map, found array")
+
+	// mutation
+	expectRun(t, `out = set(1); out[2] = true; out[1] = false`, nil,
+		newSet(2))
+	expectRun(t, `a := set(1); b := a; b[2] = true; out = len(a)`, nil, 2)
+	expectRun(t, `a := set(1); b := copy(a); b[2] = true; out = len(a)`,
+		nil, 1)
+	expectError(t, `a := set(); a[[1]] = true`, nil, "not hashable")
+	expectError(t, `set(1, {})`, nil, "Runtime Error: not hashable")
+
+	// iteration keeps the order the elements were added
+	expectRun(t, `out = []; for x in set(3, 1, 2, 1) { out = append(out, x) }`,
+		nil, ARR{3, 1, 2})
+	expectRun(t, `out = 0; for i, _ in set("a", "b", "c") { out += i }`,
+		nil, 3)
+	expectRun(t, `out = [x * 2 for x in set(1, 2)]`, nil, ARR{2, 4})
+	expectRun(t, `s := set(1, 2, 3); out = []; for x in s { s[x] = false; out = append(out, x) }`,
+		nil, ARR{1, 2, 3})
+
+	// immutable sets
+	expectRun(t, `out = string(immutable(set(1, 2)))`, nil, "set(1, 2)")
+	expectRun(t, `out = is_immutable_set(immutable(set(1, 2)))`, nil, true)
+	expectRun(t, `out = is_set(immutable(set(1, 2)))`, nil, false)
+	expectRun(t, `out = is_set(set(1, 2))`, nil, true)
+	expectRun(t, `out = is_set([1, 2])`, nil, false)
+	expectRun(t, `a := set(1); b := immutable(a); a[2] = true; out = len(b)`,
+		nil, 1)
+	expectRun(t, `out = immutable(set(1)) | set(2)`, nil, newSet(1, 2))
+	expectRun(t, `out = copy(immutable(set(1)))`, nil, newSet(1))
+	expectRun(t, `out = immutable(set(1)) == set(1)`, nil, true)
+	expectRun(t, `out = type_name(immutable(set(1)))`, nil, "immutable-set")
+	expectError(t, `a := immutable(set(1)); a[2] = true`, nil,
+		"not index-assignable")
+}
+
 func TestSourceModules(t *testing.T) {
 	testEnumModule(t, `out = enum.key(0, 20)`, 0)
 	testEnumModule(t, `out = enum.key(10, 20)`, 10)
@@ -2968,6 +3785,33 @@ out = x.at([1, 2, 3], 0)
 `, Opts().Stdlib(), 1)
 }
 
+func TestVM_EnableOverflowCheck(t *testing.T) {
+	fileSet := parser.NewFileSet()
+	input := []byte(`out := 9223372036854775807 + 1`)
+	srcFile := fileSet.AddFile("test", -1, len(input))
+	file, err := parser.NewParser(srcFile, input, nil).ParseFile()
+	require.NoError(t, err)
+	symTable := tengo.NewSymbolTable()
+	c := tengo.NewCompiler(srcFile, symTable, nil, nil, nil)
+	require.NoError(t, c.Compile(file))
+	symbol, _, _ := symTable.Resolve("out")
+
+	globals := make([]tengo.Object, tengo.GlobalsSize)
+	v := tengo.NewVM(c.Bytecode(), globals, -1)
+	require.NoError(t, v.Run())
+	require.Equal(t, int64(math.MinInt64),
+		globals[symbol.Index].(*tengo.Int).Value)
+
+	v.EnableOverflowCheck(true)
+	err = v.Run()
+	require.Error(t, err)
+	require.True(t, strings.Contains(err.Error(),
+		tengo.ErrIntegerOverflow.Error()), err.Error())
+
+	v.EnableOverflowCheck(false)
+	require.NoError(t, v.Run())
+}
+
 func TestVMStackOverflow(t *testing.T) {
 	expectError(t, `f := func() { return f() + 1 }; f()`,
 		nil, "stack overflow")
@@ -3062,6 +3906,29 @@ func TestString(t *testing.T) {
 	expectError(t, `1 + "foo"`, nil, "invalid operation")
 
 	expectError(t, `"foo" - "bar"`, nil, "invalid operation")
+
+	// interpolated strings
+	expectRun(t, `out = f"foo"`, nil, "foo")
+	expectRun(t, `out = f""`, nil, "")
+	expectRun(t, `a := 1; out = f"${a}"`, nil, "1")
+	expectRun(t, `a := 1; b := "bar"; out = f"foo ${a + 1} ${b}!"`,
+		nil, "foo 2 bar!")
+	expectRun(t, `out = f"${1.5} ${true} ${'X'} ${undefined} ${[1, 2]}"`,
+		nil, "1.5 true X <undefined> [1, 2]")
+	expectRun(t, `out = f"${error(5)}"`, nil, "error: 5")
+	expectRun(t, `m := {a: {b: "c"}}; out = f"${m["a"].b}${m.a["b"]}"`,
+		nil, "cc")
+	expectRun(t, `out = f"\${a} \t${"}"}"`, nil, "${a} \t}")
+	expectRun(t, `a := "x"; out = f"[${f"(${a})"}]"`, nil, "[(x)]")
+	expectRun(t, `f := func(x) { return x * 2 }; out = f"${f(2)}"`, nil, "4")
+	expectRun(t, `out = f"${ {a: 1}.a }"`, nil, "1")
+	expectRun(t, `
+out = ""
+for i in [1, 2, 3] {
+	out += f"${i},"
+}`, nil, "1,2,3,")
+	expectError(t, `f"${a}"`, nil, "unresolved reference 'a'")
+	expectError(t, `f"${1 - "a"}"`, nil, "invalid operation")
 }
 
 func TestTailCall(t *testing.T) {
@@ -3221,6 +4088,8 @@ func expectRun(
 			expectedObj = &tengo.ImmutableArray{Value: eo.Value}
 		case *tengo.Map:
 			expectedObj = &tengo.ImmutableMap{Value: eo.Value}
+		case *tengo.Set:
+			expectedObj, _ = tengo.NewImmutableSet(eo.Elements()...)
 		}
 
 		modules.AddSourceModule("__code__",
@@ -3467,6 +4336,14 @@ func objectZeroCopy(o tengo.Object) tengo.Object {
 		return &tengo.ImmutableArray{}
 	case *tengo.ImmutableMap:
 		return &tengo.ImmutableMap{}
+	case *tengo.Set:
+		return &tengo.Set{}
+	case *tengo.ImmutableSet:
+		return &tengo.ImmutableSet{}
+	case *tengo.BigInt:
+		return &tengo.BigInt{Value: new(big.Int)}
+	case *tengo.Decimal:
+		return &tengo.Decimal{Value: new(big.Int)}
 	case nil:
 		panic("nil")
 	default: